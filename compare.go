@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// change is one field that differs between a baseline result and a later
+// one.
+type change struct {
+	text        string
+	significant bool
+}
+
+// compareTo checks target and reports how the result differs from the JSON
+// result saved at path, WARNING when anything significant changed. A unit
+// that can't be read is reported as it would be without a baseline.
+func compareTo(path string, target string) (int, error) {
+	baseline, err := readResult(path)
+	if err != nil {
+		return sensu.CheckStateUnknown, err
+	}
+
+	res, err := checkTarget(target)
+	var threshold *ErrThreshold
+	if err != nil && !errors.As(err, &threshold) {
+		return res.print()
+	}
+
+	changes := diffResults(baseline, res, plugin.CompareDelta)
+	if len(changes) == 0 {
+		return res.report(sensu.CheckStateOK, "no changes since the baseline.", res.Metrics)
+	}
+
+	state, significant := sensu.CheckStateOK, 0
+	texts := make([]string, len(changes))
+	for i, c := range changes {
+		texts[i] = c.text
+		if c.significant {
+			state = sensu.CheckStateWarning
+			significant++
+		}
+	}
+	summary := fmt.Sprintf("changed since the baseline, %d of %d significant: %s", significant, len(changes), strings.Join(texts, "; "))
+	return res.report(state, summary, res.Metrics)
+}
+
+// readResult loads a result saved by --output json.
+func readResult(path string) (*checkResult, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the baseline: %v", err)
+	}
+	var r checkResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s isn't a JSON result: %v", path, err)
+	}
+	return &r, nil
+}
+
+// diffResults lists the fields that differ from before to after. A temperature
+// moving by delta or more, the unit being moved or renamed, the state
+// changing and a metric appearing or disappearing are significant; the rest
+// is reported for information.
+func diffResults(before *checkResult, after *checkResult, delta float64) []change {
+	var changes []change
+
+	text := func(field string, was string, is string) {
+		if was != is {
+			changes = append(changes, change{fmt.Sprintf("%s %q -> %q", field, was, is), field != "snmp_version"})
+		}
+	}
+	text("state", before.State, after.State)
+	text("location", before.Location, after.Location)
+	text("sysname", before.SysName, after.SysName)
+	text("snmp_version", before.SnmpVersion, after.SnmpVersion)
+
+	reading := func(field string, unit string, was *float64, is *float64, significant bool) {
+		switch {
+		case was == nil && is == nil:
+		case was == nil:
+			changes = append(changes, change{fmt.Sprintf("%s appeared at %.2f%s", field, *is, unit), significant})
+		case is == nil:
+			changes = append(changes, change{fmt.Sprintf("%s disappeared, was %.2f%s", field, *was, unit), significant})
+		case *was != *is:
+			changes = append(changes, change{fmt.Sprintf("%s %.2f%s -> %.2f%s", field, *was, unit, *is, unit), significant && math.Abs(*is-*was) >= delta})
+		}
+	}
+	reading("internal", "c", before.Internal, after.Internal, true)
+	reading("external", "c", before.External, after.External, true)
+	reading("humidity", "%", before.Humidity, after.Humidity, false)
+
+	// a metric coming or going means a probe was added or removed
+	names := func(r *checkResult) map[string]bool {
+		m := make(map[string]bool, len(r.Metrics))
+		for _, metric := range r.Metrics {
+			m[metric.Name] = true
+		}
+		return m
+	}
+	was, is := names(before), names(after)
+	for _, metric := range after.Metrics {
+		if !was[metric.Name] {
+			changes = append(changes, change{fmt.Sprintf("new metric %s", metric.Name), true})
+		}
+	}
+	for _, metric := range before.Metrics {
+		if !is[metric.Name] {
+			changes = append(changes, change{fmt.Sprintf("metric %s is gone", metric.Name), true})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	before, err := readResult(filepath.Join("testdata", "baseline.json"))
+	if err != nil {
+		t.Fatalf("readResult() error = %v", err)
+	}
+	after, err := readResult(filepath.Join("testdata", "moved.json"))
+	if err != nil {
+		t.Fatalf("readResult() error = %v", err)
+	}
+
+	want := []change{
+		{`state "OK" -> "WARNING"`, true},
+		{`location "lab" -> "loading dock"`, true},
+		{"internal 20.00c -> 21.00c", false},
+		{"external 21.50c -> 36.00c", true},
+		{"humidity appeared at 40.00%", false},
+		{"new metric tempager_humidity", true},
+	}
+	if got := diffResults(before, after, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffResults() = %v, want %v", got, want)
+	}
+
+	// going back lists the same fields the other way round
+	back := diffResults(after, before, 2)
+	if len(back) != len(want) || back[5].text != "metric tempager_humidity is gone" {
+		t.Errorf("diffResults() backwards = %v", back)
+	}
+
+	if got := diffResults(before, before, 2); len(got) != 0 {
+		t.Errorf("diffResults() of a result with itself = %v, want none", got)
+	}
+}
+
+func TestExecuteCheckCompareTo(t *testing.T) {
+	tests := []struct {
+		external  int
+		wantState int
+		want      string
+	}{
+		{2150, sensu.CheckStateOK, "no changes since the baseline."},
+		{2250, sensu.CheckStateOK, "changed since the baseline, 0 of 1 significant: external 21.50c -> 22.50c"},
+		{2400, sensu.CheckStateWarning, "changed since the baseline, 1 of 1 significant: external 21.50c -> 24.00c"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.CompareTo = filepath.Join("testdata", "baseline.json")
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if state != tt.wantState || !strings.Contains(out, tt.want) {
+			t.Errorf("external %d: executeCheck() = %d, %q, want %d, %q", tt.external, state, out, tt.wantState, tt.want)
+		}
+	}
+}
+
+func TestExecuteCheckCompareToMissingBaseline(t *testing.T) {
+	setDefaults()
+	plugin.CompareTo = filepath.Join("testdata", "missing.json")
+
+	if state, err := executeCheck(nil); state != sensu.CheckStateUnknown || err == nil {
+		t.Errorf("executeCheck() = %d, %v, want UNKNOWN with an error", state, err)
+	}
+}
+
+func TestCheckArgsCompareTo(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.CompareTo = filepath.Join("testdata", "baseline.json")
+	plugin.CompareDelta = 0
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a compare-delta of 0")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// ErrConnect is a failure to reach the unit, or to get an answer from it.
+type ErrConnect struct {
+	Target string
+	Err    error
+}
+
+func (e *ErrConnect) Error() string {
+	return fmt.Sprintf("%s: %v", e.Target, e.Err)
+}
+
+func (e *ErrConnect) Unwrap() error {
+	return e.Err
+}
+
+// ErrDecode is an answer from the unit that couldn't be turned into a
+// reading.
+type ErrDecode struct {
+	Target string
+	Err    error
+}
+
+func (e *ErrDecode) Error() string {
+	return fmt.Sprintf("%s: %v", e.Target, e.Err)
+}
+
+func (e *ErrDecode) Unwrap() error {
+	return e.Err
+}
+
+// ErrThreshold is a reading that breached a threshold, State being the check
+// state it resulted in.
+type ErrThreshold struct {
+	Target  string
+	State   int
+	Summary string
+}
+
+func (e *ErrThreshold) Error() string {
+	return fmt.Sprintf("%s: %s %s", e.Target, stateLabels[e.State], e.Summary)
+}
+
+// ErrConfig is a set of plugin options that don't make sense.
+type ErrConfig struct {
+	Err error
+}
+
+func (e *ErrConfig) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrConfig) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"github.com/gosnmp/gosnmp"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"testing"
+)
+
+// checkWith runs checkTarget against client.
+func checkWith(t *testing.T, client snmpClient) (*checkResult, error) {
+	t.Helper()
+
+	oldClient := newClient
+	newClient = func(string, string) snmpClient { return client }
+	defer func() { newClient = oldClient }()
+
+	return checkTarget("192.0.2.1")
+}
+
+func TestCheckTargetConnectErrors(t *testing.T) {
+	refused := errors.New("no route to host")
+	timedOut := func([]string) (*gosnmp.SnmpPacket, error) {
+		return nil, errors.New("request timeout (after 3 retries)")
+	}
+
+	for _, client := range []*fakeClient{{connectErr: refused}, {get: timedOut}} {
+		setDefaults()
+		res, err := checkWith(t, client)
+
+		var connectErr *ErrConnect
+		if !errors.As(err, &connectErr) {
+			t.Errorf("checkTarget() error = %v, want an ErrConnect", err)
+			continue
+		}
+		if connectErr.Target != "192.0.2.1" || res.Status != sensu.CheckStateCritical {
+			t.Errorf("checkTarget() = %d, %+v, want a critical for 192.0.2.1", res.Status, connectErr)
+		}
+	}
+
+	// the cause is still there underneath
+	setDefaults()
+	if _, err := checkWith(t, &fakeClient{connectErr: refused}); !errors.Is(err, refused) {
+		t.Errorf("checkTarget() error = %v, want it to wrap %v", err, refused)
+	}
+}
+
+func TestCheckTargetDecodeErrors(t *testing.T) {
+	short := &gosnmp.SnmpPacket{Variables: tempagerPacket("lab", 2000, 2150).Variables[:1]}
+	status := &gosnmp.SnmpPacket{Error: gosnmp.GenErr, ErrorIndex: 2}
+
+	tests := []struct {
+		packet    *gosnmp.SnmpPacket
+		wantState int
+	}{
+		{short, sensu.CheckStateCritical},
+		{status, sensu.CheckStateUnknown},
+		{faultedPacket("lab", 2000), sensu.CheckStateCritical},
+		{tempagerPacket("lab", 2000, -27315), sensu.CheckStateUnknown},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		res, err := checkWith(t, &fakeClient{get: respond(tt.packet)})
+
+		var decodeErr *ErrDecode
+		if !errors.As(err, &decodeErr) || res.Status != tt.wantState {
+			t.Errorf("checkTarget() = %d, %v, want %d with an ErrDecode", res.Status, err, tt.wantState)
+		}
+	}
+
+	// a faulted external probe can still be picked out
+	setDefaults()
+	if _, err := checkWith(t, &fakeClient{get: respond(faultedPacket("lab", 2000))}); !errors.Is(err, errExternalFault) {
+		t.Errorf("checkTarget() error = %v, want it to wrap errExternalFault", err)
+	}
+}
+
+func TestCheckTargetThresholdError(t *testing.T) {
+	setDefaults()
+	res, err := checkWith(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 4100))})
+
+	var thresholdErr *ErrThreshold
+	if !errors.As(err, &thresholdErr) {
+		t.Fatalf("checkTarget() error = %v, want an ErrThreshold", err)
+	}
+	if thresholdErr.State != sensu.CheckStateCritical || thresholdErr.Summary != res.Summary {
+		t.Errorf("ErrThreshold = %+v, want critical with the summary %q", thresholdErr, res.Summary)
+	}
+
+	// a healthy unit isn't an error at all
+	setDefaults()
+	if _, err := checkWith(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}); err != nil {
+		t.Errorf("checkTarget() error = %v, want none", err)
+	}
+}
+
+func TestCheckArgsConfigError(t *testing.T) {
+	setDefaults()
+	_, err := checkArgs(nil)
+
+	var configErr *ErrConfig
+	if !errors.As(err, &configErr) || err.Error() != "target unit must be specified." {
+		t.Errorf("checkArgs() error = %v, want an ErrConfig for the missing target", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"github.com/gosnmp/gosnmp"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// httpAPIStub serves body as the unit's JSON HTTP API, with status.
+func httpAPIStub(t *testing.T, status int, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExecuteCheckHTTPFallback(t *testing.T) {
+	server := httpAPIStub(t, http.StatusOK, `{"location":"lab","internal":20,"external":36}`)
+	timeout := func([]string) (*gosnmp.SnmpPacket, error) {
+		return nil, errors.New("request timeout")
+	}
+
+	tests := []struct {
+		name   string
+		client *fakeClient
+	}{
+		{"get fails", &fakeClient{get: timeout}},
+		{"connect fails", &fakeClient{connectErr: errors.New("connection refused"), get: timeout}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setDefaults()
+			plugin.Target = "192.0.2.1"
+			plugin.HTTPFallbackURL = server.URL
+
+			state, out := runCheck(t, tt.client)
+			if state != sensu.CheckStateWarning || !strings.Contains(out, "lab temperature is 36.00c; read over HTTP, SNMP unavailable") {
+				t.Errorf("executeCheck() = %d, %q, want the WARNING read over HTTP", state, out)
+			}
+		})
+	}
+}
+
+func TestExecuteCheckHTTPFallbackFails(t *testing.T) {
+	server := httpAPIStub(t, http.StatusServiceUnavailable, "")
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.HTTPFallbackURL = server.URL
+
+	state, out := runCheck(t, &fakeClient{connectErr: errors.New("connection refused")})
+	if state != sensu.CheckStateCritical || !strings.Contains(out, "failed to connect") || strings.Contains(out, "HTTP") {
+		t.Errorf("executeCheck() = %d, %q, want the SNMP failure", state, out)
+	}
+}
+
+func TestCheckArgsHTTPFallbackURL(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.HTTPFallbackURL = "ftp://192.0.2.1/status.json"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted an http-fallback-url that isn't http")
+	}
+}
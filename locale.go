@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"strings"
+)
+
+// messages is the wording of the summary in one language.
+type messages struct {
+	states map[int]string
+	// temperature is the format of the reading, taking the location and
+	// the formatted temperature
+	temperature string
+	// decimal and thousands separate the numbers under locale-numbers
+	decimal   string
+	thousands string
+}
+
+// catalog holds the languages the summary can be worded in, by locale.
+var catalog = map[string]messages{
+	"en": {
+		states:      stateLabels,
+		temperature: "%s temperature is %sc",
+		decimal:     ".",
+		thousands:   ",",
+	},
+	"de": {
+		states: map[int]string{
+			sensu.CheckStateOK:       "OK",
+			sensu.CheckStateWarning:  "WARNUNG",
+			sensu.CheckStateCritical: "KRITISCH",
+			sensu.CheckStateUnknown:  "UNBEKANNT",
+		},
+		temperature: "Temperatur %s beträgt %sc",
+		decimal:     ",",
+		thousands:   ".",
+	},
+	"fr": {
+		states: map[int]string{
+			sensu.CheckStateOK:       "OK",
+			sensu.CheckStateWarning:  "AVERTISSEMENT",
+			sensu.CheckStateCritical: "CRITIQUE",
+			sensu.CheckStateUnknown:  "INCONNU",
+		},
+		temperature: "la température de %s est de %sc",
+		decimal:     ",",
+		thousands:   "\u202f",
+	},
+	"es": {
+		states: map[int]string{
+			sensu.CheckStateOK:       "OK",
+			sensu.CheckStateWarning:  "ADVERTENCIA",
+			sensu.CheckStateCritical: "CRÍTICO",
+			sensu.CheckStateUnknown:  "DESCONOCIDO",
+		},
+		temperature: "la temperatura de %s es %sc",
+		decimal:     ",",
+		thousands:   ".",
+	},
+}
+
+// localized returns the messages for the configured locale. checkArgs only
+// lets known locales through.
+func localized() messages {
+	if m, ok := catalog[plugin.Locale]; ok {
+		return m
+	}
+	return catalog["en"]
+}
+
+// stateWord returns the status word for state in the configured locale.
+func stateWord(state int) string {
+	return localized().states[state]
+}
+
+// temperatureSummary words the reading at location in the configured locale.
+func temperatureSummary(location string, temperature float64) string {
+	return fmt.Sprintf(localized().temperature, location, localNumber(temperature))
+}
+
+// localNumber formats v to two places for the summary, with the locale's
+// separators under locale-numbers. The perfdata never goes through here, it
+// has to stay parseable.
+func localNumber(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	if !plugin.LocaleNumbers {
+		return s
+	}
+
+	m := localized()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction := s[:len(s)-3], s[len(s)-2:]
+
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(m.thousands)
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String() + m.decimal + fraction
+}
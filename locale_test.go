@@ -0,0 +1,106 @@
+package main
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"testing"
+)
+
+func TestExecuteCheckLocale(t *testing.T) {
+	tests := []struct {
+		locale    string
+		external  int
+		wantState int
+		want      string
+	}{
+		{"en", 2150, sensu.CheckStateOK, "check-tempager-3e-temperature OK: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+		{"en", 4100, sensu.CheckStateCritical, "check-tempager-3e-temperature CRITICAL: lab temperature is 41.00c | tempager_internal=20.00, tempager_external=41.00\n"},
+		{"de", 3600, sensu.CheckStateWarning, "check-tempager-3e-temperature WARNUNG: Temperatur lab beträgt 36.00c | tempager_internal=20.00, tempager_external=36.00\n"},
+		{"de", 4100, sensu.CheckStateCritical, "check-tempager-3e-temperature KRITISCH: Temperatur lab beträgt 41.00c | tempager_internal=20.00, tempager_external=41.00\n"},
+		{"fr", 2150, sensu.CheckStateOK, "check-tempager-3e-temperature OK: la température de lab est de 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+		{"fr", 3600, sensu.CheckStateWarning, "check-tempager-3e-temperature AVERTISSEMENT: la température de lab est de 36.00c | tempager_internal=20.00, tempager_external=36.00\n"},
+		{"es", 3600, sensu.CheckStateWarning, "check-tempager-3e-temperature ADVERTENCIA: la temperatura de lab es 36.00c | tempager_internal=20.00, tempager_external=36.00\n"},
+		{"es", 4100, sensu.CheckStateCritical, "check-tempager-3e-temperature CRÍTICO: la temperatura de lab es 41.00c | tempager_internal=20.00, tempager_external=41.00\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Locale = tt.locale
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if state != tt.wantState || out != tt.want {
+			t.Errorf("%s: executeCheck() = %d, %q, want %d, %q", tt.locale, state, out, tt.wantState, tt.want)
+		}
+	}
+}
+
+func TestStateWordUnknown(t *testing.T) {
+	for locale, want := range map[string]string{"en": "UNKNOWN", "de": "UNBEKANNT", "fr": "INCONNU", "es": "DESCONOCIDO"} {
+		setDefaults()
+		plugin.Locale = locale
+		if got := stateWord(sensu.CheckStateUnknown); got != want {
+			t.Errorf("%s: stateWord(UNKNOWN) = %q, want %q", locale, got, want)
+		}
+	}
+}
+
+func TestExecuteCheckLocaleNumbers(t *testing.T) {
+	const humidityOID = ".1.3.6.1.4.1.20916.1.7.1.3.1.1.0"
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en", "check-tempager-3e-temperature WARNING: lab temperature is 36.00c with 45.50% humidity | tempager_internal=20.00, tempager_external=36.00, tempager_humidity=45.50\n"},
+		{"de", "check-tempager-3e-temperature WARNUNG: Temperatur lab beträgt 36,00c with 45,50% humidity | tempager_internal=20.00, tempager_external=36.00, tempager_humidity=45.50\n"},
+		{"fr", "check-tempager-3e-temperature AVERTISSEMENT: la température de lab est de 36,00c with 45,50% humidity | tempager_internal=20.00, tempager_external=36.00, tempager_humidity=45.50\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Locale = tt.locale
+		plugin.LocaleNumbers = true
+		plugin.HumidityOID = humidityOID
+
+		a := tempagerAgent("lab", 2000, 3600)
+		a[humidityOID] = gosnmp.SnmpPDU{Name: humidityOID, Type: gosnmp.Integer, Value: 4550}
+
+		_, out := runCheck(t, &fakeClient{get: a.get})
+		if out != tt.want {
+			t.Errorf("%s: executeCheck() = %q, want %q", tt.locale, out, tt.want)
+		}
+	}
+}
+
+func TestLocalNumber(t *testing.T) {
+	tests := []struct {
+		locale  string
+		numbers bool
+		v       float64
+		want    string
+	}{
+		{"de", false, 1234.5, "1234.50"},
+		{"en", true, 1234.5, "1,234.50"},
+		{"en", true, 21.5, "21.50"},
+		{"de", true, 1234567.891, "1.234.567,89"},
+		{"de", true, -5.25, "-5,25"},
+		{"es", true, 123, "123,00"},
+		{"fr", true, 273150, "273\u202f150,00"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Locale = tt.locale
+		plugin.LocaleNumbers = tt.numbers
+		if got := localNumber(tt.v); got != tt.want {
+			t.Errorf("%s, %t: localNumber(%v) = %q, want %q", tt.locale, tt.numbers, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestCheckArgsLocale(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Locale = "it"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a locale without a catalog")
+	}
+}
@@ -1,21 +1,248 @@
 package main
 
 import (
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gosnmp/gosnmp"
 	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
 	"github.com/sensu/sensu-go/types"
+	"io"
+	"math"
 	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Config struct {
 	sensu.PluginConfig
-	Target    string
-	Community string
-	Warning   float64
-	Critical  float64
+	Target              string
+	StdinTargets        bool
+	Concurrency         int
+	MaxConcurrentSNMP   int
+	FailFast            bool
+	SourceAddress       string
+	SocksProxy          string
+	Replay              string
+	HTTPFallbackURL     string
+	PingFirst           bool
+	PingTimeout         int
+	PingFailureState    string
+	Community           string
+	HumidityOID         string
+	HumidityTarget      string
+	HumidityCommunity   string
+	AckOnCritical       bool
+	AckOID              string
+	AckValue            int
+	WriteCommunity      string
+	RequireHumidity     bool
+	MissingHumidity     string
+	CommunityMap        map[string]string
+	CommunityMapFile    string
+	SnmpVersion         string
+	SecurityName        string
+	AuthProtocol        string
+	AuthPassphrase      string
+	PrivProtocol        string
+	PrivPassphrase      string
+	V3EngineID          string
+	TransientErrorState string
+	AutoVersionFallback bool
+	ReportSnmpVersion   bool
+	ProbeVersions       bool
+	CompareTo           string
+	Serve               string
+	PollInterval        int
+	CompareDelta        float64
+	Attempts            int
+	RereadOnZero        int
+	PollUntilStable     bool
+	StableTolerance     float64
+	StableMaxPolls      int
+	StableInterval      int
+	MaxOids             int
+	RetryOnDecodeError  bool
+	PartialOK           bool
+	Warning             float64
+	Critical            float64
+	WarningPercent      float64
+	ThresholdSchedule   []string
+	WarningRange        string
+	CriticalRange       string
+	Emergency           float64
+	SeverityKeyword     bool
+	ReportMargins       bool
+	MarginPerfData      bool
+	FallbackToInternal  bool
+	DetectUnit          bool
+	UnitOID             string
+	Unit                string
+	InternalUnit        string
+	ExternalUnit        string
+	CalibrationOffset   float64
+	Invert              bool
+	InvertMode          string
+	AbsoluteMinimum     float64
+	SensorSpreadWarning float64
+	WarnOnIdentical     bool
+	CheckFirmware       bool
+	FirmwareOID         string
+	MinFirmware         string
+	BuggyFirmware       []string
+	HealthScore         bool
+	AllowedLocations    []string
+	StripLocationPrefix string
+	StripLocationSuffix string
+	LocationAsHex       bool
+	MaintenanceWindows  []string
+	MaintenanceState    string
+	RttWarning          int
+	RttCritical         int
+	SetpointOID         string
+	DeviationWarning    float64
+	DeviationCritical   float64
+	BatteryOID          string
+	BatteryWarning      float64
+	BatteryCritical     float64
+	CO2OID              string
+	CO2Warning          int
+	CO2Critical         int
+	CheckProbeHealth    bool
+	ProbeStatusOID      string
+	CheckDoor           bool
+	DoorOID             string
+	DoorOpenState       string
+	IncludeIfstats      bool
+	IfIndex             int
+	WarnOnIfErrors      bool
+	SummaryMaxLength    int
+	Locale              string
+	LocaleNumbers       bool
+	Output              string
+	IncludeSysName      bool
+	IncludeAssetTag     bool
+	AssetTagOID         string
+	SlugLocation        bool
+	EmitHeartbeat       bool
+	EmitDuration        bool
+	ShowRaw             bool
+	DumpRawResponse     bool
+	ValidateOIDs        bool
+	UseGetNext          bool
+	DumpOptions         bool
+	Profile             string
+	ProfileFile         string
+	ExitOK              int
+	ExitWarning         int
+	ExitCritical        int
+	ExitUnknown         int
+	ResultLog           string
+	NagiosCmdFile       string
+	NagiosHost          string
+	NagiosService       string
+	Syslog              string
+	SyslogFacility      string
+	RedisURL            string
+	RedisChannel        string
+	PushgatewayURL      string
+	PushgatewayJob      string
+	PushFailureState    string
+	SplunkHECURL        string
+	SplunkHECToken      string
+	SplunkSourcetype    string
+	SplunkFailureState  string
+	NoPerfData          bool
+	SkipPerfdataBelow   float64
+	OutputMetricFormat  string
+	MetricTags          map[string]string
+	PerfMin             map[string]string
+	PerfMax             map[string]string
+	DedupeMetrics       bool
+	StateFile           string
+	ThrottleWindow      int
+	DegreesDelta        bool
+	EmaAlpha            float64
+	MinInterval         int
+	Warmup              int
+	AlertOnReboot       bool
+	IncludeMinMax       bool
+	ExternalMinOID      string
+	ExternalMaxOID      string
+	ProbeKey            string
+	ProbeKeyOID         string
+	ProbeValueOID       string
+	DiscoverSensor      bool
+	SensorTypeOID       string
+	SensorValueOID      string
+	ExpectedProbeCount  int
+	MaxProbeCount       int
+	MaxProbeCountState  string
+	ProbeCountOID       string
 }
 
+const ellipsis = "..."
+
+const (
+	locationOID    = ".1.3.6.1.2.1.1.6.0"
+	sysNameOID     = ".1.3.6.1.2.1.1.5.0"
+	uptimeOID      = ".1.3.6.1.2.1.1.3.0"
+	ifInErrorsOID  = ".1.3.6.1.2.1.2.2.1.14"
+	ifOutErrorsOID = ".1.3.6.1.2.1.2.2.1.20"
+	internalOID    = ".1.3.6.1.4.1.20916.1.7.1.1.1.1.0"
+	externalOID    = ".1.3.6.1.4.1.20916.1.7.1.2.1.1.0"
+)
+
+// stateLabels are the status words used in the check output
+var stateLabels = map[int]string{
+	sensu.CheckStateOK:       "OK",
+	sensu.CheckStateWarning:  "WARNING",
+	sensu.CheckStateCritical: "CRITICAL",
+	sensu.CheckStateUnknown:  "UNKNOWN",
+}
+
+// transientStates are the states a timeout, or missing humidity, can be
+// reported as
+var transientStates = map[string]int{
+	"warning":  sensu.CheckStateWarning,
+	"critical": sensu.CheckStateCritical,
+	"unknown":  sensu.CheckStateUnknown,
+}
+
+// alertStates are the states an open door, or a failed push, can be
+// reported as
+var alertStates = map[string]int{
+	"ok":       sensu.CheckStateOK,
+	"warning":  sensu.CheckStateWarning,
+	"critical": sensu.CheckStateCritical,
+}
+
+// severity ranks check states for worst
+var severity = map[int]int{
+	sensu.CheckStateOK:       0,
+	sensu.CheckStateWarning:  1,
+	sensu.CheckStateUnknown:  2,
+	sensu.CheckStateCritical: 3,
+}
+
+// now, sleep, stdin and stdout are swapped out by the tests
+var (
+	now              = time.Now
+	sleep            = time.Sleep
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
 var (
 	plugin = Config{
 		PluginConfig: sensu.PluginConfig{
@@ -34,6 +261,94 @@ var (
 			Usage:     "IP address of the target unit.",
 			Value:     &plugin.Target,
 		},
+		{
+			Path:      "stdin-targets",
+			Argument:  "stdin-targets",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "poll each target read from stdin, one per line, instead of target.",
+			Value:     &plugin.StdinTargets,
+		},
+		{
+			Path:      "concurrency",
+			Argument:  "concurrency",
+			Shorthand: "",
+			Default:   1,
+			Usage:     "number of stdin-targets to poll at once.",
+			Value:     &plugin.Concurrency,
+		},
+		{
+			Path:      "max-concurrent-snmp",
+			Argument:  "max-concurrent-snmp",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "most SNMP requests in flight at once across all targets, 0 for no limit.",
+			Value:     &plugin.MaxConcurrentSNMP,
+		},
+		{
+			Path:      "fail-fast",
+			Argument:  "fail-fast",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "stop the stdin-targets sweep at the first unit that can't be read, returning its state.",
+			Value:     &plugin.FailFast,
+		},
+		{
+			Path:      "source-address",
+			Argument:  "source-address",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "local IP address to send SNMP requests from.",
+			Value:     &plugin.SourceAddress,
+		},
+		{
+			Path:      "socks-proxy",
+			Argument:  "socks-proxy",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "host:port of a SOCKS5 proxy to relay the SNMP traffic through.",
+			Value:     &plugin.SocksProxy,
+		},
+		{
+			Path:      "replay",
+			Argument:  "replay",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "pcap capture of an earlier exchange to replay the unit's responses from, instead of polling it.",
+			Value:     &plugin.Replay,
+		},
+		{
+			Path:      "http-fallback-url",
+			Argument:  "http-fallback-url",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "url of the unit's JSON HTTP API to read from when SNMP can't reach it at all.",
+			Value:     &plugin.HTTPFallbackURL,
+		},
+		{
+			Path:      "ping-first",
+			Argument:  "ping-first",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "ping the unit before polling it, to tell a unit that's down from SNMP that isn't answering.",
+			Value:     &plugin.PingFirst,
+		},
+		{
+			Path:      "ping-timeout",
+			Argument:  "ping-timeout",
+			Shorthand: "",
+			Default:   1,
+			Usage:     "seconds to wait for the ping reply.",
+			Value:     &plugin.PingTimeout,
+		},
+		{
+			Path:      "ping-failure-state",
+			Argument:  "ping-failure-state",
+			Shorthand: "",
+			Default:   "critical",
+			Usage:     "state when the unit doesn't answer ping (ok, warning or critical).",
+			Value:     &plugin.PingFailureState,
+		},
 		{
 			Path:      "community",
 			Argument:  "community",
@@ -42,6 +357,288 @@ var (
 			Usage:     "SNMP community.",
 			Value:     &plugin.Community,
 		},
+		{
+			Path:      "humidity-oid",
+			Argument:  "humidity-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the humidity, in hundredths of a percent, to report alongside the temperature.",
+			Value:     &plugin.HumidityOID,
+		},
+		{
+			Path:      "humidity-target",
+			Argument:  "humidity-target",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "IP address of a separate unit to read the humidity from, defaults to the target.",
+			Value:     &plugin.HumidityTarget,
+		},
+		{
+			Path:      "humidity-community",
+			Argument:  "humidity-community",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "SNMP community of the humidity-target, defaults to community.",
+			Value:     &plugin.HumidityCommunity,
+		},
+		{
+			Path:      "ack-on-critical",
+			Argument:  "ack-on-critical",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "acknowledge the unit's alarm by setting ack-oid when the check goes critical.",
+			Value:     &plugin.AckOnCritical,
+		},
+		{
+			Path:      "ack-oid",
+			Argument:  "ack-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID set to acknowledge the unit's alarm.",
+			Value:     &plugin.AckOID,
+		},
+		{
+			Path:      "ack-value",
+			Argument:  "ack-value",
+			Shorthand: "",
+			Default:   1,
+			Usage:     "integer ack-oid is set to.",
+			Value:     &plugin.AckValue,
+		},
+		{
+			Path:      "write-community",
+			Argument:  "write-community",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "SNMP community allowed to write ack-oid, v3 writes as security-name.",
+			Value:     &plugin.WriteCommunity,
+		},
+		{
+			Path:      "require-humidity",
+			Argument:  "require-humidity",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "alert when the humidity can't be read instead of leaving it out.",
+			Value:     &plugin.RequireHumidity,
+		},
+		{
+			Path:      "missing-humidity-state",
+			Argument:  "missing-humidity-state",
+			Shorthand: "",
+			Default:   "warning",
+			Usage:     "state returned by require-humidity when the humidity can't be read (warning, critical or unknown).",
+			Value:     &plugin.MissingHumidity,
+		},
+		{
+			Path:      "community-map",
+			Argument:  "community-map",
+			Shorthand: "",
+			Default:   map[string]string{},
+			Usage:     "target=community pair giving a unit its own community, repeatable.",
+			Value:     &plugin.CommunityMap,
+		},
+		{
+			Path:      "community-map-file",
+			Argument:  "community-map-file",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "file of target=community pairs, one per line, community-map entries take precedence.",
+			Value:     &plugin.CommunityMapFile,
+		},
+		{
+			Path:      "snmp-version",
+			Argument:  "snmp-version",
+			Shorthand: "v",
+			Default:   "1",
+			Usage:     "SNMP version (1, 2c or 3).",
+			Value:     &plugin.SnmpVersion,
+		},
+		{
+			Path:      "security-name",
+			Argument:  "security-name",
+			Shorthand: "u",
+			Default:   "",
+			Usage:     "SNMPv3 security name.",
+			Value:     &plugin.SecurityName,
+		},
+		{
+			Path:      "auth-protocol",
+			Argument:  "auth-protocol",
+			Shorthand: "a",
+			Default:   "",
+			Usage:     "SNMPv3 authentication protocol (MD5, SHA, SHA224, SHA256, SHA384 or SHA512), empty for noAuthNoPriv.",
+			Value:     &plugin.AuthProtocol,
+		},
+		{
+			Path:      "auth-passphrase",
+			Argument:  "auth-passphrase",
+			Shorthand: "A",
+			Default:   "",
+			Usage:     "SNMPv3 authentication passphrase.",
+			Value:     &plugin.AuthPassphrase,
+			Secret:    true,
+		},
+		{
+			Path:      "priv-protocol",
+			Argument:  "priv-protocol",
+			Shorthand: "x",
+			Default:   "",
+			Usage:     "SNMPv3 privacy protocol (DES, AES, AES192, AES256, AES192C or AES256C), empty for no privacy.",
+			Value:     &plugin.PrivProtocol,
+		},
+		{
+			Path:      "priv-passphrase",
+			Argument:  "priv-passphrase",
+			Shorthand: "X",
+			Default:   "",
+			Usage:     "SNMPv3 privacy passphrase.",
+			Value:     &plugin.PrivPassphrase,
+			Secret:    true,
+		},
+		{
+			Path:      "v3-engine-id",
+			Argument:  "v3-engine-id",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "SNMPv3 authoritative engine ID in hex, for agents that don't answer engine discovery.",
+			Value:     &plugin.V3EngineID,
+		},
+		{
+			Path:      "transient-error-state",
+			Argument:  "transient-error-state",
+			Shorthand: "",
+			Default:   "critical",
+			Usage:     "state returned when the unit times out (warning, critical or unknown).",
+			Value:     &plugin.TransientErrorState,
+		},
+		{
+			Path:      "auto-version-fallback",
+			Argument:  "auto-version-fallback",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "retry as SNMP v2c when a v1 query times out or is refused.",
+			Value:     &plugin.AutoVersionFallback,
+		},
+		{
+			Path:      "report-snmp-version",
+			Argument:  "report-snmp-version",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "report the SNMP version that served the reading as tempager_snmp_version perfdata and snmp_version in the JSON output.",
+			Value:     &plugin.ReportSnmpVersion,
+		},
+		{
+			Path:      "probe-versions",
+			Argument:  "probe-versions",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "report which SNMP versions the unit responds to instead of checking it.",
+			Value:     &plugin.ProbeVersions,
+		},
+		{
+			Path:      "compare-to",
+			Argument:  "compare-to",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "report the differences from the JSON result saved in this file instead of checking the unit, WARNING when any are significant.",
+			Value:     &plugin.CompareTo,
+		},
+		{
+			Path:      "compare-delta",
+			Argument:  "compare-delta",
+			Shorthand: "",
+			Default:   2.0,
+			Usage:     "smallest change in a temperature, in degrees, that compare-to counts as significant.",
+			Value:     &plugin.CompareDelta,
+		},
+		{
+			Path:      "serve",
+			Argument:  "serve",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "run as an exporter instead, polling the unit every poll-interval and serving /metrics and /healthz on this address, :9781 say.",
+			Value:     &plugin.Serve,
+		},
+		{
+			Path:      "poll-interval",
+			Argument:  "poll-interval",
+			Shorthand: "",
+			Default:   60,
+			Usage:     "seconds between polls of the unit in serve mode.",
+			Value:     &plugin.PollInterval,
+		},
+		{
+			Path:      "attempts",
+			Argument:  "attempts",
+			Shorthand: "",
+			Default:   3,
+			Usage:     "number of Gets to try with retry-on-decode-error.",
+			Value:     &plugin.Attempts,
+		},
+		{
+			Path:      "reread-on-zero",
+			Argument:  "reread-on-zero",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "number of times to read the unit again when the external probe reads exactly 0, before taking it as the reading.",
+			Value:     &plugin.RereadOnZero,
+		},
+		{
+			Path:      "poll-until-stable",
+			Argument:  "poll-until-stable",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "poll the unit again until successive external readings are within stable-tolerance, for a probe still settling.",
+			Value:     &plugin.PollUntilStable,
+		},
+		{
+			Path:      "stable-tolerance",
+			Argument:  "stable-tolerance",
+			Shorthand: "",
+			Default:   0.1,
+			Usage:     "degrees successive readings can differ by and still be stable.",
+			Value:     &plugin.StableTolerance,
+		},
+		{
+			Path:      "stable-max-polls",
+			Argument:  "stable-max-polls",
+			Shorthand: "",
+			Default:   10,
+			Usage:     "most polls poll-until-stable makes before taking the last reading as it is.",
+			Value:     &plugin.StableMaxPolls,
+		},
+		{
+			Path:      "stable-interval",
+			Argument:  "stable-interval",
+			Shorthand: "",
+			Default:   5,
+			Usage:     "seconds between the polls of poll-until-stable.",
+			Value:     &plugin.StableInterval,
+		},
+		{
+			Path:      "max-oids",
+			Argument:  "max-oids",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "most OIDs to ask for in a single Get, larger requests are split, 0 for the gosnmp default.",
+			Value:     &plugin.MaxOids,
+		},
+		{
+			Path:      "retry-on-decode-error",
+			Argument:  "retry-on-decode-error",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "re-issue the Get when the response can't be decoded, UNKNOWN once the attempts run out rather than CRITICAL.",
+			Value:     &plugin.RetryOnDecodeError,
+		},
+		{
+			Path:      "partial-ok",
+			Argument:  "partial-ok",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "evaluate whatever values could be decoded when some of them can't, rather than failing the check.",
+			Value:     &plugin.PartialOK,
+		},
 		{
 			Path:      "warning",
 			Argument:  "warning",
@@ -58,92 +655,2755 @@ var (
 			Usage:     "critical threshold.",
 			Value:     &plugin.Critical,
 		},
-	}
-)
-
-func main() {
-	check := sensu.NewGoCheck(&plugin.PluginConfig, options, checkArgs, executeCheck, false)
-	check.Execute()
-}
-
-func checkArgs(event *types.Event) (int, error) {
-
-	// target is a required argument
-	if plugin.Target == "" {
-		return sensu.CheckStateCritical, fmt.Errorf("target unit must be specified.")
-	}
-
-	// target must be an IP address
-	if net.ParseIP(plugin.Target) == nil {
-		return sensu.CheckStateCritical, fmt.Errorf("target must be an IP address.")
-	}
-
-	return sensu.CheckStateOK, nil
-}
-
-func executeCheck(event *types.Event) (int, error) {
-
-	// configure the SNMP connection
-	gosnmp.Default.Target = plugin.Target
-	gosnmp.Default.Community = plugin.Community
-	gosnmp.Default.Version = gosnmp.Version1
-
-	// make the connection
-	err := gosnmp.Default.Connect()
-	if err != nil {
-		fmt.Printf("%s CRITICAL: failed to connect to tempager.\n", plugin.PluginConfig.Name)
-		return sensu.CheckStateCritical, nil
-	}
-	defer gosnmp.Default.Conn.Close()
-
-	// gather the required values (internal sensor / external sensor)
-	oids := []string{".1.3.6.1.2.1.1.6.0", ".1.3.6.1.4.1.20916.1.7.1.1.1.1.0", ".1.3.6.1.4.1.20916.1.7.1.2.1.1.0"}
-	result, err := gosnmp.Default.Get(oids)
-	if err != nil {
-		fmt.Printf("%s CRITICAL: failed to gather oids.\n", plugin.PluginConfig.Name)
-		return sensu.CheckStateCritical, nil
-	}
-
-	// validate the location oid
-	location_oid, ok := result.Variables[0].Value.([]uint8)
-	if !ok {
-		fmt.Printf("%s CRITICAL: failed to read location.\n", plugin.PluginConfig.Name)
-		return sensu.CheckStateCritical, nil
-	}
-
-	// validate the internal temperature oid
-	inttemp_oid, ok := result.Variables[1].Value.(int)
-	if !ok {
-		fmt.Printf("%s CRITICAL: failed to read internal temperature.\n", plugin.PluginConfig.Name)
-		return sensu.CheckStateCritical, nil
-	}
-
-	// validate the external temperature oid
-	exttemp_oid, ok := result.Variables[2].Value.(int)
-	if !ok {
-		fmt.Printf("%s CRITICAL: failed to read external temperature.\n", plugin.PluginConfig.Name)
-		return sensu.CheckStateCritical, nil
-	}
-
-	// convert oid values into something usable
-	location := string(location_oid)
-	internal_temperature := float64(inttemp_oid) / 100.0
-	external_temperature := float64(exttemp_oid) / 100.0
-
-	// construct the performance data
-	perfData := fmt.Sprintf("tempager_internal=%.2f, tempager_external=%.2f", internal_temperature, external_temperature)
-	t := fmt.Sprintf("%s temperature is %.2fc | %s\n", location, external_temperature, perfData)
-
-	if external_temperature > plugin.Critical {
-		fmt.Printf("%s CRITICAL: %s", plugin.PluginConfig.Name, t)
-		return sensu.CheckStateCritical, nil
-	}
-
-	if external_temperature > plugin.Warning {
-		fmt.Printf("%s WARNING: %s", plugin.PluginConfig.Name, t)
-		return sensu.CheckStateWarning, nil
-	}
-
-	fmt.Printf("%s OK: %s", plugin.PluginConfig.Name, t)
-	return sensu.CheckStateOK, nil
+		{
+			Path:      "warning-percent",
+			Argument:  "warning-percent",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "warn at this percentage of the critical threshold instead of at warning, 0 disables.",
+			Value:     &plugin.WarningPercent,
+		},
+		{
+			Path:      "threshold-schedule",
+			Argument:  "threshold-schedule",
+			Shorthand: "",
+			Default:   []string{},
+			Usage:     "\"<days> <HH:MM>-<HH:MM> <warning>/<critical>\" thresholds to use in place of warning and critical during that window, repeatable.",
+			Value:     &plugin.ThresholdSchedule,
+		},
+		{
+			Path:      "warning-range",
+			Argument:  "warning-range",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Nagios range, such as @10:20 or ~:35, to warn on in place of warning, which can't be given with it.",
+			Value:     &plugin.WarningRange,
+		},
+		{
+			Path:      "critical-range",
+			Argument:  "critical-range",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Nagios range, such as @10:20 or ~:40, to go critical on in place of critical, which can't be given with it.",
+			Value:     &plugin.CriticalRange,
+		},
+		{
+			Path:      "emergency",
+			Argument:  "emergency",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "emergency threshold, tags the CRITICAL output with [EMERGENCY] when crossed, 0 disables.",
+			Value:     &plugin.Emergency,
+		},
+		{
+			Path:      "severity-keyword",
+			Argument:  "severity-keyword",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "tag the output with info, minor, major or critical by how far past the thresholds the reading is.",
+			Value:     &plugin.SeverityKeyword,
+		},
+		{
+			Path:      "report-margins",
+			Argument:  "report-margins",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "add margin_to_warning and margin_to_critical, the degrees left before each threshold, to the JSON output.",
+			Value:     &plugin.ReportMargins,
+		},
+		{
+			Path:      "margin-perfdata",
+			Argument:  "margin-perfdata",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "also add the margins to the perfdata, requires report-margins.",
+			Value:     &plugin.MarginPerfData,
+		},
+		{
+			Path:      "fallback-to-internal",
+			Argument:  "fallback-to-internal",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "evaluate the internal sensor, with a warning, when the external probe can't be read.",
+			Value:     &plugin.FallbackToInternal,
+		},
+		{
+			Path:      "detect-unit",
+			Argument:  "detect-unit",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "read the unit the readings are reported in (C, F or K) from unit-oid and convert them to celsius.",
+			Value:     &plugin.DetectUnit,
+		},
+		{
+			Path:      "unit-oid",
+			Argument:  "unit-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the unit's reporting unit.",
+			Value:     &plugin.UnitOID,
+		},
+		{
+			Path:      "unit",
+			Argument:  "unit",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "unit the readings are reported in (c, f or k), instead of detecting it, defaults to celsius.",
+			Value:     &plugin.Unit,
+		},
+		{
+			Path:      "internal-unit",
+			Argument:  "internal-unit",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "unit the internal sensor reports in (c, f or k), overriding unit.",
+			Value:     &plugin.InternalUnit,
+		},
+		{
+			Path:      "external-unit",
+			Argument:  "external-unit",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "unit the external probe reports in (c, f or k), overriding unit.",
+			Value:     &plugin.ExternalUnit,
+		},
+		{
+			Path:      "absolute-minimum",
+			Argument:  "absolute-minimum",
+			Shorthand: "",
+			Default:   -273.15,
+			Usage:     "readings at or below this are a shorted or open probe and reported as UNKNOWN.",
+			Value:     &plugin.AbsoluteMinimum,
+		},
+		{
+			Path:      "calibration-offset",
+			Argument:  "calibration-offset",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "degrees added to both readings to correct a probe that reads high or low.",
+			Value:     &plugin.CalibrationOffset,
+		},
+		{
+			Path:      "invert",
+			Argument:  "invert",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "invert the external reading, for probes wired so the raw value runs opposite to the temperature.",
+			Value:     &plugin.Invert,
+		},
+		{
+			Path:      "invert-mode",
+			Argument:  "invert-mode",
+			Shorthand: "",
+			Default:   "negate",
+			Usage:     "how invert inverts the reading, negate or reciprocal (1/x).",
+			Value:     &plugin.InvertMode,
+		},
+		{
+			Path:      "sensor-spread-warning",
+			Argument:  "sensor-spread-warning",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "warn when internal and external readings differ by more than this, 0 disables.",
+			Value:     &plugin.SensorSpreadWarning,
+		},
+		{
+			Path:      "warn-on-identical-sensors",
+			Argument:  "warn-on-identical-sensors",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "warn when the internal and external sensors return exactly the same nonzero value.",
+			Value:     &plugin.WarnOnIdentical,
+		},
+		{
+			Path:      "check-firmware",
+			Argument:  "check-firmware",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "warn when the unit runs one of the buggy-firmware versions.",
+			Value:     &plugin.CheckFirmware,
+		},
+		{
+			Path:      "firmware-oid",
+			Argument:  "firmware-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the unit's firmware version.",
+			Value:     &plugin.FirmwareOID,
+		},
+		{
+			Path:      "min-firmware",
+			Argument:  "min-firmware",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "warn when the unit runs firmware older than this version.",
+			Value:     &plugin.MinFirmware,
+		},
+		{
+			Path:      "buggy-firmware",
+			Argument:  "buggy-firmware",
+			Shorthand: "",
+			Default:   []string{},
+			Usage:     "comma separated list of firmware versions known to report incorrect scaling.",
+			Value:     &plugin.BuggyFirmware,
+		},
+		{
+			Path:      "health-score",
+			Argument:  "health-score",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "emit a 0-100 tempager_health_score from how close the sensors are to the active critical threshold or range.",
+			Value:     &plugin.HealthScore,
+		},
+		{
+			Path:      "allowed-locations",
+			Argument:  "allowed-locations",
+			Shorthand: "",
+			Default:   []string{},
+			Usage:     "comma separated list of locations the unit may report, anything else is a WARNING.",
+			Value:     &plugin.AllowedLocations,
+		},
+		{
+			Path:      "strip-location-prefix",
+			Argument:  "strip-location-prefix",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "prefix removed from the location before it's used.",
+			Value:     &plugin.StripLocationPrefix,
+		},
+		{
+			Path:      "strip-location-suffix",
+			Argument:  "strip-location-suffix",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "suffix removed from the location before it's used.",
+			Value:     &plugin.StripLocationSuffix,
+		},
+		{
+			Path:      "location-as-hex",
+			Argument:  "location-as-hex",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "render the location as hex, as is done anyway when it isn't printable text.",
+			Value:     &plugin.LocationAsHex,
+		},
+		{
+			Path:      "maintenance-window",
+			Argument:  "maintenance-window",
+			Shorthand: "",
+			Default:   []string{},
+			Usage:     "window such as \"sat-sun 08:00-18:00\" during which temperature breaches are downgraded to maintenance-state, repeatable.",
+			Value:     &plugin.MaintenanceWindows,
+		},
+		{
+			Path:      "maintenance-state",
+			Argument:  "maintenance-state",
+			Shorthand: "",
+			Default:   "warning",
+			Usage:     "state breaches are downgraded to in a maintenance window (ok or warning).",
+			Value:     &plugin.MaintenanceState,
+		},
+		{
+			Path:      "rtt-warning",
+			Argument:  "rtt-warning",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "warn when the SNMP round trip takes longer than this many milliseconds, 0 disables.",
+			Value:     &plugin.RttWarning,
+		},
+		{
+			Path:      "rtt-critical",
+			Argument:  "rtt-critical",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "go critical when the SNMP round trip takes longer than this many milliseconds, 0 disables.",
+			Value:     &plugin.RttCritical,
+		},
+		{
+			Path:      "setpoint-oid",
+			Argument:  "setpoint-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the unit's configured setpoint, enables deviation alerting.",
+			Value:     &plugin.SetpointOID,
+		},
+		{
+			Path:      "deviation-warning",
+			Argument:  "deviation-warning",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "warn when the reading is further than this from the setpoint, 0 disables.",
+			Value:     &plugin.DeviationWarning,
+		},
+		{
+			Path:      "deviation-critical",
+			Argument:  "deviation-critical",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "go critical when the reading is further than this from the setpoint, 0 disables.",
+			Value:     &plugin.DeviationCritical,
+		},
+		{
+			Path:      "battery-oid",
+			Argument:  "battery-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the unit's battery voltage, in hundredths of a volt.",
+			Value:     &plugin.BatteryOID,
+		},
+		{
+			Path:      "battery-warning",
+			Argument:  "battery-warning",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "warn when the battery drops below this many volts, 0 disables.",
+			Value:     &plugin.BatteryWarning,
+		},
+		{
+			Path:      "battery-critical",
+			Argument:  "battery-critical",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "go critical when the battery drops below this many volts, 0 disables.",
+			Value:     &plugin.BatteryCritical,
+		},
+		{
+			Path:      "co2-oid",
+			Argument:  "co2-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the unit's CO2 reading, in ppm.",
+			Value:     &plugin.CO2OID,
+		},
+		{
+			Path:      "co2-warning",
+			Argument:  "co2-warning",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "warn when CO2 rises above this many ppm, 0 disables.",
+			Value:     &plugin.CO2Warning,
+		},
+		{
+			Path:      "co2-critical",
+			Argument:  "co2-critical",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "go critical when CO2 rises above this many ppm, 0 disables.",
+			Value:     &plugin.CO2Critical,
+		},
+		{
+			Path:      "check-probe-health",
+			Argument:  "check-probe-health",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "go critical when the external probe's status is fault or disconnected, whatever it reads.",
+			Value:     &plugin.CheckProbeHealth,
+		},
+		{
+			Path:      "probe-status-oid",
+			Argument:  "probe-status-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the external probe's status (ok, fault or disconnected).",
+			Value:     &plugin.ProbeStatusOID,
+		},
+		{
+			Path:      "check-door",
+			Argument:  "check-door",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "report whether the door contact is open or closed.",
+			Value:     &plugin.CheckDoor,
+		},
+		{
+			Path:      "door-oid",
+			Argument:  "door-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the door contact, open or closed, or non-zero when open.",
+			Value:     &plugin.DoorOID,
+		},
+		{
+			Path:      "door-open-state",
+			Argument:  "door-open-state",
+			Shorthand: "",
+			Default:   "ok",
+			Usage:     "state returned by check-door when the door is open (ok, warning or critical).",
+			Value:     &plugin.DoorOpenState,
+		},
+		{
+			Path:      "include-ifstats",
+			Argument:  "include-ifstats",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "include the management interface's ifInErrorsOID and ifOutErrorsOID in the perfdata.",
+			Value:     &plugin.IncludeIfstats,
+		},
+		{
+			Path:      "if-index",
+			Argument:  "if-index",
+			Shorthand: "",
+			Default:   1,
+			Usage:     "ifIndex of the unit's management interface.",
+			Value:     &plugin.IfIndex,
+		},
+		{
+			Path:      "warn-on-if-errors",
+			Argument:  "warn-on-if-errors",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "warn when the interface error counters have gone up since the last poll, requires state-file.",
+			Value:     &plugin.WarnOnIfErrors,
+		},
+		{
+			Path:      "summary-max-length",
+			Argument:  "summary-max-length",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "truncate the summary to this many characters, 0 disables truncation.",
+			Value:     &plugin.SummaryMaxLength,
+		},
+		{
+			Path:      "locale",
+			Argument:  "locale",
+			Shorthand: "",
+			Default:   "en",
+			Usage:     "language of the status word and reading in the summary (en, de, fr or es).",
+			Value:     &plugin.Locale,
+		},
+		{
+			Path:      "locale-numbers",
+			Argument:  "locale-numbers",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "use the locale's decimal and thousands separators for the numbers in the summary, perfdata is unaffected.",
+			Value:     &plugin.LocaleNumbers,
+		},
+		{
+			Path:      "output",
+			Argument:  "output",
+			Shorthand: "o",
+			Default:   "text",
+			Usage:     "output format (text, json or json-gz for gzip compressed json).",
+			Value:     &plugin.Output,
+		},
+		{
+			Path:      "include-sysname",
+			Argument:  "include-sysname",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "also read the unit's sysName and include it in the output.",
+			Value:     &plugin.IncludeSysName,
+		},
+		{
+			Path:      "include-asset-tag",
+			Argument:  "include-asset-tag",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "also read the unit's asset tag or coordinates and include them in the JSON output.",
+			Value:     &plugin.IncludeAssetTag,
+		},
+		{
+			Path:      "asset-tag-oid",
+			Argument:  "asset-tag-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the unit's asset tag or coordinates.",
+			Value:     &plugin.AssetTagOID,
+		},
+		{
+			Path:      "slug-location",
+			Argument:  "slug-location",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "prefix metric names with the location, lowercased and with anything but letters and digits replaced by underscores.",
+			Value:     &plugin.SlugLocation,
+		},
+		{
+			Path:      "emit-heartbeat",
+			Argument:  "emit-heartbeat",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "add a tempager_up metric, 1 when the unit was reached and 0 when it couldn't be.",
+			Value:     &plugin.EmitHeartbeat,
+		},
+		{
+			Path:      "emit-duration",
+			Argument:  "emit-duration",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "add a check_duration_ms metric, how long the whole check took.",
+			Value:     &plugin.EmitDuration,
+		},
+		{
+			Path:      "show-raw",
+			Argument:  "show-raw",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "include the raw values returned by the unit in the JSON output.",
+			Value:     &plugin.ShowRaw,
+		},
+		{
+			Path:      "dump-raw-response",
+			Argument:  "dump-raw-response",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "print the hex of each value in the unit's response to stderr, for vendor support.",
+			Value:     &plugin.DumpRawResponse,
+		},
+		{
+			Path:      "validate-oid-responses",
+			Argument:  "validate-oid-responses",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "go unknown, listing the mismatches, when any value of the standard Get isn't of the type the MIB gives it.",
+			Value:     &plugin.ValidateOIDs,
+		},
+		{
+			Path:      "use-getnext",
+			Argument:  "use-getnext",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "fetch any value of the standard Get the agent has no .0 instance of with a GetNext.",
+			Value:     &plugin.UseGetNext,
+		},
+		{
+			Path:      "exit-ok",
+			Argument:  "exit-ok",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "exit code returned for OK.",
+			Value:     &plugin.ExitOK,
+		},
+		{
+			Path:      "exit-warning",
+			Argument:  "exit-warning",
+			Shorthand: "",
+			Default:   1,
+			Usage:     "exit code returned for WARNING.",
+			Value:     &plugin.ExitWarning,
+		},
+		{
+			Path:      "exit-critical",
+			Argument:  "exit-critical",
+			Shorthand: "",
+			Default:   2,
+			Usage:     "exit code returned for CRITICAL.",
+			Value:     &plugin.ExitCritical,
+		},
+		{
+			Path:      "exit-unknown",
+			Argument:  "exit-unknown",
+			Shorthand: "",
+			Default:   3,
+			Usage:     "exit code returned for UNKNOWN.",
+			Value:     &plugin.ExitUnknown,
+		},
+		{
+			Path:      "",
+			Argument:  "dump-options",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "print the plugin options as JSON and exit.",
+			Value:     &plugin.DumpOptions,
+		},
+		{
+			Path:      "profile",
+			Argument:  "profile",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "named profile from the profile-file to take option values from, options given here win.",
+			Value:     &plugin.Profile,
+		},
+		{
+			Path:      "profile-file",
+			Argument:  "profile-file",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "file of [profile] sections of option = value lines.",
+			Value:     &plugin.ProfileFile,
+		},
+		{
+			Path:      "result-log",
+			Argument:  "result-log",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "file to append a timestamped line with the result of every run to.",
+			Value:     &plugin.ResultLog,
+		},
+		{
+			Path:      "nagios-cmd-file",
+			Argument:  "nagios-cmd-file",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "also submit the result as a passive check to the Nagios external command file at this path.",
+			Value:     &plugin.NagiosCmdFile,
+		},
+		{
+			Path:      "nagios-host",
+			Argument:  "nagios-host",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Nagios host the passive result is for, defaults to the target.",
+			Value:     &plugin.NagiosHost,
+		},
+		{
+			Path:      "nagios-service",
+			Argument:  "nagios-service",
+			Shorthand: "",
+			Default:   "tempager",
+			Usage:     "Nagios service the passive result is for.",
+			Value:     &plugin.NagiosService,
+		},
+		{
+			Path:      "syslog",
+			Argument:  "syslog",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "also send the result to this syslog server, host:port over UDP or local for the local syslog socket.",
+			Value:     &plugin.Syslog,
+		},
+		{
+			Path:      "syslog-facility",
+			Argument:  "syslog-facility",
+			Shorthand: "",
+			Default:   "daemon",
+			Usage:     "syslog facility the result is sent with.",
+			Value:     &plugin.SyslogFacility,
+		},
+		{
+			Path:      "redis-url",
+			Argument:  "redis-url",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "also publish the JSON result to the redis server at this redis://[:password@]host:port[/db] url.",
+			Value:     &plugin.RedisURL,
+		},
+		{
+			Path:      "redis-channel",
+			Argument:  "redis-channel",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "redis channel the result is published to.",
+			Value:     &plugin.RedisChannel,
+		},
+		{
+			Path:      "pushgateway-url",
+			Argument:  "pushgateway-url",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "also push the metrics to the Prometheus Pushgateway at this url.",
+			Value:     &plugin.PushgatewayURL,
+		},
+		{
+			Path:      "pushgateway-job",
+			Argument:  "pushgateway-job",
+			Shorthand: "",
+			Default:   "tempager",
+			Usage:     "job the metrics are pushed under, grouped by target.",
+			Value:     &plugin.PushgatewayJob,
+		},
+		{
+			Path:      "push-failure-state",
+			Argument:  "push-failure-state",
+			Shorthand: "",
+			Default:   "ok",
+			Usage:     "state returned when the metrics can't be pushed (ok, warning or critical), the failure is noted in the summary either way.",
+			Value:     &plugin.PushFailureState,
+		},
+		{
+			Path:      "splunk-hec-url",
+			Argument:  "splunk-hec-url",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "also send the JSON result to the Splunk HTTP Event Collector at this url.",
+			Value:     &plugin.SplunkHECURL,
+		},
+		{
+			Path:      "splunk-hec-token",
+			Argument:  "splunk-hec-token",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "token the Splunk HTTP Event Collector accepts events with.",
+			Value:     &plugin.SplunkHECToken,
+		},
+		{
+			Path:      "splunk-sourcetype",
+			Argument:  "splunk-sourcetype",
+			Shorthand: "",
+			Default:   "tempager:result",
+			Usage:     "sourcetype of the events sent to Splunk.",
+			Value:     &plugin.SplunkSourcetype,
+		},
+		{
+			Path:      "splunk-failure-state",
+			Argument:  "splunk-failure-state",
+			Shorthand: "",
+			Default:   "warning",
+			Usage:     "state returned when the result can't be sent to Splunk (ok, warning or critical), the failure is noted in the summary either way.",
+			Value:     &plugin.SplunkFailureState,
+		},
+		{
+			Path:      "no-perfdata",
+			Argument:  "no-perfdata",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "leave the perfdata out of the text output.",
+			Value:     &plugin.NoPerfData,
+		},
+		{
+			Path:      "skip-perfdata-if-ok-below",
+			Argument:  "skip-perfdata-if-ok-below",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "leave the extended perfdata out of an OK when the temperature is below this, the checks still run, 0 disables.",
+			Value:     &plugin.SkipPerfdataBelow,
+		},
+		{
+			Path:      "output-metric-format",
+			Argument:  "output-metric-format",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "output metrics in this Sensu output_metric_format (nagios_perfdata, graphite_plaintext, opentsdb_line, influxdb_line or prometheus_text).",
+			Value:     &plugin.OutputMetricFormat,
+		},
+		{
+			Path:      "metric-tag",
+			Argument:  "metric-tag",
+			Shorthand: "",
+			Default:   map[string]string{},
+			Usage:     "key=value tag added to every metric point in the output-metric-format, repeatable.",
+			Value:     &plugin.MetricTags,
+		},
+		{
+			Path:      "perf-min",
+			Argument:  "perf-min",
+			Shorthand: "",
+			Default:   map[string]string{},
+			Usage:     "metric=value giving the min field of that metric's perfdata, repeatable.",
+			Value:     &plugin.PerfMin,
+		},
+		{
+			Path:      "perf-max",
+			Argument:  "perf-max",
+			Shorthand: "",
+			Default:   map[string]string{},
+			Usage:     "metric=value giving the max field of that metric's perfdata, repeatable.",
+			Value:     &plugin.PerfMax,
+		},
+		{
+			Path:      "dedupe-metrics",
+			Argument:  "dedupe-metrics",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "collapse neighbouring probe readings with the same value into one metric and a count.",
+			Value:     &plugin.DedupeMetrics,
+		},
+		{
+			Path:      "state-file",
+			Argument:  "state-file",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "file used to keep state between runs.",
+			Value:     &plugin.StateFile,
+		},
+		{
+			Path:      "throttle-window",
+			Argument:  "throttle-window",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "seconds during which a repeated identical CRITICAL is abbreviated, requires state-file.",
+			Value:     &plugin.ThrottleWindow,
+		},
+		{
+			Path:      "degrees-delta",
+			Argument:  "degrees-delta",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "add the change in external temperature since the last run to the perfdata, requires state-file.",
+			Value:     &plugin.DegreesDelta,
+		},
+		{
+			Path:      "ema-alpha",
+			Argument:  "ema-alpha",
+			Shorthand: "",
+			Default:   0.0,
+			Usage:     "evaluate thresholds against an exponential moving average of the external temperature with this weight for the newest reading, in (0,1], requires state-file.",
+			Value:     &plugin.EmaAlpha,
+		},
+		{
+			Path:      "min-interval",
+			Argument:  "min-interval",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "seconds that must pass between polls of the unit, requires a state-file.",
+			Value:     &plugin.MinInterval,
+		},
+		{
+			Path:      "warmup",
+			Argument:  "warmup",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "seconds after the unit boots during which readings are ignored, 0 disables.",
+			Value:     &plugin.Warmup,
+		},
+		{
+			Path:      "alert-on-reboot",
+			Argument:  "alert-on-reboot",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "warn when the unit's uptime is lower than at the previous poll, requires a state-file.",
+			Value:     &plugin.AlertOnReboot,
+		},
+		{
+			Path:      "include-minmax",
+			Argument:  "include-minmax",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "emit the external probe's recorded min/max as tempager_external_min/max perfdata.",
+			Value:     &plugin.IncludeMinMax,
+		},
+		{
+			Path:      "external-min-oid",
+			Argument:  "external-min-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the external probe's recorded minimum, used by include-minmax.",
+			Value:     &plugin.ExternalMinOID,
+		},
+		{
+			Path:      "external-max-oid",
+			Argument:  "external-max-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the external probe's recorded maximum, used by include-minmax.",
+			Value:     &plugin.ExternalMaxOID,
+		},
+		{
+			Path:      "probe-key",
+			Argument:  "probe-key",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "read the external temperature from the sensor table row with this key (e.g. a probe UUID).",
+			Value:     &plugin.ProbeKey,
+		},
+		{
+			Path:      "probe-key-oid",
+			Argument:  "probe-key-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the sensor table column holding the probe keys.",
+			Value:     &plugin.ProbeKeyOID,
+		},
+		{
+			Path:      "probe-value-oid",
+			Argument:  "probe-value-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the sensor table column holding the probe temperatures.",
+			Value:     &plugin.ProbeValueOID,
+		},
+		{
+			Path:      "discover-sensor",
+			Argument:  "discover-sensor",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "find the external temperature sensor's index in the sensor table rather than assuming it.",
+			Value:     &plugin.DiscoverSensor,
+		},
+		{
+			Path:      "sensor-type-oid",
+			Argument:  "sensor-type-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the sensor table column holding the sensor types, used by discover-sensor.",
+			Value:     &plugin.SensorTypeOID,
+		},
+		{
+			Path:      "sensor-value-oid",
+			Argument:  "sensor-value-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the sensor table column holding the sensor values, used by discover-sensor.",
+			Value:     &plugin.SensorValueOID,
+		},
+		{
+			Path:      "expected-probe-count",
+			Argument:  "expected-probe-count",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "warn when the unit reports a different number of probes, 0 disables.",
+			Value:     &plugin.ExpectedProbeCount,
+		},
+		{
+			Path:      "max-probe-count",
+			Argument:  "max-probe-count",
+			Shorthand: "",
+			Default:   0,
+			Usage:     "alert when the unit reports more probes than this, an unexpected probe being possible tampering, 0 disables.",
+			Value:     &plugin.MaxProbeCount,
+		},
+		{
+			Path:      "max-probe-count-state",
+			Argument:  "max-probe-count-state",
+			Shorthand: "",
+			Default:   "critical",
+			Usage:     "state when the unit reports more than max-probe-count probes (ok, warning or critical).",
+			Value:     &plugin.MaxProbeCountState,
+		},
+		{
+			Path:      "probe-count-oid",
+			Argument:  "probe-count-oid",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "OID of the unit's temperature probe count, the temperature rows of the sensor table are counted instead when it's not set.",
+			Value:     &plugin.ProbeCountOID,
+		},
+	}
+)
+
+func main() {
+	check := sensu.NewGoCheck(&plugin.PluginConfig, options, checkArgs, executeCheck, false)
+	check.Execute()
+}
+
+func checkArgs(event *types.Event) (int, error) {
+	state, err := validateArgs(event)
+	if err != nil {
+		return state, &ErrConfig{Err: err}
+	}
+	return state, nil
+}
+
+// validateArgs checks the plugin options make sense together.
+func validateArgs(event *types.Event) (int, error) {
+
+	// nothing else matters when only listing the options
+	if plugin.DumpOptions {
+		return sensu.CheckStateOK, nil
+	}
+
+	// a profile fills in whatever wasn't given, before any of it is checked
+	if plugin.Profile != "" {
+		if plugin.ProfileFile == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("profile requires profile-file.")
+		}
+		if err := applyProfile(plugin.ProfileFile, plugin.Profile); err != nil {
+			return sensu.CheckStateCritical, err
+		}
+	}
+
+	// a sweep needs at least one worker
+	if plugin.Concurrency < 1 {
+		return sensu.CheckStateCritical, fmt.Errorf("concurrency must be at least 1.")
+	}
+	if plugin.MaxConcurrentSNMP < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("max-concurrent-snmp must not be negative.")
+	}
+
+	// targets either come from stdin, where they're polled concurrency at a
+	// time, or from target
+	if plugin.StdinTargets {
+		if plugin.Target != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("target and stdin-targets are mutually exclusive.")
+		}
+		if plugin.StateFile != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("state-file can't be used with stdin-targets.")
+		}
+		if plugin.ProbeVersions {
+			return sensu.CheckStateCritical, fmt.Errorf("probe-versions can't be used with stdin-targets.")
+		}
+		if plugin.Replay != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("replay can't be used with stdin-targets.")
+		}
+		if plugin.CompareTo != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("compare-to can't be used with stdin-targets.")
+		}
+		if plugin.HTTPFallbackURL != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("http-fallback-url can't be used with stdin-targets.")
+		}
+		if plugin.Serve != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("serve can't be used with stdin-targets.")
+		}
+	} else {
+		if plugin.Concurrency > 1 {
+			return sensu.CheckStateCritical, fmt.Errorf("concurrency requires stdin-targets.")
+		}
+		if plugin.FailFast {
+			return sensu.CheckStateCritical, fmt.Errorf("fail-fast requires stdin-targets.")
+		}
+
+		// target is a required argument
+		if plugin.Target == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("target unit must be specified.")
+		}
+
+		// target must be an IP address
+		if net.ParseIP(plugin.Target) == nil {
+			return sensu.CheckStateCritical, fmt.Errorf("target must be an IP address.")
+		}
+	}
+
+	// as must the source address, when given
+	if plugin.SourceAddress != "" && net.ParseIP(plugin.SourceAddress) == nil {
+		return sensu.CheckStateCritical, fmt.Errorf("source-address must be an IP address.")
+	}
+
+	// a separate humidity unit is still an IP, and needs the OID to read
+	if plugin.HumidityTarget != "" {
+		if net.ParseIP(plugin.HumidityTarget) == nil {
+			return sensu.CheckStateCritical, fmt.Errorf("humidity-target must be an IP address.")
+		}
+		if plugin.HumidityOID == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("humidity-target requires humidity-oid.")
+		}
+	}
+
+	// as does insisting on the humidity, whose absence maps onto a real state
+	if plugin.RequireHumidity {
+		if plugin.HumidityOID == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("require-humidity requires humidity-oid.")
+		}
+		if _, ok := transientStates[plugin.MissingHumidity]; !ok {
+			return sensu.CheckStateCritical, fmt.Errorf("missing-humidity-state must be warning, critical or unknown.")
+		}
+	}
+
+	// per-unit communities are picked by IP, the file fills in whatever the
+	// flags didn't give
+	if plugin.CommunityMapFile != "" {
+		communities, err := readCommunityMap(plugin.CommunityMapFile)
+		if err != nil {
+			return sensu.CheckStateCritical, err
+		}
+		for target, community := range plugin.CommunityMap {
+			communities[target] = community
+		}
+		plugin.CommunityMap = communities
+	}
+	for target := range plugin.CommunityMap {
+		if net.ParseIP(target) == nil {
+			return sensu.CheckStateCritical, fmt.Errorf("community-map targets must be IP addresses.")
+		}
+	}
+
+	// the proxy has to be somewhere we can dial
+	if plugin.SocksProxy != "" {
+		if _, err := socksAddress(plugin.SocksProxy); err != nil {
+			return sensu.CheckStateCritical, fmt.Errorf("socks-proxy must be host:port.")
+		}
+	}
+
+	// snmp-version must be one we know how to speak
+	if _, ok := snmpVersions[plugin.SnmpVersion]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("snmp-version must be one of 1, 2c or 3.")
+	}
+
+	// v3 has its own set of requirements
+	if plugin.SnmpVersion == "3" {
+		if err := checkV3Args(); err != nil {
+			return sensu.CheckStateCritical, err
+		}
+	}
+
+	// an engine ID is only part of v3
+	if plugin.V3EngineID != "" && plugin.SnmpVersion != "3" {
+		return sensu.CheckStateCritical, fmt.Errorf("v3-engine-id requires snmp-version 3.")
+	}
+
+	// there's only somewhere to fall back to from v1
+	if plugin.AutoVersionFallback && plugin.SnmpVersion != "1" {
+		return sensu.CheckStateCritical, fmt.Errorf("auto-version-fallback requires snmp-version 1.")
+	}
+
+	// timeouts can only map onto a real state
+	if _, ok := transientStates[plugin.TransientErrorState]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("transient-error-state must be warning, critical or unknown.")
+	}
+
+	// there has to be at least the one Get
+	if plugin.Attempts < 1 {
+		return sensu.CheckStateCritical, fmt.Errorf("attempts must be at least 1.")
+	}
+	if plugin.RereadOnZero < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("reread-on-zero must not be negative.")
+	}
+
+	// stable takes two readings to tell, and some drift to allow
+	if plugin.PollUntilStable {
+		if plugin.StableTolerance <= 0 {
+			return sensu.CheckStateCritical, fmt.Errorf("stable-tolerance must be greater than 0.")
+		}
+		if plugin.StableMaxPolls < 2 {
+			return sensu.CheckStateCritical, fmt.Errorf("stable-max-polls must be at least 2.")
+		}
+		if plugin.StableInterval < 0 {
+			return sensu.CheckStateCritical, fmt.Errorf("stable-interval must not be negative.")
+		}
+	}
+
+	// a split can't go below one OID a request
+	if plugin.MaxOids < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("max-oids must not be negative.")
+	}
+
+	// a warning derived from critical has to come before it
+	if plugin.WarningPercent != 0 {
+		if plugin.WarningPercent <= 0 || plugin.WarningPercent >= 100 {
+			return sensu.CheckStateCritical, fmt.Errorf("warning-percent must be above 0 and below 100.")
+		}
+		if plugin.Critical <= 0 {
+			return sensu.CheckStateCritical, fmt.Errorf("warning-percent requires a critical threshold above 0.")
+		}
+	}
+
+	// an emergency is worse than a critical
+	if plugin.Emergency != 0 && plugin.Emergency <= plugin.Critical {
+		return sensu.CheckStateCritical, fmt.Errorf("emergency threshold must be above the critical threshold.")
+	}
+
+	// the asset tag lives wherever the unit's firmware puts it
+	if plugin.IncludeAssetTag && plugin.AssetTagOID == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("include-asset-tag requires asset-tag-oid.")
+	}
+
+	// a reading is only ever inverted one of two ways
+	if plugin.InvertMode != "negate" && plugin.InvertMode != "reciprocal" {
+		return sensu.CheckStateCritical, fmt.Errorf("invert-mode must be negate or reciprocal.")
+	}
+
+	// the perfdata margins are an extra on the reported ones
+	if plugin.MarginPerfData && !plugin.ReportMargins {
+		return sensu.CheckStateCritical, fmt.Errorf("margin-perfdata requires report-margins.")
+	}
+
+	// a negative spread makes no sense
+	if plugin.SensorSpreadWarning < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("sensor-spread-warning must not be negative.")
+	}
+
+	// the reporting unit has to be read from somewhere
+	if plugin.DetectUnit && plugin.UnitOID == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("detect-unit requires unit-oid.")
+	}
+	if plugin.DetectUnit && plugin.Unit != "" {
+		return sensu.CheckStateCritical, fmt.Errorf("unit and detect-unit are mutually exclusive.")
+	}
+	for _, unit := range []string{plugin.Unit, plugin.InternalUnit, plugin.ExternalUnit} {
+		if unit != "" && unit != "c" && unit != "f" && unit != "k" {
+			return sensu.CheckStateCritical, fmt.Errorf("unit, internal-unit and external-unit must be c, f or k.")
+		}
+	}
+
+	// there's nothing to check the firmware against without both
+	if plugin.CheckFirmware && (plugin.FirmwareOID == "" || len(plugin.BuggyFirmware) == 0) {
+		return sensu.CheckStateCritical, fmt.Errorf("check-firmware requires firmware-oid and buggy-firmware.")
+	}
+	if plugin.MinFirmware != "" {
+		if plugin.FirmwareOID == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("min-firmware requires firmware-oid.")
+		}
+		if _, ok := parseFirmwareVersion(plugin.MinFirmware); !ok {
+			return sensu.CheckStateCritical, fmt.Errorf("min-firmware must be a version such as 2.1.0.")
+		}
+	}
+
+	// the score is the headroom left below critical
+	if plugin.HealthScore && plugin.Critical <= 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("health-score requires a critical threshold above 0.")
+	}
+
+	// nor a negative warmup
+	if plugin.Warmup < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("warmup must not be negative.")
+	}
+
+	// as do the scheduled thresholds
+	for _, s := range plugin.ThresholdSchedule {
+		if _, err := parseThresholdSchedule(s); err != nil {
+			return sensu.CheckStateCritical, err
+		}
+	}
+
+	// and the ranges, which replace the plain thresholds rather than
+	// adding to them
+	if plugin.WarningRange != "" {
+		if _, err := parseRange(plugin.WarningRange); err != nil {
+			return sensu.CheckStateCritical, fmt.Errorf("warning-range %v", err)
+		}
+		if changed(&plugin.Warning) {
+			return sensu.CheckStateCritical, fmt.Errorf("warning and warning-range can't be used together.")
+		}
+	}
+	if plugin.CriticalRange != "" {
+		if _, err := parseRange(plugin.CriticalRange); err != nil {
+			return sensu.CheckStateCritical, fmt.Errorf("critical-range %v", err)
+		}
+		if changed(&plugin.Critical) {
+			return sensu.CheckStateCritical, fmt.Errorf("critical and critical-range can't be used together.")
+		}
+	}
+
+	// maintenance windows have to make sense up front, not at 3am
+	for _, s := range plugin.MaintenanceWindows {
+		if _, err := parseMaintenanceWindow(s); err != nil {
+			return sensu.CheckStateCritical, err
+		}
+	}
+	if _, ok := maintenanceStates[plugin.MaintenanceState]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("maintenance-state must be ok or warning.")
+	}
+
+	// round trip thresholds are durations
+	if plugin.RttWarning < 0 || plugin.RttCritical < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("rtt-warning and rtt-critical must not be negative.")
+	}
+
+	// deviations are distances
+	if plugin.DeviationWarning < 0 || plugin.DeviationCritical < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("deviation-warning and deviation-critical must not be negative.")
+	}
+
+	// a battery sags, so critical is the lower of the two
+	if plugin.BatteryWarning < 0 || plugin.BatteryCritical < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("battery-warning and battery-critical must not be negative.")
+	}
+	if (plugin.BatteryWarning > 0 || plugin.BatteryCritical > 0) && plugin.BatteryOID == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("battery thresholds require battery-oid.")
+	}
+	if plugin.BatteryWarning > 0 && plugin.BatteryCritical > plugin.BatteryWarning {
+		return sensu.CheckStateCritical, fmt.Errorf("battery-critical must not be above battery-warning.")
+	}
+
+	// CO2 is the other way round, critical is the higher
+	if plugin.CO2Warning < 0 || plugin.CO2Critical < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("co2-warning and co2-critical must not be negative.")
+	}
+	if (plugin.CO2Warning > 0 || plugin.CO2Critical > 0) && plugin.CO2OID == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("co2 thresholds require co2-oid.")
+	}
+	if plugin.CO2Critical > 0 && plugin.CO2Warning > plugin.CO2Critical {
+		return sensu.CheckStateCritical, fmt.Errorf("co2-warning must not be above co2-critical.")
+	}
+
+	// the probe's status is its own column
+	if plugin.CheckProbeHealth && plugin.ProbeStatusOID == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("check-probe-health requires probe-status-oid.")
+	}
+
+	// as does the door contact, and an open door can only map onto a real
+	// state
+	if plugin.CheckDoor && plugin.DoorOID == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("check-door requires door-oid.")
+	}
+	if _, ok := alertStates[plugin.DoorOpenState]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("door-open-state must be ok, warning or critical.")
+	}
+
+	// interface errors only go up from one poll to the next if the last
+	// poll's counters were kept
+	if plugin.IfIndex < 1 {
+		return sensu.CheckStateCritical, fmt.Errorf("if-index must be at least 1.")
+	}
+	if plugin.WarnOnIfErrors && (!plugin.IncludeIfstats || plugin.StateFile == "") {
+		return sensu.CheckStateCritical, fmt.Errorf("warn-on-if-errors requires include-ifstats and a state-file.")
+	}
+
+	// the unit is only ever written to when asked, with a community that
+	// can, and never while replaying a capture
+	if plugin.AckOnCritical {
+		if plugin.AckOID == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("ack-on-critical requires ack-oid.")
+		}
+		if plugin.WriteCommunity == "" && plugin.SnmpVersion != "3" {
+			return sensu.CheckStateCritical, fmt.Errorf("ack-on-critical requires write-community.")
+		}
+		if plugin.Replay != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("ack-on-critical can't be used with replay.")
+		}
+	}
+
+	// validating the responses is there to catch what the GetNext would
+	// work around
+	if plugin.UseGetNext && plugin.ValidateOIDs {
+		return sensu.CheckStateCritical, fmt.Errorf("use-getnext can't be used with validate-oid-responses.")
+	}
+
+	// a ping doesn't go through the proxy, and there's no unit to ping in a
+	// replay
+	if plugin.PingFirst {
+		if plugin.PingTimeout < 1 {
+			return sensu.CheckStateCritical, fmt.Errorf("ping-timeout must be at least 1.")
+		}
+		if plugin.SocksProxy != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("ping-first can't be used with socks-proxy.")
+		}
+		if plugin.Replay != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("ping-first can't be used with replay.")
+		}
+	}
+	if _, ok := alertStates[plugin.PingFailureState]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("ping-failure-state must be ok, warning or critical.")
+	}
+
+	// a keyed probe needs to know where the table is
+	if plugin.ProbeKey != "" && (plugin.ProbeKeyOID == "" || plugin.ProbeValueOID == "") {
+		return sensu.CheckStateCritical, fmt.Errorf("probe-key requires probe-key-oid and probe-value-oid.")
+	}
+
+	// so does discovery, which picks the row itself
+	if plugin.DiscoverSensor {
+		if plugin.SensorTypeOID == "" || plugin.SensorValueOID == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("discover-sensor requires sensor-type-oid and sensor-value-oid.")
+		}
+		if plugin.ProbeKey != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("discover-sensor and probe-key are mutually exclusive.")
+		}
+	}
+
+	// the probes are counted from the unit's own count or its sensor table
+	if plugin.ExpectedProbeCount < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("expected-probe-count must not be negative.")
+	}
+	if plugin.ExpectedProbeCount > 0 && plugin.ProbeCountOID == "" && !plugin.DiscoverSensor && plugin.ProbeKey == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("expected-probe-count requires probe-count-oid, discover-sensor or probe-key.")
+	}
+	if plugin.MaxProbeCount < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("max-probe-count must not be negative.")
+	}
+	if plugin.MaxProbeCount > 0 && plugin.ProbeCountOID == "" && !plugin.DiscoverSensor && plugin.ProbeKey == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("max-probe-count requires probe-count-oid, discover-sensor or probe-key.")
+	}
+	if _, ok := alertStates[plugin.MaxProbeCountState]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("max-probe-count-state must be ok, warning or critical.")
+	}
+
+	// min/max live wherever the firmware keeps them
+	if plugin.IncludeMinMax && (plugin.ExternalMinOID == "" || plugin.ExternalMaxOID == "") {
+		return sensu.CheckStateCritical, fmt.Errorf("include-minmax requires external-min-oid and external-max-oid.")
+	}
+
+	// a baseline is compared to one fresh reading of the unit
+	if plugin.CompareTo != "" {
+		if plugin.ProbeVersions {
+			return sensu.CheckStateCritical, fmt.Errorf("compare-to can't be used with probe-versions.")
+		}
+		if plugin.CompareDelta <= 0 {
+			return sensu.CheckStateCritical, fmt.Errorf("compare-delta must be positive.")
+		}
+	}
+
+	// serving keeps polling the one unit, there's nothing else to do
+	if plugin.Serve != "" {
+		if plugin.ProbeVersions || plugin.CompareTo != "" {
+			return sensu.CheckStateCritical, fmt.Errorf("serve can't be used with probe-versions or compare-to.")
+		}
+		if plugin.PollInterval < 1 {
+			return sensu.CheckStateCritical, fmt.Errorf("poll-interval must be at least 1.")
+		}
+	}
+
+	// exit codes are a single byte
+	for _, code := range []int{plugin.ExitOK, plugin.ExitWarning, plugin.ExitCritical, plugin.ExitUnknown} {
+		if code < 0 || code > 255 {
+			return sensu.CheckStateCritical, fmt.Errorf("exit codes must be between 0 and 255.")
+		}
+	}
+
+	// summary-max-length can't be negative
+	if plugin.SummaryMaxLength < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("summary-max-length must not be negative.")
+	}
+
+	// output is either for people or for machines
+	if plugin.Output != "text" && plugin.Output != "json" && plugin.Output != "json-gz" {
+		return sensu.CheckStateCritical, fmt.Errorf("output must be text, json or json-gz.")
+	}
+
+	// a passive result has to name the service, and the fields are split on
+	// semicolons
+	if plugin.NagiosCmdFile != "" {
+		if plugin.NagiosService == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("nagios-cmd-file requires nagios-service.")
+		}
+		if strings.ContainsAny(plugin.NagiosHost+plugin.NagiosService, ";\n") {
+			return sensu.CheckStateCritical, fmt.Errorf("nagios-host and nagios-service must not contain semicolons or newlines.")
+		}
+	}
+
+	// the summary can only be worded in a language in the catalog
+	if _, ok := catalog[plugin.Locale]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("locale must be en, de, fr or es.")
+	}
+
+	// syslog needs somewhere to send to and a facility it knows
+	if plugin.Syslog != "" {
+		if err := checkSyslogAddress(plugin.Syslog); err != nil {
+			return sensu.CheckStateCritical, err
+		}
+		if _, ok := syslogFacilities[plugin.SyslogFacility]; !ok {
+			return sensu.CheckStateCritical, fmt.Errorf("syslog-facility must be a syslog facility such as daemon or local0.")
+		}
+	}
+
+	// as does redis, and a channel to publish to
+	if plugin.RedisURL != "" {
+		if _, err := parseRedisURL(plugin.RedisURL); err != nil {
+			return sensu.CheckStateCritical, err
+		}
+		if plugin.RedisChannel == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("redis-url requires redis-channel.")
+		}
+	}
+
+	// as does the pushgateway, and a failed push can only map onto a real
+	// state
+	if plugin.PushgatewayURL != "" {
+		if u, err := url.Parse(plugin.PushgatewayURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("pushgateway-url must be an http or https url.")
+		}
+		if plugin.PushgatewayJob == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("pushgateway-url requires pushgateway-job.")
+		}
+	}
+	if _, ok := alertStates[plugin.PushFailureState]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("push-failure-state must be ok, warning or critical.")
+	}
+
+	// as does the Splunk HEC, which won't take an event without a token
+	if plugin.SplunkHECURL != "" {
+		if u, err := url.Parse(plugin.SplunkHECURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("splunk-hec-url must be an http or https url.")
+		}
+		if plugin.SplunkHECToken == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("splunk-hec-url requires splunk-hec-token.")
+		}
+	}
+	if _, ok := alertStates[plugin.SplunkFailureState]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("splunk-failure-state must be ok, warning or critical.")
+	}
+
+	// the unit's own HTTP API is a url too
+	if plugin.HTTPFallbackURL != "" {
+		if u, err := url.Parse(plugin.HTTPFallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("http-fallback-url must be an http or https url.")
+		}
+	}
+
+	// only the formats Sensu knows how to extract
+	if plugin.OutputMetricFormat != "" && !validMetricFormat(plugin.OutputMetricFormat) {
+		return sensu.CheckStateCritical, fmt.Errorf("output-metric-format must be one of %s.", strings.Join(corev2.OutputMetricFormats, ", "))
+	}
+
+	// tags only go on the formats that have metric points, and have to
+	// survive being written into all of them
+	if len(plugin.MetricTags) > 0 {
+		if plugin.OutputMetricFormat == "" || plugin.OutputMetricFormat == corev2.NagiosOutputMetricFormat {
+			return sensu.CheckStateCritical, fmt.Errorf("metric-tag requires an output-metric-format other than %s.", corev2.NagiosOutputMetricFormat)
+		}
+		for key, value := range plugin.MetricTags {
+			if !validMetricTag(key, value) {
+				return sensu.CheckStateCritical, fmt.Errorf("metric-tag %s=%s must be a key of letters, digits and underscores and a value without spaces or separators.", key, value)
+			}
+		}
+	}
+
+	// as do the perfdata bounds, which only the perfdata has room for, and
+	// a metric's range can't be empty
+	if len(plugin.PerfMin) > 0 || len(plugin.PerfMax) > 0 {
+		if plugin.OutputMetricFormat != "" && plugin.OutputMetricFormat != corev2.NagiosOutputMetricFormat {
+			return sensu.CheckStateCritical, fmt.Errorf("perf-min and perf-max can't be used with an output-metric-format other than %s.", corev2.NagiosOutputMetricFormat)
+		}
+		for argument, bounds := range map[string]map[string]string{"perf-min": plugin.PerfMin, "perf-max": plugin.PerfMax} {
+			for name, value := range bounds {
+				if !knownMetrics[name] {
+					return sensu.CheckStateCritical, fmt.Errorf("%s %s isn't a metric the check emits.", argument, name)
+				}
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					return sensu.CheckStateCritical, fmt.Errorf("%s %s=%s must be a number.", argument, name, value)
+				}
+			}
+		}
+		for name, value := range plugin.PerfMin {
+			if max, ok := plugin.PerfMax[name]; ok {
+				lo, _ := strconv.ParseFloat(value, 64)
+				hi, _ := strconv.ParseFloat(max, 64)
+				if lo >= hi {
+					return sensu.CheckStateCritical, fmt.Errorf("perf-min for %s must be below its perf-max.", name)
+				}
+			}
+		}
+	}
+
+	// throttling needs somewhere to remember the last critical
+	if plugin.ThrottleWindow < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("throttle-window must not be negative.")
+	}
+	if plugin.ThrottleWindow > 0 && plugin.StateFile == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("throttle-window requires a state-file.")
+	}
+
+	// as does the delta
+	if plugin.DegreesDelta && plugin.StateFile == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("degrees-delta requires a state-file.")
+	}
+
+	// and the moving average, whose weight is a fraction
+	if plugin.EmaAlpha != 0 {
+		if plugin.EmaAlpha < 0 || plugin.EmaAlpha > 1 {
+			return sensu.CheckStateCritical, fmt.Errorf("ema-alpha must be above 0 and at most 1.")
+		}
+		if plugin.StateFile == "" {
+			return sensu.CheckStateCritical, fmt.Errorf("ema-alpha requires a state-file.")
+		}
+	}
+
+	// and the uptime
+	if plugin.AlertOnReboot && plugin.StateFile == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("alert-on-reboot requires a state-file.")
+	}
+
+	// and the last poll
+	if plugin.MinInterval < 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("min-interval must not be negative.")
+	}
+	if plugin.MinInterval > 0 && plugin.StateFile == "" {
+		return sensu.CheckStateCritical, fmt.Errorf("min-interval requires a state-file.")
+	}
+
+	// skipping the extended checks would leave gaps in the tracked readings
+	if plugin.SkipPerfdataBelow != 0 && (plugin.DegreesDelta || plugin.EmaAlpha != 0) {
+		return sensu.CheckStateCritical, fmt.Errorf("skip-perfdata-if-ok-below can't be used with degrees-delta or ema-alpha.")
+	}
+
+	return sensu.CheckStateOK, nil
+}
+
+// changed reports whether the option whose value is at value has been moved
+// off its default. As with a profile, an option given its default value
+// can't be told from one not given at all.
+func changed(value interface{}) bool {
+	for _, opt := range options {
+		if opt.Value == value {
+			return !reflect.DeepEqual(reflect.ValueOf(value).Elem().Interface(), opt.Default)
+		}
+	}
+	return false
+}
+
+func executeCheck(event *types.Event) (int, error) {
+
+	if plugin.DumpOptions {
+		return dumpOptions()
+	}
+
+	// one set of slots is shared by every client this run opens
+	snmpSlots = nil
+	if plugin.MaxConcurrentSNMP > 0 {
+		snmpSlots = make(chan struct{}, plugin.MaxConcurrentSNMP)
+	}
+
+	// every result line goes through the one compressed stream
+	var zw *gzip.Writer
+	if plugin.Output == "json-gz" {
+		zw = gzip.NewWriter(stdout)
+		w := stdout
+		stdout = zw
+		defer func() { stdout = w }()
+	}
+
+	var (
+		state int
+		err   error
+	)
+	switch {
+	case plugin.ProbeVersions:
+		state, err = probeVersions(plugin.Target)
+	case plugin.StdinTargets:
+		state, err = pollTargets(stdin)
+	case plugin.CompareTo != "":
+		state, err = compareTo(plugin.CompareTo, plugin.Target)
+	case plugin.Serve != "":
+		state, err = serve(plugin.Serve, plugin.Target)
+	default:
+		state, err = pollTarget(plugin.Target)
+	}
+
+	if zw != nil {
+		if cerr := zw.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to compress the output: %v", cerr)
+		}
+	}
+	return exitCode(state), err
+}
+
+// probeVersions tries each SNMP version against target in turn and reports
+// the ones the unit answers. It's a diagnostic, so always OK.
+func probeVersions(target string) (int, error) {
+	var working []string
+	for _, version := range []string{"1", "2c", "3"} {
+		if answersVersion(target, version) {
+			working = append(working, version)
+		}
+	}
+
+	summary := "unit doesn't respond to any SNMP version."
+	if len(working) > 0 {
+		summary = fmt.Sprintf("unit responds to SNMP %s.", strings.Join(working, ", "))
+	}
+	return (&checkResult{Target: target}).report(sensu.CheckStateOK, summary, nil)
+}
+
+// answersVersion reports whether target returns the location when asked
+// using version.
+func answersVersion(target string, version string) bool {
+	client := dial(target, version)
+	if err := client.Connect(); err != nil {
+		return false
+	}
+	defer client.Close()
+
+	result, err := client.Get([]string{locationOID})
+	return err == nil && result.Error == gosnmp.NoError
+}
+
+// exitCode maps a check state onto the exit code configured for it.
+func exitCode(state int) int {
+	switch state {
+	case sensu.CheckStateOK:
+		return plugin.ExitOK
+	case sensu.CheckStateWarning:
+		return plugin.ExitWarning
+	case sensu.CheckStateCritical:
+		return plugin.ExitCritical
+	case sensu.CheckStateUnknown:
+		return plugin.ExitUnknown
+	}
+	return state
+}
+
+// pollTarget checks the unit at target, printing and returning the result.
+func pollTarget(target string) (int, error) {
+	// the failure class only matters to callers of checkTarget, the state
+	// and summary already say it all
+	res, _ := checkTarget(target)
+	return res.print()
+}
+
+// checkTarget checks the unit at target without printing anything. The
+// result always carries the state and summary, and the error says why when
+// the unit couldn't be read (ErrConnect, ErrDecode) or breached a threshold
+// (ErrThreshold).
+func checkTarget(target string) (res *checkResult, err error) {
+
+	res = &checkResult{Target: target}
+
+	// timed whatever the outcome, a unit that's slow to fail is slow too
+	if plugin.EmitDuration {
+		start := now()
+		defer func() {
+			ms := now().Sub(start).Milliseconds()
+			res.Metrics = append(res.Metrics, metric{"check_duration_ms", json.Number(strconv.FormatInt(ms, 10))})
+		}()
+	}
+
+	// a scheduler polling too often can get the unit rate-limiting us
+	if plugin.MinInterval > 0 {
+		if since, ok := pollTooSoon(); ok {
+			res.set(sensu.CheckStateOK, fmt.Sprintf("last polled %ds ago, skipped until min-interval of %ds has passed.", int(since.Seconds()), plugin.MinInterval), nil)
+			return res, nil
+		}
+	}
+
+	// whatever the outcome, say whether the unit could be reached at all
+	if plugin.EmitHeartbeat {
+		defer func() {
+			up := "1"
+			var connectErr *ErrConnect
+			if errors.As(err, &connectErr) {
+				up = "0"
+			}
+			res.Metrics = append(res.Metrics, metric{"tempager_up", json.Number(up)})
+		}()
+	}
+
+	// a unit that doesn't answer ping is down, not misconfigured, and one
+	// that does has only SNMP to blame when it doesn't answer
+	reachable := ""
+	if plugin.PingFirst {
+		if err := ping(target, time.Duration(plugin.PingTimeout)*time.Second); err != nil {
+			return res.fail(alertStates[plugin.PingFailureState], "tempager is unreachable, it doesn't answer ping.", &ErrConnect{Target: target, Err: err})
+		}
+		reachable = ", though it answers ping"
+	}
+
+	// configure the SNMP connection
+	version := plugin.SnmpVersion
+	client := dial(target, version)
+
+	// make the connection, newer firmware serves the same readings over
+	// HTTP for when SNMP can't reach the unit at all
+	viaHTTP := false
+	err = client.Connect()
+	if err != nil && plugin.HTTPFallbackURL != "" {
+		if c, ok := dialHTTP(plugin.HTTPFallbackURL); ok {
+			client, viaHTTP, err = c, true, nil
+		}
+	}
+	if err != nil {
+		return res.fail(sensu.CheckStateCritical, fmt.Sprintf("failed to connect to tempager%s.", reachable), &ErrConnect{Target: target, Err: err})
+	}
+	defer func() { client.Close() }()
+
+	// the external reading may come from a keyed or discovered row of the
+	// sensor table
+	externalValueOID := externalOID
+	switch {
+	case plugin.ProbeKey != "":
+		oid, err := probeValueOID(client)
+		if err != nil {
+			return res.fail(sensu.CheckStateCritical, err.Error(), &ErrDecode{Target: target, Err: err})
+		}
+		externalValueOID = oid
+	case plugin.DiscoverSensor:
+		oid, err := discoverSensorOID(client)
+		if err != nil {
+			return res.fail(sensu.CheckStateCritical, err.Error(), &ErrDecode{Target: target, Err: err})
+		}
+		externalValueOID = oid
+	}
+
+	// gather the required values (location / internal sensor / external sensor)
+	oids := []string{locationOID, internalOID, externalValueOID}
+	if plugin.Warmup > 0 || plugin.AlertOnReboot {
+		oids = append(oids, uptimeOID)
+	}
+	var (
+		r          reading
+		rtt        time.Duration
+		fallback   bool
+		skipped    []string
+		uptime     int
+		haveUptime bool
+		rereads    int
+		polls      int
+		last       float64
+		settled    bool
+	)
+	for attempt := 1; ; attempt++ {
+		start := now()
+		result, err := client.Get(oids)
+		rtt = now().Sub(start)
+
+		// a unit that has moved on from v1 ignores or refuses it, but may
+		// well answer v2c with the same community
+		if plugin.AutoVersionFallback && version == "1" && refusedV1(result, err) {
+			client.Close()
+			version = "2c"
+			client = dial(target, version)
+			if err := client.Connect(); err != nil {
+				return res.fail(sensu.CheckStateCritical, fmt.Sprintf("failed to connect to tempager%s.", reachable), &ErrConnect{Target: target, Err: err})
+			}
+			// the switch doesn't use up an attempt
+			attempt--
+			continue
+		}
+
+		// as can a unit that doesn't answer SNMP, which doesn't use up an
+		// attempt either
+		if err != nil && plugin.HTTPFallbackURL != "" && !viaHTTP {
+			if c, ok := dialHTTP(plugin.HTTPFallbackURL); ok {
+				client.Close()
+				client, viaHTTP = c, true
+				attempt--
+				continue
+			}
+		}
+
+		if err != nil {
+			// a timeout may just be a blip, so it gets its own state
+			if isTimeout(err) {
+				return res.fail(transientStates[plugin.TransientErrorState], fmt.Sprintf("timed out gathering oids%s.", reachable), &ErrConnect{Target: target, Err: err})
+			}
+			return res.fail(sensu.CheckStateCritical, fmt.Sprintf("failed to gather oids%s.", reachable), &ErrConnect{Target: target, Err: err})
+		}
+
+		// the bytes behind the decoding, for when the decoding looks wrong
+		if plugin.DumpRawResponse {
+			dumpResponse(stderr, result)
+		}
+
+		// a v1 agent refuses the whole Get over a missing value, which the
+		// GetNext may yet find
+		if plugin.UseGetNext && result.Error == gosnmp.NoSuchName {
+			if result, err = withoutNoSuchName(client, result, oids); err != nil {
+				return res.fail(sensu.CheckStateCritical, fmt.Sprintf("failed to gather oids%s.", reachable), &ErrConnect{Target: target, Err: err})
+			}
+		}
+
+		// the agent may answer with an error-status rather than values
+		if result.Error != gosnmp.NoError {
+			msg := errorStatusMessage(result, oids)
+			return res.fail(sensu.CheckStateUnknown, msg, &ErrDecode{Target: target, Err: errors.New(msg)})
+		}
+
+		// from here on each value is at the position of the oid it answers
+		result = matchVariables(result, oids)
+
+		// some agents only give a scalar up to a GetNext
+		if plugin.UseGetNext {
+			result = fillFromGetNext(client, result, oids)
+		}
+
+		// in pre-production every value has to be just what the MIB says,
+		// nothing is quietly worked around
+		if plugin.ValidateOIDs {
+			if mismatches := validateResponse(result, oids); len(mismatches) > 0 {
+				msg := fmt.Sprintf("unexpected OID responses: %s.", strings.Join(mismatches, "; "))
+				return res.fail(sensu.CheckStateUnknown, msg, &ErrDecode{Target: target, Err: errors.New(msg)})
+			}
+		}
+
+		// readings straight after a cold start can't be trusted, an unreadable
+		// uptime just means the reading is evaluated as normal
+		if len(result.Variables) > 3 {
+			uptime, haveUptime = uptimeSeconds(result.Variables[3])
+		}
+		if plugin.Warmup > 0 && haveUptime && uptime < plugin.Warmup {
+			res.set(sensu.CheckStateOK, fmt.Sprintf("unit is warming up (uptime %ds), reading ignored.", uptime), nil)
+			return res, nil
+		}
+
+		r, err = decodeReading(result)
+
+		// some units read 0 for a moment mid-cycle, another look usually
+		// gets the real value, and doesn't use up an attempt
+		if err == nil && r.rawExternal == 0 && rereads < plugin.RereadOnZero {
+			rereads++
+			attempt--
+			continue
+		}
+
+		// a probe that's still settling is read again until it stops
+		// drifting, or the polls run out and the last reading stands
+		if err == nil && plugin.PollUntilStable {
+			polls++
+			settled = polls > 1 && math.Abs(r.external-last) < plugin.StableTolerance
+			if !settled && polls < plugin.StableMaxPolls {
+				last = r.external
+				attempt--
+				sleep(time.Duration(plugin.StableInterval) * time.Second)
+				continue
+			}
+		}
+		if err == nil {
+			break
+		}
+
+		// a malformed response is often followed by a good one
+		if !plugin.RetryOnDecodeError || attempt >= plugin.Attempts {
+			// a dead external probe can be covered by the internal sensor
+			if err == errExternalFault && plugin.FallbackToInternal {
+				fallback = true
+				break
+			}
+			// or whatever did decode is evaluated on its own
+			if plugin.PartialOK {
+				if partial, s := decodePartial(result); !partial.noInternal || !partial.noExternal {
+					r, skipped = partial, s
+					fallback = r.noExternal
+					break
+				}
+			}
+			// a bad reading is critical, retries running out only unknown
+			state := sensu.CheckStateCritical
+			if plugin.RetryOnDecodeError {
+				state = sensu.CheckStateUnknown
+			}
+			return res.fail(state, err.Error(), &ErrDecode{Target: target, Err: err})
+		}
+	}
+
+	// inverse wiring is undone on the reading as the unit scaled it, before
+	// anything else is worked out from it
+	if plugin.Invert && !fallback && !r.noExternal {
+		external, err := invertReading(r.external)
+		if err != nil {
+			return res.fail(sensu.CheckStateUnknown, err.Error(), &ErrDecode{Target: target, Err: err})
+		}
+		r.external = external
+	}
+
+	// some units report in the unit they're set to display, and mixed
+	// installs can have each probe in its own, everything from here on
+	// works in celsius
+	unit := "c"
+	switch {
+	case plugin.Unit != "":
+		unit = plugin.Unit
+	case plugin.DetectUnit:
+		unit = readUnit(client)
+	}
+	internalUnit, externalUnit := unit, unit
+	if plugin.InternalUnit != "" {
+		internalUnit = plugin.InternalUnit
+	}
+	if plugin.ExternalUnit != "" {
+		externalUnit = plugin.ExternalUnit
+	}
+	r.internal = toCelsius(internalUnit, r.internal)
+	r.external = toCelsius(externalUnit, r.external)
+
+	// nothing reads colder than absolute zero, a probe that does is broken
+	if !r.noInternal && r.internal <= plugin.AbsoluteMinimum {
+		err := fmt.Errorf("internal sensor reading of %.2fc indicates a probe fault.", r.internal)
+		return res.fail(sensu.CheckStateUnknown, err.Error(), &ErrDecode{Target: target, Err: err})
+	}
+	if !fallback && r.external <= plugin.AbsoluteMinimum {
+		err := fmt.Errorf("external probe reading of %.2fc indicates a probe fault.", r.external)
+		return res.fail(sensu.CheckStateUnknown, err.Error(), &ErrDecode{Target: target, Err: err})
+	}
+
+	// a known calibration error is corrected before anything looks at it,
+	// as is the location
+	location := displayLocation(r.location)
+	internal_temperature := r.internal + plugin.CalibrationOffset
+	external_temperature := r.external + plugin.CalibrationOffset
+
+	res.Location = location
+
+	// construct the performance data
+	var metrics []metric
+	if !r.noInternal {
+		res.Internal = &internal_temperature
+		metrics = append(metrics, temperatureMetric("tempager_internal", internal_temperature))
+
+		// the unscaled values help when calibrating
+		if plugin.ShowRaw {
+			res.RawInternal = &r.rawInternal
+		}
+	}
+
+	// a comfortably cool reading doesn't need the extended perfdata, it's
+	// still evaluated in full and trimmed to the bare temperatures once it's
+	// found to be OK
+	bare := plugin.SkipPerfdataBelow != 0 && !fallback && len(skipped) == 0 && external_temperature < plugin.SkipPerfdataBelow
+
+	// without an external reading the internal one stands in for it
+	if fallback {
+		external_temperature = internal_temperature
+	} else {
+		res.External = &external_temperature
+		if plugin.ShowRaw {
+			res.RawExternal = &r.rawExternal
+		}
+		metrics = append(metrics, temperatureMetric("tempager_external", external_temperature))
+
+		// not every unit records them, those that don't are skipped
+		if plugin.IncludeMinMax {
+			if min, ok := readHundredths(client, plugin.ExternalMinOID); ok {
+				min = toCelsius(externalUnit, min)
+				metrics = append(metrics, temperatureMetric("tempager_external_min", min+plugin.CalibrationOffset))
+			}
+			if max, ok := readHundredths(client, plugin.ExternalMaxOID); ok {
+				max = toCelsius(externalUnit, max)
+				metrics = append(metrics, temperatureMetric("tempager_external_max", max+plugin.CalibrationOffset))
+			}
+		}
+	}
+
+	// the unit's own name is optional extra context
+	if plugin.IncludeSysName {
+		res.SysName = readSysName(client)
+	}
+
+	// as is where it is, for units that have been told
+	if plugin.IncludeAssetTag {
+		res.AssetTag = readAssetTag(client)
+	}
+
+	// humidity may come from this unit or a separate one, and is skipped
+	// when it can't be read unless it's required
+	if plugin.HumidityOID != "" {
+		if humidity, ok := readHumidity(target, version, client); ok {
+			res.Humidity = &humidity
+			metrics = append(metrics, metric{"tempager_humidity", json.Number(fmt.Sprintf("%.2f", humidity))})
+		}
+	}
+
+	// there's no delta on the first run
+	if plugin.DegreesDelta && !fallback {
+		if delta, ok := externalDelta(external_temperature); ok {
+			metrics = append(metrics, temperatureMetric("tempager_external_delta", delta))
+		}
+	}
+
+	// a smoothed reading rides out the odd spike, state file problems fall
+	// back to the reading itself
+	evaluated := external_temperature
+	averaged := false
+	if plugin.EmaAlpha > 0 {
+		if ema, ok := externalEMA(external_temperature); ok {
+			evaluated, averaged = ema, true
+			metrics = append(metrics, temperatureMetric("tempager_external_ema", ema))
+		}
+	}
+
+	t := temperatureSummary(location, external_temperature)
+	if averaged {
+		t += fmt.Sprintf(", averaging %sc", localNumber(evaluated))
+	}
+	if res.SysName != "" {
+		t += fmt.Sprintf(" on %s", res.SysName)
+	}
+	if res.Humidity != nil {
+		t += fmt.Sprintf(" with %s%% humidity", localNumber(*res.Humidity))
+	}
+
+	// the limits may be relaxed at times, off-peak say
+	warning, critical := activeRanges(now())
+
+	// the reading's own breach is kept apart from the other alerts until
+	// the maintenance windows have had their say
+	breach := sensu.CheckStateOK
+	switch {
+	case critical.alerts(evaluated):
+		breach = sensu.CheckStateCritical
+	case warning.alerts(evaluated):
+		breach = sensu.CheckStateWarning
+	}
+	state := sensu.CheckStateOK
+
+	// headroom for capacity planning, whichever way the ranges point
+	if plugin.ReportMargins {
+		if m, ok := warning.margin(evaluated); ok {
+			res.MarginToWarning = &m
+			if plugin.MarginPerfData {
+				metrics = append(metrics, temperatureMetric("tempager_margin_to_warning", m))
+			}
+		}
+		if m, ok := critical.margin(evaluated); ok {
+			res.MarginToCritical = &m
+			if plugin.MarginPerfData {
+				metrics = append(metrics, temperatureMetric("tempager_margin_to_critical", m))
+			}
+		}
+	}
+
+	// the reading is the best there is, but it may still be moving
+	if plugin.PollUntilStable && !settled && !fallback {
+		t += fmt.Sprintf("; not stable after %d polls", polls)
+	}
+
+	// the fallback is never better than a warning, the probe still needs
+	// fixing, skipped values were asked to be let through
+	if len(skipped) > 0 {
+		t += fmt.Sprintf("; skipped unreadable %s", strings.Join(skipped, ", "))
+	} else if fallback {
+		state = worst(state, sensu.CheckStateWarning)
+		t += "; external probe fault, evaluating the internal sensor"
+	}
+
+	// agents sending the reading as text can tag it with their own view
+	// of it, an alarm there is one here too
+	if !r.noInternal {
+		state, t = sensorStatus(state, t, "internal sensor", r.internalStatus)
+	}
+	if !fallback {
+		state, t = sensorStatus(state, t, "external probe", r.externalStatus)
+	}
+
+	// a faulted or disconnected probe can keep serving its last value, so
+	// its status trumps whatever it reads
+	if plugin.CheckProbeHealth {
+		switch status, ok := readProbeStatus(client); {
+		case !ok:
+		case status == "fault" || status == "disconnected":
+			state = worst(state, sensu.CheckStateCritical)
+			t += fmt.Sprintf("; external probe is %s, the reading may be stale", status)
+		case status != "ok":
+			state = worst(state, sensu.CheckStateWarning)
+			t += fmt.Sprintf("; external probe reports unrecognised status %q", status)
+		}
+	}
+
+	// an open door explains a lot about a warm room, units without a
+	// contact just don't answer
+	if plugin.CheckDoor {
+		if open, ok := readDoor(client); ok {
+			door, value := "closed", "0"
+			if open {
+				door, value = "open", "1"
+				state = worst(state, alertStates[plugin.DoorOpenState])
+			}
+			metrics = append(metrics, metric{"tempager_door_open", json.Number(value)})
+			t += fmt.Sprintf("; door %s", door)
+		}
+	}
+
+	// a flaky network shows up in the unit's own error counters
+	if plugin.IncludeIfstats {
+		if errs, ok := readIfErrors(client); ok {
+			metrics = append(metrics,
+				metric{"tempager_if_in_errors", json.Number(strconv.FormatUint(errs.In, 10))},
+				metric{"tempager_if_out_errors", json.Number(strconv.FormatUint(errs.Out, 10))})
+			if plugin.WarnOnIfErrors {
+				if delta, ok := ifErrorsDelta(errs); ok && delta.In+delta.Out > 0 {
+					state = worst(state, sensu.CheckStateWarning)
+					t += fmt.Sprintf("; %d interface errors since the last poll (%d in, %d out)", delta.In+delta.Out, delta.In, delta.Out)
+				}
+			}
+		}
+	}
+
+	// where humidity is mandatory its absence is a fault of its own
+	if plugin.RequireHumidity && res.Humidity == nil {
+		state = worst(state, transientStates[plugin.MissingHumidity])
+		t += "; humidity unavailable"
+	}
+
+	// the reading is good, but SNMP on the unit isn't
+	if viaHTTP {
+		t += "; read over HTTP, SNMP unavailable"
+	}
+
+	// probes reading far apart usually means a wiring fault
+	if plugin.SensorSpreadWarning > 0 && !fallback && !r.noInternal {
+		spread := math.Abs(internal_temperature - external_temperature)
+		if spread > plugin.SensorSpreadWarning {
+			state = worst(state, sensu.CheckStateWarning)
+			t += fmt.Sprintf("; sensor spread of %.2fc exceeds %.2fc", spread, plugin.SensorSpreadWarning)
+		}
+	}
+
+	// aliased OIDs hide a dead external probe behind the internal reading
+	if plugin.WarnOnIdentical && !fallback && !r.noInternal && r.rawInternal == r.rawExternal && r.rawExternal != 0 {
+		state = worst(state, sensu.CheckStateWarning)
+		t += "; internal and external sensors read identically"
+	}
+
+	// some firmware gets the scaling wrong, so the reading itself is suspect,
+	// and older firmware doesn't behave like the rest of the fleet
+	if plugin.CheckFirmware || plugin.MinFirmware != "" {
+		firmware := readFirmware(client)
+		if plugin.CheckFirmware && firmwareIn(firmware, plugin.BuggyFirmware) {
+			state = worst(state, sensu.CheckStateWarning)
+			t += fmt.Sprintf("; firmware %s is known to report incorrect scaling", firmware)
+		}
+		if plugin.MinFirmware != "" && firmware != "" {
+			switch below, ok := firmwareBelow(firmware, plugin.MinFirmware); {
+			case !ok:
+				t += fmt.Sprintf("; firmware %q can't be compared with min-firmware", firmware)
+			case below:
+				state = worst(state, sensu.CheckStateWarning)
+				t += fmt.Sprintf("; firmware %s is older than the minimum of %s", firmware, plugin.MinFirmware)
+			}
+		}
+	}
+
+	// after any fallback, this is the version that actually answered, 2c
+	// is just 2 to keep the perfdata numeric
+	if plugin.ReportSnmpVersion && !viaHTTP {
+		res.SnmpVersion = version
+		metrics = append(metrics, metric{"tempager_snmp_version", json.Number(strings.TrimSuffix(version, "c"))})
+	}
+
+	// a slow answer can mean an overloaded or failing unit
+	if plugin.RttWarning > 0 || plugin.RttCritical > 0 {
+		ms := rtt.Milliseconds()
+		metrics = append(metrics, metric{"tempager_rtt_ms", json.Number(strconv.FormatInt(ms, 10))})
+		switch {
+		case plugin.RttCritical > 0 && ms > int64(plugin.RttCritical):
+			state = worst(state, sensu.CheckStateCritical)
+			t += fmt.Sprintf("; SNMP round trip of %dms exceeds %dms", ms, plugin.RttCritical)
+		case plugin.RttWarning > 0 && ms > int64(plugin.RttWarning):
+			state = worst(state, sensu.CheckStateWarning)
+			t += fmt.Sprintf("; SNMP round trip of %dms exceeds %dms", ms, plugin.RttWarning)
+		}
+	}
+
+	// alert on drifting from the unit's own setpoint, if it has one
+	if plugin.SetpointOID != "" {
+		if setpoint, ok := readSetpoint(client); ok {
+			setpoint = toCelsius(externalUnit, setpoint)
+			deviation := math.Abs(external_temperature - setpoint)
+			metrics = append(metrics, temperatureMetric("tempager_setpoint_deviation", deviation))
+			switch {
+			case plugin.DeviationCritical > 0 && deviation > plugin.DeviationCritical:
+				state = worst(state, sensu.CheckStateCritical)
+				t += fmt.Sprintf("; %.2fc from the %.2fc setpoint", deviation, setpoint)
+			case plugin.DeviationWarning > 0 && deviation > plugin.DeviationWarning:
+				state = worst(state, sensu.CheckStateWarning)
+				t += fmt.Sprintf("; %.2fc from the %.2fc setpoint", deviation, setpoint)
+			}
+		}
+	}
+
+	// a battery sags for a while before it gives out, units without one
+	// just don't answer
+	if plugin.BatteryOID != "" {
+		if volts, ok := readBattery(client); ok {
+			metrics = append(metrics, metric{"tempager_battery_volts", json.Number(fmt.Sprintf("%.2f", volts))})
+			switch {
+			case plugin.BatteryCritical > 0 && volts < plugin.BatteryCritical:
+				state = worst(state, sensu.CheckStateCritical)
+				t += fmt.Sprintf("; battery at %.2fV is below %.2fV", volts, plugin.BatteryCritical)
+			case plugin.BatteryWarning > 0 && volts < plugin.BatteryWarning:
+				state = worst(state, sensu.CheckStateWarning)
+				t += fmt.Sprintf("; battery at %.2fV is below %.2fV", volts, plugin.BatteryWarning)
+			}
+		}
+	}
+
+	// stale air on units with a CO2 probe, those without just don't answer
+	if plugin.CO2OID != "" {
+		if ppm, ok := readCO2(client); ok {
+			metrics = append(metrics, metric{"tempager_co2_ppm", json.Number(strconv.Itoa(ppm))})
+			switch {
+			case plugin.CO2Critical > 0 && ppm > plugin.CO2Critical:
+				state = worst(state, sensu.CheckStateCritical)
+				t += fmt.Sprintf("; CO2 at %dppm exceeds %dppm", ppm, plugin.CO2Critical)
+			case plugin.CO2Warning > 0 && ppm > plugin.CO2Warning:
+				state = worst(state, sensu.CheckStateWarning)
+				t += fmt.Sprintf("; CO2 at %dppm exceeds %dppm", ppm, plugin.CO2Warning)
+			}
+		}
+	}
+
+	// an uptime going backwards means the unit restarted between polls
+	if plugin.AlertOnReboot && haveUptime {
+		if previous, ok := rebooted(uptime); ok {
+			state = worst(state, sensu.CheckStateWarning)
+			t += fmt.Sprintf("; unit rebooted since the last poll (uptime %ds, was %ds)", uptime, previous)
+		}
+	}
+
+	// probes added or removed behind the config's back, one more than there
+	// should ever be may be someone tampering with the bus
+	if plugin.ExpectedProbeCount > 0 || plugin.MaxProbeCount > 0 {
+		if count, ok := readProbeCount(client); ok {
+			if plugin.ExpectedProbeCount > 0 && count != plugin.ExpectedProbeCount {
+				state = worst(state, sensu.CheckStateWarning)
+				t += fmt.Sprintf("; %d probes reported, expected %d", count, plugin.ExpectedProbeCount)
+			}
+			if plugin.MaxProbeCount > 0 && count > plugin.MaxProbeCount {
+				state = worst(state, alertStates[plugin.MaxProbeCountState])
+				t += fmt.Sprintf("; %d probes reported, more than the maximum of %d", count, plugin.MaxProbeCount)
+			}
+		}
+	}
+
+	// a location outside the known set means a misdeployed unit
+	if len(plugin.AllowedLocations) > 0 && !locationAllowed(location) {
+		state = worst(state, sensu.CheckStateWarning)
+		t += fmt.Sprintf("; location %q is not an allowed location", location)
+	}
+
+	// a softer view than worst-wins, one warm sensor only pulls it down a bit
+	if plugin.HealthScore {
+		var sensors []float64
+		if !r.noInternal {
+			sensors = append(sensors, internal_temperature)
+		}
+		if !fallback {
+			sensors = append(sensors, external_temperature)
+		}
+		if score, ok := healthScore(sensors, critical); ok {
+			metrics = append(metrics, metric{"tempager_health_score", json.Number(strconv.Itoa(score))})
+		}
+	}
+
+	// still critical, but tagged so routing can escalate
+	if plugin.Emergency != 0 && worst(state, breach) == sensu.CheckStateCritical && evaluated > plugin.Emergency {
+		t += " [EMERGENCY]"
+		metrics = append(metrics, metric{"emergency", "1"})
+	}
+
+	// planned work legitimately heats things up, it doesn't open doors or
+	// slow the unit down
+	if breach != sensu.CheckStateOK && inMaintenance(now()) {
+		breach = maintenanceStates[plugin.MaintenanceState]
+		t += "; in a maintenance window"
+	}
+	state = worst(state, breach)
+
+	// routing wants a finer grade than the state, taken once the state is
+	// final
+	if plugin.SeverityKeyword {
+		res.Severity = severityKeyword(state, evaluated, warning, critical)
+		t += fmt.Sprintf(" [severity=%s]", res.Severity)
+	}
+
+	// the unit's own alarm is acknowledged once the check has taken it on,
+	// a maintenance window having had its say
+	if plugin.AckOnCritical && state == sensu.CheckStateCritical && !viaHTTP {
+		if err := acknowledge(target, version); err != nil {
+			t += fmt.Sprintf("; failed to acknowledge the alarm: %v", err)
+		} else {
+			t += "; alarm acknowledged"
+		}
+	}
+
+	// all that's left of a comfortably cool OK is its temperatures
+	if bare && state == sensu.CheckStateOK {
+		var temperatures []metric
+		for _, m := range metrics {
+			if m.Name == "tempager_internal" || m.Name == "tempager_external" {
+				temperatures = append(temperatures, m)
+			}
+		}
+		metrics = temperatures
+	}
+
+	// aliased inputs repeat the one reading under several names
+	if plugin.DedupeMetrics {
+		metrics = dedupeMetrics(metrics)
+	}
+
+	res.set(state, t, metrics)
+	if state != sensu.CheckStateOK {
+		return res, &ErrThreshold{Target: target, State: state, Summary: t}
+	}
+	return res, nil
+}
+
+// sensorStatus folds the status an agent gave for sensor into the state and
+// summary. OK and NORMAL say nothing new, ALARM and CRITICAL are critical
+// and anything else is a warning.
+func sensorStatus(state int, summary string, sensor string, status string) (int, string) {
+	switch strings.ToLower(status) {
+	case "", "ok", "normal":
+		return state, summary
+	case "alarm", "critical":
+		state = worst(state, sensu.CheckStateCritical)
+	default:
+		state = worst(state, sensu.CheckStateWarning)
+	}
+	return state, fmt.Sprintf("%s; %s reports %s", summary, sensor, status)
+}
+
+// invertReading inverts v by invert-mode. A reciprocal of 0 has no
+// temperature to map onto, so it's an error rather than an infinity.
+func invertReading(v float64) (float64, error) {
+	if plugin.InvertMode != "reciprocal" {
+		return -v, nil
+	}
+	if v == 0 {
+		return 0, errors.New("external reading of 0 can't be inverted with invert-mode reciprocal.")
+	}
+	return 1 / v, nil
+}
+
+// severityKeyword grades a result for routing: info when OK, minor when
+// WARNING and major when CRITICAL, or critical once the reading is as far
+// past the critical range as the critical range is past the warning one on
+// that side. An "@" range has no side to measure from, so it stays major.
+func severityKeyword(state int, reading float64, warning thresholdRange, critical thresholdRange) string {
+	switch state {
+	case sensu.CheckStateOK:
+		return "info"
+	case sensu.CheckStateWarning:
+		return "minor"
+	}
+	if warning.inside || critical.inside {
+		return "major"
+	}
+
+	var past, margin float64
+	switch {
+	case reading > critical.end:
+		past, margin = reading-critical.end, critical.end-warning.end
+	case reading < critical.start:
+		past, margin = critical.start-reading, warning.start-critical.start
+	default:
+		return "major"
+	}
+	if margin > 0 && !math.IsInf(margin, 0) && past >= margin {
+		return "critical"
+	}
+	return "major"
+}
+
+// displayLocation returns location as it should be shown, as hex when asked
+// for or when it's binary rather than text, otherwise with any vendor
+// clutter stripped.
+func displayLocation(location string) string {
+	if plugin.LocationAsHex || !printable(location) {
+		return hex.EncodeToString([]byte(location))
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(location, plugin.StripLocationPrefix), plugin.StripLocationSuffix)
+}
+
+// printable reports whether s is text that can be shown as is.
+func printable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// locationAllowed reports whether location is one of allowed-locations,
+// ignoring surrounding whitespace and case.
+func locationAllowed(location string) bool {
+	location = strings.TrimSpace(location)
+	for _, allowed := range plugin.AllowedLocations {
+		if strings.EqualFold(strings.TrimSpace(allowed), location) {
+			return true
+		}
+	}
+	return false
+}
+
+// firmwareIn reports whether firmware is one of versions, ignoring case and
+// surrounding whitespace.
+func firmwareIn(firmware string, versions []string) bool {
+	if firmware == "" {
+		return false
+	}
+	for _, v := range versions {
+		if strings.EqualFold(strings.TrimSpace(v), firmware) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFirmwareVersion parses the dotted numbers leading a firmware version,
+// so "v2.10.1-beta" is 2, 10, 1.
+func parseFirmwareVersion(s string) ([]int, bool) {
+	s = strings.TrimLeft(strings.TrimSpace(s), "vV")
+	if end := strings.IndexFunc(s, func(r rune) bool { return r != '.' && !unicode.IsDigit(r) }); end >= 0 {
+		s = s[:end]
+	}
+
+	var parts []int
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// firmwareBelow reports whether firmware is an older version than min, a
+// missing part counting as 0 so 2.1 is 2.1.0. ok is false when firmware
+// isn't a version at all.
+func firmwareBelow(firmware string, min string) (below bool, ok bool) {
+	have, ok := parseFirmwareVersion(firmware)
+	if !ok {
+		return false, false
+	}
+	want, _ := parseFirmwareVersion(min)
+
+	for i := 0; i < len(have) || i < len(want); i++ {
+		var h, w int
+		if i < len(have) {
+			h = have[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if h != w {
+			return h < w, true
+		}
+	}
+	return false, true
+}
+
+// healthScore averages the headroom each reading has before the critical
+// range alerts into a score from 100, with all the headroom there is, down to
+// 0 for a reading it alerts on. The headroom is measured against half of a
+// range bounded at both ends, otherwise against the bound's distance from 0,
+// so a plain critical of 40 scores a reading of 0 or below at 100. ok is
+// false without any readings to score.
+func healthScore(readings []float64, critical thresholdRange) (score int, ok bool) {
+	if len(readings) == 0 {
+		return 0, false
+	}
+
+	var scale float64
+	switch {
+	case !math.IsInf(critical.start, 0) && !math.IsInf(critical.end, 0):
+		scale = (critical.end - critical.start) / 2
+	case !math.IsInf(critical.end, 0):
+		scale = math.Abs(critical.end)
+	case !math.IsInf(critical.start, 0):
+		scale = math.Abs(critical.start)
+	}
+
+	var total float64
+	for _, r := range readings {
+		m, bounded := critical.margin(r)
+		switch {
+		case !bounded:
+			total++
+		case scale > 0:
+			total += math.Min(1, m/scale)
+		case m > 0:
+			total++
+		}
+	}
+	return int(math.Round(100 * total / float64(len(readings)))), true
+}
+
+// worst returns the more severe of two check states, where CRITICAL beats
+// UNKNOWN beats WARNING beats OK.
+func worst(a int, b int) int {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+// set records the outcome on the result.
+func (r *checkResult) set(state int, summary string, metrics []metric) {
+	r.Status = state
+	r.State = stateLabels[state]
+	r.Summary = summary
+	r.Metrics = metrics
+}
+
+// fail records a failed check on the result and returns it with err.
+func (r *checkResult) fail(state int, summary string, err error) (*checkResult, error) {
+	r.set(state, summary, nil)
+	return r, err
+}
+
+// report records the outcome on the result, prints it and returns state as
+// the check result.
+func (r *checkResult) report(state int, summary string, metrics []metric) (int, error) {
+	r.set(state, summary, metrics)
+	return r.print()
+}
+
+// print prints the result and returns its state as the check result.
+func (r *checkResult) print() (int, error) {
+
+	// pushed, and sent to splunk, before anything is printed, so the output
+	// can tell of a failure
+	if plugin.PushgatewayURL != "" && len(r.Metrics) > 0 {
+		if err := pushMetrics(plugin.PushgatewayURL, plugin.PushgatewayJob, r); err != nil {
+			r.set(worst(r.Status, alertStates[plugin.PushFailureState]), fmt.Sprintf("%s; failed to push metrics: %v", r.Summary, err), r.Metrics)
+		}
+	}
+	if plugin.SplunkHECURL != "" {
+		if err := sendHEC(plugin.SplunkHECURL, plugin.SplunkHECToken, plugin.SplunkSourcetype, r); err != nil {
+			r.set(worst(r.Status, alertStates[plugin.SplunkFailureState]), fmt.Sprintf("%s; failed to send to splunk: %v", r.Summary, err), r.Metrics)
+		}
+	}
+
+	state, summary, metrics := r.Status, r.Summary, r.Metrics
+
+	// the audit trail is kept whatever the output looks like, and a log
+	// that can't be written never changes the result
+	if plugin.ResultLog != "" {
+		_ = appendResultLog(plugin.ResultLog, r)
+	}
+
+	// nor does a Nagios that isn't reading its command file
+	if plugin.NagiosCmdFile != "" {
+		_ = writeNagiosCommand(plugin.NagiosCmdFile, r)
+	}
+
+	var out string
+	if plugin.Output == "json" || plugin.Output == "json-gz" {
+		out = formatJSON(r)
+	} else {
+		// in a sweep each line has to say which unit it's about
+		if plugin.StdinTargets {
+			summary = fmt.Sprintf("%s: %s", r.Target, summary)
+		}
+
+		// metric pipelines want the location in the name, the summary is
+		// for people and keeps it as it is
+		if plugin.SlugLocation && r.Location != "" {
+			metrics = prefixMetrics(slugLocation(r.Location), metrics)
+		}
+
+		out = formatOutput(r.Target, stateWord(state), summary, metrics)
+		if plugin.ThrottleWindow > 0 {
+			out = throttle(r.Target, state, out, metrics)
+		}
+	}
+
+	// like the result log, syslog is extra and can't fail the check
+	if plugin.Syslog != "" {
+		_ = sendSyslog(plugin.Syslog, state, out)
+	}
+
+	// as is the fan-out to redis, though a failure is worth a mention
+	if plugin.RedisURL != "" {
+		if err := publishRedis(plugin.RedisURL, plugin.RedisChannel, strings.TrimSpace(formatJSON(r))); err != nil {
+			fmt.Fprintf(stderr, "warning: failed to publish to redis: %v\n", err)
+		}
+	}
+
+	fmt.Fprint(stdout, out)
+	return state, nil
+}
+
+// throttle replaces a CRITICAL output identical to the previous one with a
+// shorter "still critical" message while inside the throttle-window. State
+// file problems never hide an alert, the full output is used instead.
+func throttle(target string, state int, out string, metrics []metric) string {
+	s, err := loadState(plugin.StateFile)
+	if err != nil {
+		return out
+	}
+
+	t := now()
+	window := time.Duration(plugin.ThrottleWindow) * time.Second
+	if state == sensu.CheckStateCritical && s.LastCritical == out && t.Sub(s.LastCriticalTime) < window {
+		return formatOutput(target, stateWord(state), fmt.Sprintf("still critical since %s", s.LastCriticalTime.Format(time.RFC3339)), metrics)
+	}
+
+	// anything other than a critical resets the throttle
+	if state == sensu.CheckStateCritical {
+		s.LastCritical = out
+		s.LastCriticalTime = t
+	} else {
+		s.LastCritical = ""
+		s.LastCriticalTime = time.Time{}
+	}
+	_ = saveState(plugin.StateFile, s)
+
+	return out
+}
+
+// externalDelta returns the change in external temperature since the reading
+// stored in the state file, then stores this one. ok is false when there is
+// no previous reading to compare against.
+func externalDelta(external float64) (delta float64, ok bool) {
+	s, err := loadState(plugin.StateFile)
+	if err != nil {
+		return 0, false
+	}
+
+	if s.LastExternal != nil {
+		delta, ok = external-*s.LastExternal, true
+	}
+
+	s.LastExternal = &external
+	_ = saveState(plugin.StateFile, s)
+
+	return delta, ok
+}
+
+// externalEMA folds external into the exponential moving average kept in the
+// state file and returns the new average. The first reading starts the
+// average off.
+func externalEMA(external float64) (float64, bool) {
+	s, err := loadState(plugin.StateFile)
+	if err != nil {
+		return 0, false
+	}
+
+	ema := external
+	if s.ExternalEMA != nil {
+		ema = plugin.EmaAlpha*external + (1-plugin.EmaAlpha)**s.ExternalEMA
+	}
+
+	s.ExternalEMA = &ema
+	_ = saveState(plugin.StateFile, s)
+
+	return ema, true
+}
+
+// rebooted records uptime in the state file and reports whether it's lower
+// than the uptime recorded by the previous poll, which it returns.
+func rebooted(uptime int) (int, bool) {
+	s, err := loadState(plugin.StateFile)
+	if err != nil {
+		return 0, false
+	}
+
+	previous := s.LastUptime
+	s.LastUptime = &uptime
+	_ = saveState(plugin.StateFile, s)
+
+	if previous == nil || uptime >= *previous {
+		return 0, false
+	}
+	return *previous, true
+}
+
+// ifErrorsDelta records errs in the state file and returns how far the
+// counters have gone up since the previous poll. ok is false on the first
+// poll, and when a counter went down, the unit having restarted or the
+// counter wrapped.
+func ifErrorsDelta(errs ifErrors) (delta ifErrors, ok bool) {
+	s, err := loadState(plugin.StateFile)
+	if err != nil {
+		return delta, false
+	}
+
+	previous := s.IfErrors
+	s.IfErrors = &errs
+	_ = saveState(plugin.StateFile, s)
+
+	if previous == nil || errs.In < previous.In || errs.Out < previous.Out {
+		return delta, false
+	}
+	return ifErrors{In: errs.In - previous.In, Out: errs.Out - previous.Out}, true
+}
+
+// pollTooSoon reports whether the previous poll in the state file was less
+// than min-interval ago, and how long ago it was. Otherwise this poll is
+// recorded. State file problems never stop a poll.
+func pollTooSoon() (since time.Duration, ok bool) {
+	s, err := loadState(plugin.StateFile)
+	if err != nil {
+		return 0, false
+	}
+
+	t := now()
+	since = t.Sub(s.LastPoll)
+	if !s.LastPoll.IsZero() && since < time.Duration(plugin.MinInterval)*time.Second {
+		return since, true
+	}
+
+	s.LastPoll = t
+	_ = saveState(plugin.StateFile, s)
+
+	return since, false
+}
+
+// formatOutput builds the output line for the given status, truncating the
+// summary (everything before the perfdata pipe) to summary-max-length. When
+// an output-metric-format other than nagios_perfdata is used, the metrics
+// follow the status line, one per line in that format. no-perfdata drops the
+// metrics entirely.
+func formatOutput(target string, status string, summary string, metrics []metric) string {
+	if plugin.NoPerfData {
+		metrics = nil
+	}
+
+	s := truncate(fmt.Sprintf("%s %s: %s", plugin.PluginConfig.Name, status, summary), plugin.SummaryMaxLength)
+	if len(metrics) == 0 {
+		return s + "\n"
+	}
+	if plugin.OutputMetricFormat != "" && plugin.OutputMetricFormat != corev2.NagiosOutputMetricFormat {
+		return s + "\n" + formatMetrics(plugin.OutputMetricFormat, target, metrics, now())
+	}
+	return fmt.Sprintf("%s | %s\n", s, perfData(metrics))
+}
+
+// truncate shortens s to at most max characters, marking the cut with an
+// ellipsis. A max of 0 leaves s untouched.
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if max <= 0 || len(r) <= max {
+		return s
+	}
+	if max <= len(ellipsis) {
+		return string(r[:max])
+	}
+	return string(r[:max-len(ellipsis)]) + ellipsis
 }
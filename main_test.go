@@ -1,8 +1,2651 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMain(t *testing.T) {
 }
+
+// setDefaults resets the plugin configuration to the option defaults.
+func setDefaults() {
+	for _, opt := range options {
+		reflect.ValueOf(opt.Value).Elem().Set(reflect.ValueOf(opt.Default))
+	}
+}
+
+func TestFormatOutputTruncatesSummary(t *testing.T) {
+	plugin.SummaryMaxLength = 40
+	defer func() { plugin.SummaryMaxLength = 0 }()
+
+	metrics := []metric{{"tempager_internal", "21.50"}, {"tempager_external", "22.00"}}
+	summary := "a very long location string for the server room temperature is 22.00c"
+	got := formatOutput(plugin.Target, "OK", summary, metrics)
+
+	want := "check-tempager-3e-temperature OK: a v... | tempager_internal=21.50, tempager_external=22.00\n"
+	if got != want {
+		t.Errorf("formatOutput() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatOutputNoTruncation(t *testing.T) {
+	plugin.SummaryMaxLength = 0
+
+	got := formatOutput(plugin.Target, "WARNING", "lab temperature is 36.00c", []metric{{"tempager_external", "36.00"}})
+	want := "check-tempager-3e-temperature WARNING: lab temperature is 36.00c | tempager_external=36.00\n"
+	if got != want {
+		t.Errorf("formatOutput() = %q, want %q", got, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"server room", 0, "server room"},
+		{"server room", 11, "server room"},
+		{"server room", 9, "server..."},
+		{"server room", 2, "se"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.in, tt.max); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}
+
+// tempStateFile returns a state file path inside a directory removed when the
+// test finishes.
+func tempStateFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tempager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "state.json")
+}
+
+// setNow pins the check clock to ts for the rest of the test.
+func setNow(t *testing.T, ts time.Time) {
+	now = func() time.Time { return ts }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestThrottleAbbreviatesRepeatedCritical(t *testing.T) {
+	setDefaults()
+	plugin.StateFile = tempStateFile(t)
+	plugin.ThrottleWindow = 300
+
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	metrics := []metric{{"tempager_external", "41.00"}}
+	out := formatOutput(plugin.Target, "CRITICAL", "lab temperature is 41.00c", metrics)
+
+	setNow(t, start)
+	if got := throttle(plugin.Target, sensu.CheckStateCritical, out, metrics); got != out {
+		t.Fatalf("first run = %q, want full output %q", got, out)
+	}
+
+	setNow(t, start.Add(time.Minute))
+	want := "check-tempager-3e-temperature CRITICAL: still critical since 2020-06-01T12:00:00Z | tempager_external=41.00\n"
+	if got := throttle(plugin.Target, sensu.CheckStateCritical, out, metrics); got != want {
+		t.Errorf("second run = %q, want %q", got, want)
+	}
+
+	setNow(t, start.Add(10*time.Minute))
+	if got := throttle(plugin.Target, sensu.CheckStateCritical, out, metrics); got != out {
+		t.Errorf("run outside window = %q, want full output %q", got, out)
+	}
+}
+
+func TestThrottleResetsAfterRecovery(t *testing.T) {
+	setDefaults()
+	plugin.StateFile = tempStateFile(t)
+	plugin.ThrottleWindow = 300
+
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	critical := formatOutput(plugin.Target, "CRITICAL", "lab temperature is 41.00c", nil)
+	ok := formatOutput(plugin.Target, "OK", "lab temperature is 21.00c", nil)
+
+	setNow(t, start)
+	throttle(plugin.Target, sensu.CheckStateCritical, critical, nil)
+	throttle(plugin.Target, sensu.CheckStateOK, ok, nil)
+
+	setNow(t, start.Add(time.Minute))
+	if got := throttle(plugin.Target, sensu.CheckStateCritical, critical, nil); got != critical {
+		t.Errorf("critical after recovery = %q, want full output %q", got, critical)
+	}
+}
+
+// fakeClient answers Gets, GetNexts and walks from the get, getNext and walk
+// functions instead of the network.
+type fakeClient struct {
+	connectErr error
+	get        func(oids []string) (*gosnmp.SnmpPacket, error)
+	getNext    func(oids []string) (*gosnmp.SnmpPacket, error)
+	walk       func(rootOid string) ([]gosnmp.SnmpPDU, error)
+	gets       [][]string
+	closed     bool
+}
+
+func (c *fakeClient) Connect() error {
+	return c.connectErr
+}
+
+func (c *fakeClient) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	c.gets = append(c.gets, oids)
+	return c.get(oids)
+}
+
+func (c *fakeClient) GetNext(oids []string) (*gosnmp.SnmpPacket, error) {
+	return c.getNext(oids)
+}
+
+func (c *fakeClient) WalkAll(rootOid string) ([]gosnmp.SnmpPDU, error) {
+	return c.walk(rootOid)
+}
+
+func (c *fakeClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+// tempagerPacket is a response to the standard Get carrying the given
+// location and raw (hundredths of a degree) temperatures.
+func tempagerPacket(location string, internal int, external int) *gosnmp.SnmpPacket {
+	return &gosnmp.SnmpPacket{
+		Variables: []gosnmp.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.1.6.0", Type: gosnmp.OctetString, Value: []byte(location)},
+			{Name: ".1.3.6.1.4.1.20916.1.7.1.1.1.1.0", Type: gosnmp.Integer, Value: internal},
+			{Name: ".1.3.6.1.4.1.20916.1.7.1.2.1.1.0", Type: gosnmp.Integer, Value: external},
+		},
+	}
+}
+
+// respond returns a get function that always answers with packet.
+func respond(packet *gosnmp.SnmpPacket) func([]string) (*gosnmp.SnmpPacket, error) {
+	return func([]string) (*gosnmp.SnmpPacket, error) {
+		return packet, nil
+	}
+}
+
+// runCheck runs executeCheck against client, returning the state and output.
+func runCheck(t *testing.T, client snmpClient) (int, string) {
+	t.Helper()
+
+	var out bytes.Buffer
+	oldClient := newClient
+	stdout = &out
+	newClient = func(string, string) snmpClient { return client }
+	defer func() {
+		stdout = os.Stdout
+		newClient = oldClient
+	}()
+
+	state, err := executeCheck(nil)
+	if err != nil {
+		t.Fatalf("executeCheck() error = %v", err)
+	}
+	return state, out.String()
+}
+
+func TestExecuteCheck(t *testing.T) {
+	tests := []struct {
+		external  int
+		wantState int
+		wantOut   string
+	}{
+		{2150, sensu.CheckStateOK, "check-tempager-3e-temperature OK: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+		{3600, sensu.CheckStateWarning, "check-tempager-3e-temperature WARNING: lab temperature is 36.00c | tempager_internal=20.00, tempager_external=36.00\n"},
+		{4100, sensu.CheckStateCritical, "check-tempager-3e-temperature CRITICAL: lab temperature is 41.00c | tempager_internal=20.00, tempager_external=41.00\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		client := &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))}
+
+		state, out := runCheck(t, client)
+		if state != tt.wantState || out != tt.wantOut {
+			t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out, tt.wantState, tt.wantOut)
+		}
+		if !client.closed {
+			t.Error("connection was not closed")
+		}
+	}
+}
+
+func TestExecuteCheckConnectFailure(t *testing.T) {
+	setDefaults()
+	client := &fakeClient{connectErr: errors.New("no route to host")}
+
+	state, out := runCheck(t, client)
+	if state != sensu.CheckStateCritical || !strings.Contains(out, "failed to connect") {
+		t.Errorf("executeCheck() = %d, %q, want a critical connect failure", state, out)
+	}
+}
+
+func TestExecuteCheckErrorStatus(t *testing.T) {
+	tests := []struct {
+		status gosnmp.SNMPError
+		index  uint8
+		want   string
+	}{
+		{gosnmp.TooBig, 0, "response too big for the agent"},
+		{gosnmp.NoSuchName, 3, "OID .1.3.6.1.4.1.20916.1.7.1.2.1.1.0 not found on agent"},
+		{gosnmp.BadValue, 1, "OID .1.3.6.1.2.1.1.6.0 as a bad value"},
+		{gosnmp.GenErr, 2, "general error reading OID .1.3.6.1.4.1.20916.1.7.1.1.1.1.0"},
+		{gosnmp.NoAccess, 1, "access to OID .1.3.6.1.2.1.1.6.0 denied"},
+		{gosnmp.AuthorizationError, 0, "agent refused authorization"},
+		{gosnmp.ResourceUnavailable, 0, "agent is out of resources"},
+		{gosnmp.ReadOnly, 9, "error-status ReadOnly for OID requested"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		packet := &gosnmp.SnmpPacket{Error: tt.status, ErrorIndex: tt.index}
+
+		state, out := runCheck(t, &fakeClient{get: respond(packet)})
+		if state != sensu.CheckStateUnknown {
+			t.Errorf("%v: state = %d, want %d", tt.status, state, sensu.CheckStateUnknown)
+		}
+		if !strings.Contains(out, "UNKNOWN: ") || !strings.Contains(out, tt.want) {
+			t.Errorf("%v: output = %q, want it to contain %q", tt.status, out, tt.want)
+		}
+	}
+}
+
+func TestExecuteCheckDegreesDelta(t *testing.T) {
+	setDefaults()
+	plugin.StateFile = tempStateFile(t)
+	plugin.DegreesDelta = true
+
+	// first run has nothing to compare with
+	_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	if strings.Contains(out, "tempager_external_delta") {
+		t.Errorf("first run output = %q, want no delta", out)
+	}
+
+	_, out = runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2325))})
+	if !strings.HasSuffix(out, ", tempager_external_delta=1.75\n") {
+		t.Errorf("second run output = %q, want a delta of 1.75", out)
+	}
+}
+
+func TestExternalDeltaSeeded(t *testing.T) {
+	setDefaults()
+	plugin.StateFile = tempStateFile(t)
+
+	prior := 25.0
+	if err := saveState(plugin.StateFile, checkState{LastExternal: &prior}); err != nil {
+		t.Fatal(err)
+	}
+
+	delta, ok := externalDelta(22.5)
+	if !ok || delta != -2.5 {
+		t.Errorf("externalDelta() = %v, %v, want -2.5, true", delta, ok)
+	}
+
+	s, err := loadState(plugin.StateFile)
+	if err != nil || s.LastExternal == nil || *s.LastExternal != 22.5 {
+		t.Errorf("stored reading = %v, %v, want 22.5", s.LastExternal, err)
+	}
+}
+
+func TestExecuteCheckEmaAlpha(t *testing.T) {
+	setDefaults()
+	plugin.StateFile = tempStateFile(t)
+	plugin.EmaAlpha = 0.5
+
+	// a step from 20c to 40c closes half the remaining gap each run
+	steps := []struct {
+		external  int
+		wantEMA   float64
+		wantState int
+	}{
+		{2000, 20, sensu.CheckStateOK},
+		{4000, 30, sensu.CheckStateOK},
+		{4000, 35, sensu.CheckStateOK},
+		{4000, 37.5, sensu.CheckStateWarning},
+		{4000, 38.75, sensu.CheckStateWarning},
+	}
+	for i, step := range steps {
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, step.external))})
+		if state != step.wantState {
+			t.Errorf("run %d: state = %d, want %d (%q)", i, state, step.wantState, out)
+		}
+		if want := fmt.Sprintf("tempager_external_ema=%.2f\n", step.wantEMA); !strings.HasSuffix(out, want) {
+			t.Errorf("run %d: output = %q, want it to end %q", i, out, want)
+		}
+
+		s, err := loadState(plugin.StateFile)
+		if err != nil || s.ExternalEMA == nil || math.Abs(*s.ExternalEMA-step.wantEMA) > 1e-9 {
+			t.Errorf("run %d: stored average = %v, %v, want %v", i, s.ExternalEMA, err, step.wantEMA)
+		}
+	}
+}
+
+func TestCheckArgsEmaAlpha(t *testing.T) {
+	for _, alpha := range []float64{-0.1, 1.5} {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.StateFile = tempStateFile(t)
+		plugin.EmaAlpha = alpha
+		if _, err := checkArgs(nil); err == nil {
+			t.Errorf("checkArgs() accepted an ema-alpha of %v", alpha)
+		}
+	}
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.EmaAlpha = 1
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted ema-alpha without a state-file")
+	}
+}
+
+func TestExecuteCheckEmergency(t *testing.T) {
+	tests := []struct {
+		external  int
+		wantState int
+		wantTag   bool
+	}{
+		{3600, sensu.CheckStateWarning, false},
+		{4100, sensu.CheckStateCritical, false},
+		{4500, sensu.CheckStateCritical, false},
+		{4501, sensu.CheckStateCritical, true},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Emergency = 45
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if state != tt.wantState {
+			t.Errorf("%d: state = %d, want %d", tt.external, state, tt.wantState)
+		}
+		tagged := strings.Contains(out, "[EMERGENCY]") && strings.Contains(out, "emergency=1")
+		if tagged != tt.wantTag {
+			t.Errorf("%d: output = %q, want emergency tag %v", tt.external, out, tt.wantTag)
+		}
+	}
+}
+
+func TestExecuteCheckEmergencyNotCritical(t *testing.T) {
+	setDefaults()
+	plugin.Emergency = 45
+	plugin.CriticalRange = "~:50"
+
+	// a range putting critical above the emergency leaves a WARNING untagged
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 4600))})
+	if state != sensu.CheckStateWarning || strings.Contains(out, "EMERGENCY") || strings.Contains(out, "emergency=1") {
+		t.Errorf("executeCheck() = %d, %q, want an untagged WARNING", state, out)
+	}
+}
+
+func TestCheckArgsEmergencyBelowCritical(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Emergency = 38
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted an emergency threshold below critical")
+	}
+}
+
+func TestExecuteCheckSensorSpread(t *testing.T) {
+	tests := []struct {
+		internal  int
+		external  int
+		wantState int
+	}{
+		{2000, 2150, sensu.CheckStateOK},
+		{2000, 3000, sensu.CheckStateOK},
+		{-1500, 1600, sensu.CheckStateWarning},
+		{-2000, 4100, sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.SensorSpreadWarning = 30
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", tt.internal, tt.external))})
+		if state != tt.wantState {
+			t.Errorf("%d/%d: state = %d, want %d (%q)", tt.internal, tt.external, state, tt.wantState, out)
+		}
+		if spread := strings.Contains(out, "sensor spread"); spread != (tt.wantState != sensu.CheckStateOK) {
+			t.Errorf("%d/%d: output = %q, unexpected spread note", tt.internal, tt.external, out)
+		}
+	}
+}
+
+func TestWorst(t *testing.T) {
+	tests := []struct {
+		a, b, want int
+	}{
+		{sensu.CheckStateOK, sensu.CheckStateWarning, sensu.CheckStateWarning},
+		{sensu.CheckStateCritical, sensu.CheckStateWarning, sensu.CheckStateCritical},
+		{sensu.CheckStateUnknown, sensu.CheckStateWarning, sensu.CheckStateUnknown},
+		{sensu.CheckStateUnknown, sensu.CheckStateCritical, sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		if got := worst(tt.a, tt.b); got != tt.want {
+			t.Errorf("worst(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteCheckWarmup(t *testing.T) {
+	tests := []struct {
+		uptime    uint32
+		wantState int
+		wantSkip  bool
+	}{
+		{12000, sensu.CheckStateOK, true},
+		{3600000, sensu.CheckStateCritical, false},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Warmup = 300
+
+		packet := tempagerPacket("lab", 2000, 4100)
+		packet.Variables = append(packet.Variables, gosnmp.SnmpPDU{Name: uptimeOID, Type: gosnmp.TimeTicks, Value: tt.uptime})
+		client := &fakeClient{get: respond(packet)}
+
+		state, out := runCheck(t, client)
+		if state != tt.wantState {
+			t.Errorf("uptime %d: state = %d, want %d", tt.uptime, state, tt.wantState)
+		}
+		if skipped := strings.Contains(out, "warming up"); skipped != tt.wantSkip {
+			t.Errorf("uptime %d: output = %q, want skipped %v", tt.uptime, out, tt.wantSkip)
+		}
+		if got := client.gets[0]; len(got) != 4 || got[3] != uptimeOID {
+			t.Errorf("uptime %d: requested %v, want sysUpTime included", tt.uptime, got)
+		}
+	}
+}
+
+func TestExecuteCheckAlertOnReboot(t *testing.T) {
+	setDefaults()
+	plugin.StateFile = tempStateFile(t)
+	plugin.AlertOnReboot = true
+
+	// ticks are hundredths of a second
+	steps := []struct {
+		uptime    uint32
+		wantState int
+		wantNote  string
+	}{
+		{360000, sensu.CheckStateOK, ""},
+		{420000, sensu.CheckStateOK, ""},
+		{6000, sensu.CheckStateWarning, "; unit rebooted since the last poll (uptime 60s, was 4200s)"},
+		{12000, sensu.CheckStateOK, ""},
+	}
+	for i, step := range steps {
+		packet := tempagerPacket("lab", 2000, 2150)
+		packet.Variables = append(packet.Variables, gosnmp.SnmpPDU{Name: uptimeOID, Type: gosnmp.TimeTicks, Value: step.uptime})
+
+		state, out := runCheck(t, &fakeClient{get: respond(packet)})
+		if state != step.wantState {
+			t.Errorf("run %d: state = %d, want %d (%q)", i, state, step.wantState, out)
+		}
+		if noted := strings.Contains(out, "rebooted"); noted != (step.wantNote != "") || !strings.Contains(out, step.wantNote) {
+			t.Errorf("run %d: output = %q, want note %q", i, out, step.wantNote)
+		}
+	}
+}
+
+func TestCheckArgsAlertOnReboot(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.AlertOnReboot = true
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted alert-on-reboot without a state-file")
+	}
+}
+
+// agent answers each requested OID from its values, anything it doesn't have
+// comes back as NoSuchObject the way a v2c agent would.
+type agent map[string]gosnmp.SnmpPDU
+
+func (a agent) get(oids []string) (*gosnmp.SnmpPacket, error) {
+	packet := &gosnmp.SnmpPacket{}
+	for _, oid := range oids {
+		v, ok := a[oid]
+		if !ok {
+			v = gosnmp.SnmpPDU{Name: oid, Type: gosnmp.NoSuchObject}
+		}
+		packet.Variables = append(packet.Variables, v)
+	}
+	return packet, nil
+}
+
+// tempagerAgent is an agent holding the standard tempager values.
+func tempagerAgent(location string, internal int, external int) agent {
+	a := agent{}
+	for _, v := range tempagerPacket(location, internal, external).Variables {
+		a[v.Name] = v
+	}
+	return a
+}
+
+func TestExecuteCheckIncludeSysName(t *testing.T) {
+	setDefaults()
+	plugin.IncludeSysName = true
+
+	a := tempagerAgent("lab", 2000, 2150)
+	a[sysNameOID] = gosnmp.SnmpPDU{Name: sysNameOID, Type: gosnmp.OctetString, Value: []byte("tempager-01")}
+
+	_, out := runCheck(t, &fakeClient{get: a.get})
+	if !strings.Contains(out, "OK: lab temperature is 21.50c on tempager-01 |") {
+		t.Errorf("output = %q, want the sysName in the summary", out)
+	}
+
+	plugin.Output = "json"
+	_, out = runCheck(t, &fakeClient{get: a.get})
+	var res checkResult
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("output %q isn't JSON: %v", out, err)
+	}
+	if res.SysName != "tempager-01" || res.Location != "lab" {
+		t.Errorf("sysname/location = %q/%q, want tempager-01/lab", res.SysName, res.Location)
+	}
+}
+
+func TestExecuteCheckIncludeAssetTag(t *testing.T) {
+	const assetTagOID = ".1.3.6.1.4.1.20916.1.7.1.9.0"
+
+	setDefaults()
+	plugin.Output = "json"
+	plugin.IncludeAssetTag = true
+	plugin.AssetTagOID = assetTagOID
+
+	a := tempagerAgent("lab", 2000, 2150)
+	a[assetTagOID] = gosnmp.SnmpPDU{Name: assetTagOID, Type: gosnmp.OctetString, Value: []byte(" DC1-R12-U40 ")}
+
+	_, out := runCheck(t, &fakeClient{get: a.get})
+	var res checkResult
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("output %q isn't JSON: %v", out, err)
+	}
+	if res.AssetTag != "DC1-R12-U40" {
+		t.Errorf("asset_tag = %q, want DC1-R12-U40", res.AssetTag)
+	}
+
+	// a unit without one just goes without
+	_, out = runCheck(t, &fakeClient{get: tempagerAgent("lab", 2000, 2150).get})
+	if strings.Contains(out, "asset_tag") {
+		t.Errorf("executeCheck() = %q, want no asset tag", out)
+	}
+}
+
+func TestCheckArgsIncludeAssetTag(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.IncludeAssetTag = true
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted include-asset-tag without asset-tag-oid")
+	}
+}
+
+func TestExecuteCheckIncludeSysNameAbsent(t *testing.T) {
+	setDefaults()
+	plugin.IncludeSysName = true
+
+	state, out := runCheck(t, &fakeClient{get: tempagerAgent("lab", 2000, 2150).get})
+	if state != sensu.CheckStateOK || !strings.Contains(out, "OK: lab temperature is 21.50c |") {
+		t.Errorf("executeCheck() = %d, %q, want a plain OK", state, out)
+	}
+}
+
+func TestExecuteCheckJSON(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Output = "json"
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))})
+	want := `{"target":"192.0.2.1","status":1,"state":"WARNING","summary":"lab temperature is 36.00c","location":"lab","internal":20,"external":36,"metrics":[{"name":"tempager_internal","value":20.00},{"name":"tempager_external","value":36.00}]}` + "\n"
+	if state != sensu.CheckStateWarning || out != want {
+		t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out, sensu.CheckStateWarning, want)
+	}
+}
+
+func TestExecuteCheckTransientErrorState(t *testing.T) {
+	timeout := func([]string) (*gosnmp.SnmpPacket, error) {
+		return nil, errors.New("request timeout (after 3 retries)")
+	}
+	decode := func([]string) (*gosnmp.SnmpPacket, error) {
+		return nil, errors.New("unable to decode packet: wrong digest")
+	}
+
+	tests := []struct {
+		setting   string
+		get       func([]string) (*gosnmp.SnmpPacket, error)
+		wantState int
+	}{
+		{"warning", timeout, sensu.CheckStateWarning},
+		{"unknown", timeout, sensu.CheckStateUnknown},
+		{"critical", timeout, sensu.CheckStateCritical},
+		{"warning", decode, sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.TransientErrorState = tt.setting
+
+		state, out := runCheck(t, &fakeClient{get: tt.get})
+		if state != tt.wantState {
+			t.Errorf("%s: state = %d, want %d (%q)", tt.setting, state, tt.wantState, out)
+		}
+	}
+}
+
+// sequence returns a get function answering with each packet in turn, then
+// repeating the last one.
+func sequence(packets ...*gosnmp.SnmpPacket) func([]string) (*gosnmp.SnmpPacket, error) {
+	i := 0
+	return func([]string) (*gosnmp.SnmpPacket, error) {
+		p := packets[i]
+		if i < len(packets)-1 {
+			i++
+		}
+		return p, nil
+	}
+}
+
+func TestExecuteCheckRetryOnDecodeError(t *testing.T) {
+	malformed := tempagerPacket("lab", 2000, 2150)
+	malformed.Variables[2].Value = "garbage"
+
+	setDefaults()
+	plugin.RetryOnDecodeError = true
+	client := &fakeClient{get: sequence(malformed, tempagerPacket("lab", 2000, 2150))}
+
+	state, out := runCheck(t, client)
+	if state != sensu.CheckStateOK || len(client.gets) != 2 {
+		t.Errorf("executeCheck() = %d, %q after %d gets, want OK after 2", state, out, len(client.gets))
+	}
+}
+
+func TestExecuteCheckRereadOnZero(t *testing.T) {
+	zero := tempagerPacket("lab", 2000, 0)
+	real := tempagerPacket("lab", 2000, 2150)
+
+	tests := []struct {
+		rereads  int
+		packets  []*gosnmp.SnmpPacket
+		wantGets int
+		wantOut  string
+	}{
+		// without rereads the zero stands
+		{0, []*gosnmp.SnmpPacket{zero, real}, 1, "lab temperature is 0.00c"},
+		// the first reread gets the real value
+		{2, []*gosnmp.SnmpPacket{zero, real}, 2, "lab temperature is 21.50c"},
+		{2, []*gosnmp.SnmpPacket{zero, zero, real}, 3, "lab temperature is 21.50c"},
+		// out of rereads, the zero is the reading after all
+		{1, []*gosnmp.SnmpPacket{zero, zero, real}, 2, "lab temperature is 0.00c"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.RereadOnZero = tt.rereads
+		client := &fakeClient{get: sequence(tt.packets...)}
+
+		_, out := runCheck(t, client)
+		if len(client.gets) != tt.wantGets || !strings.Contains(out, tt.wantOut) {
+			t.Errorf("%d rereads: %q after %d gets, want %q after %d", tt.rereads, out, len(client.gets), tt.wantOut, tt.wantGets)
+		}
+	}
+}
+
+func TestCheckArgsRereadOnZero(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.RereadOnZero = -1
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a negative reread-on-zero")
+	}
+}
+
+func TestExecuteCheckInvert(t *testing.T) {
+	tests := []struct {
+		mode      string
+		external  int
+		wantState int
+		wantOut   string
+	}{
+		{"negate", -2150, sensu.CheckStateOK, "OK: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+		{"negate", -4100, sensu.CheckStateCritical, "CRITICAL: lab temperature is 41.00c | tempager_internal=20.00, tempager_external=41.00\n"},
+		// the probe reads 0.04 at 25c
+		{"reciprocal", 4, sensu.CheckStateOK, "OK: lab temperature is 25.00c | tempager_internal=20.00, tempager_external=25.00\n"},
+		{"reciprocal", 0, sensu.CheckStateUnknown, "UNKNOWN: external reading of 0 can't be inverted with invert-mode reciprocal.\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Invert = true
+		plugin.InvertMode = tt.mode
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if state != tt.wantState || !strings.HasSuffix(out, tt.wantOut) {
+			t.Errorf("%s %d: executeCheck() = %d, %q, want %d, %q", tt.mode, tt.external, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestCheckArgsInvertMode(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Invert = true
+	plugin.InvertMode = "log"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted an invert-mode of log")
+	}
+}
+
+func TestExecuteCheckPollUntilStable(t *testing.T) {
+	// a probe settling towards 36c after being plugged in
+	converging := []*gosnmp.SnmpPacket{
+		tempagerPacket("lab", 2000, 2400),
+		tempagerPacket("lab", 2000, 3000),
+		tempagerPacket("lab", 2000, 3400),
+		tempagerPacket("lab", 2000, 3560),
+		tempagerPacket("lab", 2000, 3600),
+		tempagerPacket("lab", 2000, 3605),
+	}
+
+	tests := []struct {
+		maxPolls  int
+		wantGets  int
+		wantState int
+		wantOut   string
+	}{
+		// settles once successive readings are within the 0.1c tolerance
+		{10, 6, sensu.CheckStateWarning, "lab temperature is 36.05c |"},
+		// out of polls, the last reading is evaluated as it is
+		{3, 3, sensu.CheckStateOK, "lab temperature is 34.00c; not stable after 3 polls |"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.PollUntilStable = true
+		plugin.StableMaxPolls = tt.maxPolls
+		plugin.StableInterval = 2
+
+		var slept []time.Duration
+		sleep = func(d time.Duration) { slept = append(slept, d) }
+		client := &fakeClient{get: sequence(converging...)}
+
+		state, out := runCheck(t, client)
+		sleep = time.Sleep
+		if len(client.gets) != tt.wantGets || state != tt.wantState || !strings.Contains(out, tt.wantOut) {
+			t.Errorf("%d polls: executeCheck() = %d, %q after %d gets, want %d, %q after %d", tt.maxPolls, state, out, len(client.gets), tt.wantState, tt.wantOut, tt.wantGets)
+		}
+		if len(slept) != tt.wantGets-1 || slept[0] != 2*time.Second {
+			t.Errorf("%d polls: slept %v, want 2s between each of the %d gets", tt.maxPolls, slept, tt.wantGets)
+		}
+	}
+}
+
+func TestCheckArgsPollUntilStable(t *testing.T) {
+	for _, set := range []func(){
+		func() { plugin.StableTolerance = 0 },
+		func() { plugin.StableMaxPolls = 1 },
+		func() { plugin.StableInterval = -1 },
+	} {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.PollUntilStable = true
+		set()
+
+		if _, err := checkArgs(nil); err == nil {
+			t.Errorf("checkArgs() accepted tolerance %v, %d polls and interval %d", plugin.StableTolerance, plugin.StableMaxPolls, plugin.StableInterval)
+		}
+	}
+}
+
+func TestExecuteCheckPersistentDecodeError(t *testing.T) {
+	malformed := tempagerPacket("lab", 2000, 2150)
+	malformed.Variables[1].Value = nil
+
+	tests := []struct {
+		retry     bool
+		wantState int
+		wantGets  int
+	}{
+		{false, sensu.CheckStateCritical, 1},
+		{true, sensu.CheckStateUnknown, 3},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.RetryOnDecodeError = tt.retry
+		client := &fakeClient{get: respond(malformed)}
+
+		state, out := runCheck(t, client)
+		if state != tt.wantState || !strings.Contains(out, "failed to read internal temperature.") {
+			t.Errorf("retry %v: executeCheck() = %d, %q, want %d", tt.retry, state, out, tt.wantState)
+		}
+		if len(client.gets) != tt.wantGets {
+			t.Errorf("retry %v: %d gets, want %d", tt.retry, len(client.gets), tt.wantGets)
+		}
+	}
+}
+
+func TestExecuteCheckStripLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		prefix   string
+		suffix   string
+		want     string
+	}{
+		{"[TEMPAGER] lab", "[TEMPAGER] ", "", "lab"},
+		{"[TEMPAGER] lab (3E)", "[TEMPAGER] ", " (3E)", "lab"},
+		{"lab (3E)", "", " (3E)", "lab"},
+		{"lab", "[TEMPAGER] ", " (3E)", "lab"},
+		{"[AVTECH] lab", "[TEMPAGER] ", "", "[AVTECH] lab"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.StripLocationPrefix = tt.prefix
+		plugin.StripLocationSuffix = tt.suffix
+
+		_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket(tt.location, 2000, 2150))})
+		if want := "OK: " + tt.want + " temperature is 21.50c"; !strings.Contains(out, want) {
+			t.Errorf("%q: output = %q, want %q", tt.location, out, want)
+		}
+	}
+}
+
+func TestExecuteCheckReorderedResponse(t *testing.T) {
+	setDefaults()
+
+	// the external probe comes back first and the location last
+	packet := tempagerPacket("lab", 2000, 4100)
+	v := packet.Variables
+	packet.Variables = []gosnmp.SnmpPDU{v[2], v[1], v[0]}
+
+	state, out := runCheck(t, &fakeClient{get: respond(packet)})
+	if want := "CRITICAL: lab temperature is 41.00c | tempager_internal=20.00, tempager_external=41.00"; state != sensu.CheckStateCritical || !strings.Contains(out, want) {
+		t.Errorf("executeCheck() = %d, %q, want CRITICAL with %q", state, out, want)
+	}
+}
+
+func TestExecuteCheckLocationAsHex(t *testing.T) {
+	tests := []struct {
+		location string
+		asHex    bool
+		want     string
+	}{
+		{"Server Room", false, "Server Room"},
+		{"Server Room", true, "53657276657220526f6f6d"},
+		{"\x00\x1a\x2b\xff", false, "001a2bff"},
+		{"lab\n", false, "6c61620a"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.LocationAsHex = tt.asHex
+
+		_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket(tt.location, 2000, 2150))})
+		if want := "OK: " + tt.want + " temperature is 21.50c"; !strings.Contains(out, want) {
+			t.Errorf("%q, as hex %v: output = %q, want %q", tt.location, tt.asHex, out, want)
+		}
+	}
+}
+
+func TestExecuteCheckAllowedLocations(t *testing.T) {
+	tests := []struct {
+		location  string
+		wantState int
+	}{
+		{"Server Room", sensu.CheckStateOK},
+		{"  server room ", sensu.CheckStateOK},
+		{"LAB", sensu.CheckStateOK},
+		{"Loading Dock", sensu.CheckStateWarning},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.AllowedLocations = []string{"server room", " Lab"}
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket(tt.location, 2000, 2150))})
+		if state != tt.wantState {
+			t.Errorf("%q: state = %d, want %d (%q)", tt.location, state, tt.wantState, out)
+		}
+		if flagged := strings.Contains(out, "is not an allowed location"); flagged != (tt.wantState == sensu.CheckStateWarning) {
+			t.Errorf("%q: output = %q, unexpected location note", tt.location, out)
+		}
+	}
+}
+
+func TestExecuteCheckRoundTrip(t *testing.T) {
+	tests := []struct {
+		delay     time.Duration
+		wantState int
+		wantRtt   string
+	}{
+		{50 * time.Millisecond, sensu.CheckStateOK, "tempager_rtt_ms=50"},
+		{750 * time.Millisecond, sensu.CheckStateWarning, "tempager_rtt_ms=750"},
+		{2500 * time.Millisecond, sensu.CheckStateCritical, "tempager_rtt_ms=2500"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.RttWarning = 500
+		plugin.RttCritical = 2000
+
+		// the fake unit takes delay to answer on the check's clock
+		clock := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		now = func() time.Time { return clock }
+		slow := func([]string) (*gosnmp.SnmpPacket, error) {
+			clock = clock.Add(tt.delay)
+			return tempagerPacket("lab", 2000, 2150), nil
+		}
+
+		state, out := runCheck(t, &fakeClient{get: slow})
+		now = time.Now
+		if state != tt.wantState || !strings.Contains(out, tt.wantRtt) {
+			t.Errorf("%v: executeCheck() = %d, %q, want %d with %s", tt.delay, state, out, tt.wantState, tt.wantRtt)
+		}
+	}
+}
+
+func TestExecuteCheckEmitDuration(t *testing.T) {
+	tests := []struct {
+		name   string
+		client *fakeClient
+		want   string
+	}{
+		{"read", &fakeClient{}, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50, check_duration_ms=120\n"},
+		{"unreachable", &fakeClient{connectErr: errors.New("connection refused")}, "failed to connect to tempager. | check_duration_ms=0\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.EmitDuration = true
+
+		// the fake unit takes 120ms to answer on the check's clock
+		clock := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		now = func() time.Time { return clock }
+		tt.client.get = func([]string) (*gosnmp.SnmpPacket, error) {
+			clock = clock.Add(120 * time.Millisecond)
+			return tempagerPacket("lab", 2000, 2150), nil
+		}
+
+		_, out := runCheck(t, tt.client)
+		now = time.Now
+		if !strings.HasSuffix(out, tt.want) {
+			t.Errorf("%s: executeCheck() = %q, want suffix %q", tt.name, out, tt.want)
+		}
+	}
+}
+
+// walk returns the variables of a under rootOid, like an agent walking its
+// tree.
+func (a agent) walk(rootOid string) ([]gosnmp.SnmpPDU, error) {
+	var vars []gosnmp.SnmpPDU
+	for oid, v := range a {
+		if strings.HasPrefix(oid, rootOid+".") {
+			vars = append(vars, v)
+		}
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars, nil
+}
+
+func TestExecuteCheckProbeKey(t *testing.T) {
+	const (
+		keyOID   = ".1.3.6.1.4.1.20916.1.7.2.1.2"
+		valueOID = ".1.3.6.1.4.1.20916.1.7.2.1.3"
+	)
+
+	// rows 1 and 2 of a sensor table keyed by probe UUID
+	a := tempagerAgent("lab", 2000, 2150)
+	a[keyOID+".1"] = gosnmp.SnmpPDU{Name: keyOID + ".1", Type: gosnmp.OctetString, Value: []byte("2f1e0c6a-0001")}
+	a[keyOID+".2"] = gosnmp.SnmpPDU{Name: keyOID + ".2", Type: gosnmp.OctetString, Value: []byte("9b3d7e42-0002")}
+	a[valueOID+".1"] = gosnmp.SnmpPDU{Name: valueOID + ".1", Type: gosnmp.Integer, Value: 1800}
+	a[valueOID+".2"] = gosnmp.SnmpPDU{Name: valueOID + ".2", Type: gosnmp.Integer, Value: 4200}
+
+	tests := []struct {
+		key       string
+		wantState int
+		wantOut   string
+	}{
+		{"2f1e0c6a-0001", sensu.CheckStateOK, "temperature is 18.00c"},
+		{"9B3D7E42-0002", sensu.CheckStateCritical, "temperature is 42.00c"},
+		{"00000000-0003", sensu.CheckStateCritical, "no probe with key 00000000-0003 found."},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.ProbeKey = tt.key
+		plugin.ProbeKeyOID = strings.TrimPrefix(keyOID, ".")
+		plugin.ProbeValueOID = valueOID
+
+		state, out := runCheck(t, &fakeClient{get: a.get, walk: a.walk})
+		if state != tt.wantState || !strings.Contains(out, tt.wantOut) {
+			t.Errorf("%s: executeCheck() = %d, %q, want %d with %q", tt.key, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestExecuteCheckSetpointDeviation(t *testing.T) {
+	const setpointOID = ".1.3.6.1.4.1.20916.1.7.1.2.3.0"
+
+	tests := []struct {
+		external  int
+		setpoint  bool
+		wantState int
+	}{
+		{2150, true, sensu.CheckStateOK},
+		{2450, true, sensu.CheckStateWarning},
+		{1500, true, sensu.CheckStateCritical},
+		{1500, false, sensu.CheckStateOK},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.SetpointOID = setpointOID
+		plugin.DeviationWarning = 2
+		plugin.DeviationCritical = 5
+
+		a := tempagerAgent("lab", 2000, tt.external)
+		if tt.setpoint {
+			a[setpointOID] = gosnmp.SnmpPDU{Name: setpointOID, Type: gosnmp.Integer, Value: 2100}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState {
+			t.Errorf("%d: state = %d, want %d (%q)", tt.external, state, tt.wantState, out)
+		}
+		if deviated := strings.Contains(out, "from the 21.00c setpoint"); deviated != (tt.wantState != sensu.CheckStateOK) {
+			t.Errorf("%d: output = %q, unexpected setpoint note", tt.external, out)
+		}
+		if reported := strings.Contains(out, "tempager_setpoint_deviation="); reported != tt.setpoint {
+			t.Errorf("%d: output = %q, want deviation metric %v", tt.external, out, tt.setpoint)
+		}
+	}
+}
+
+func TestExecuteCheckBattery(t *testing.T) {
+	const batteryOID = ".1.3.6.1.4.1.20916.1.7.1.4.1.0"
+
+	tests := []struct {
+		volts     int
+		present   bool
+		wantState int
+		wantOut   string
+	}{
+		{1320, true, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50, tempager_battery_volts=13.20\n"},
+		{1180, true, sensu.CheckStateWarning, "lab temperature is 21.50c; battery at 11.80V is below 12.00V | tempager_internal=20.00, tempager_external=21.50, tempager_battery_volts=11.80\n"},
+		{1050, true, sensu.CheckStateCritical, "lab temperature is 21.50c; battery at 10.50V is below 11.00V | tempager_internal=20.00, tempager_external=21.50, tempager_battery_volts=10.50\n"},
+		{0, false, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.BatteryOID = batteryOID
+		plugin.BatteryWarning = 12
+		plugin.BatteryCritical = 11
+
+		a := tempagerAgent("lab", 2000, 2150)
+		if tt.present {
+			a[batteryOID] = gosnmp.SnmpPDU{Name: batteryOID, Type: gosnmp.Integer, Value: tt.volts}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState || !strings.HasSuffix(out, tt.wantOut) {
+			t.Errorf("%d: executeCheck() = %d, %q, want %d, %q", tt.volts, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestExecuteCheckCO2(t *testing.T) {
+	const co2OID = ".1.3.6.1.4.1.20916.1.7.1.6.1.0"
+
+	tests := []struct {
+		ppm       int
+		present   bool
+		wantState int
+		wantOut   string
+	}{
+		{650, true, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50, tempager_co2_ppm=650\n"},
+		{1200, true, sensu.CheckStateWarning, "lab temperature is 21.50c; CO2 at 1200ppm exceeds 1000ppm | tempager_internal=20.00, tempager_external=21.50, tempager_co2_ppm=1200\n"},
+		{2600, true, sensu.CheckStateCritical, "lab temperature is 21.50c; CO2 at 2600ppm exceeds 2000ppm | tempager_internal=20.00, tempager_external=21.50, tempager_co2_ppm=2600\n"},
+		{0, false, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.CO2OID = co2OID
+		plugin.CO2Warning = 1000
+		plugin.CO2Critical = 2000
+
+		a := tempagerAgent("lab", 2000, 2150)
+		if tt.present {
+			a[co2OID] = gosnmp.SnmpPDU{Name: co2OID, Type: gosnmp.Integer, Value: tt.ppm}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState || !strings.HasSuffix(out, tt.wantOut) {
+			t.Errorf("%d: executeCheck() = %d, %q, want %d, %q", tt.ppm, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestCheckArgsCO2(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.CO2Critical = 2000
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted co2-critical without co2-oid")
+	}
+
+	plugin.CO2OID = "1.3.6.1.4.1.20916.1.7.1.6.1.0"
+	plugin.CO2Warning = 2500
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted co2-warning above co2-critical")
+	}
+}
+
+func TestExecuteCheckProbeHealth(t *testing.T) {
+	const statusOID = ".1.3.6.1.4.1.20916.1.7.1.2.1.6.0"
+
+	tests := []struct {
+		status    string
+		present   bool
+		wantState int
+		wantOut   string
+	}{
+		{"ok", true, sensu.CheckStateOK, "lab temperature is 21.50c |"},
+		{"Fault", true, sensu.CheckStateCritical, "lab temperature is 21.50c; external probe is fault, the reading may be stale |"},
+		{"disconnected", true, sensu.CheckStateCritical, "lab temperature is 21.50c; external probe is disconnected, the reading may be stale |"},
+		{"calibrating", true, sensu.CheckStateWarning, "lab temperature is 21.50c; external probe reports unrecognised status \"calibrating\" |"},
+		{"", false, sensu.CheckStateOK, "lab temperature is 21.50c |"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.CheckProbeHealth = true
+		plugin.ProbeStatusOID = statusOID
+
+		a := tempagerAgent("lab", 2000, 2150)
+		if tt.present {
+			a[statusOID] = gosnmp.SnmpPDU{Name: statusOID, Type: gosnmp.OctetString, Value: []byte(tt.status)}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState || !strings.Contains(out, tt.wantOut) {
+			t.Errorf("%q: executeCheck() = %d, %q, want %d, %q", tt.status, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestExecuteCheckDoor(t *testing.T) {
+	const doorOID = ".1.3.6.1.4.1.20916.1.7.1.7.1.0"
+
+	tests := []struct {
+		value     interface{}
+		openState string
+		wantState int
+		wantOut   string
+	}{
+		{[]byte("closed"), "warning", sensu.CheckStateOK, "lab temperature is 21.50c; door closed | tempager_internal=20.00, tempager_external=21.50, tempager_door_open=0\n"},
+		{[]byte("Open"), "ok", sensu.CheckStateOK, "lab temperature is 21.50c; door open | tempager_internal=20.00, tempager_external=21.50, tempager_door_open=1\n"},
+		{[]byte("open"), "warning", sensu.CheckStateWarning, "lab temperature is 21.50c; door open | tempager_internal=20.00, tempager_external=21.50, tempager_door_open=1\n"},
+		{1, "critical", sensu.CheckStateCritical, "lab temperature is 21.50c; door open | tempager_internal=20.00, tempager_external=21.50, tempager_door_open=1\n"},
+		{0, "critical", sensu.CheckStateOK, "lab temperature is 21.50c; door closed | tempager_internal=20.00, tempager_external=21.50, tempager_door_open=0\n"},
+		{nil, "warning", sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.CheckDoor = true
+		plugin.DoorOID = doorOID
+		plugin.DoorOpenState = tt.openState
+
+		a := tempagerAgent("lab", 2000, 2150)
+		switch tt.value.(type) {
+		case []byte:
+			a[doorOID] = gosnmp.SnmpPDU{Name: doorOID, Type: gosnmp.OctetString, Value: tt.value}
+		case int:
+			a[doorOID] = gosnmp.SnmpPDU{Name: doorOID, Type: gosnmp.Integer, Value: tt.value}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState || !strings.HasSuffix(out, tt.wantOut) {
+			t.Errorf("%v, %s: executeCheck() = %d, %q, want %d, %q", tt.value, tt.openState, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestCheckArgsDoor(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.CheckDoor = true
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted check-door without door-oid")
+	}
+
+	plugin.DoorOID = "1.3.6.1.4.1.20916.1.7.1.7.1.0"
+	plugin.DoorOpenState = "unknown"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a door-open-state of unknown")
+	}
+}
+
+// fakeAckClient records the Sets it's sent, answering them with errorStatus.
+type fakeAckClient struct {
+	errorStatus gosnmp.SNMPError
+	sets        [][]gosnmp.SnmpPDU
+}
+
+func (c *fakeAckClient) Connect() error {
+	return nil
+}
+
+func (c *fakeAckClient) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	c.sets = append(c.sets, pdus)
+	return &gosnmp.SnmpPacket{Error: c.errorStatus, Variables: pdus}, nil
+}
+
+func (c *fakeAckClient) Close() error {
+	return nil
+}
+
+// useAckClient swaps client in for the rest of the test.
+func useAckClient(t *testing.T, client ackClient) {
+	oldAckClient := newAckClient
+	newAckClient = func(string, string) ackClient { return client }
+	t.Cleanup(func() { newAckClient = oldAckClient })
+}
+
+func TestExecuteCheckAckOnCritical(t *testing.T) {
+	const ackOID = ".1.3.6.1.4.1.20916.1.7.1.8.0"
+
+	tests := []struct {
+		external    int
+		errorStatus gosnmp.SNMPError
+		wantSets    int
+		wantNote    string
+	}{
+		{2150, gosnmp.NoError, 0, ""},
+		{3600, gosnmp.NoError, 0, ""},
+		{4100, gosnmp.NoError, 1, "; alarm acknowledged"},
+		{4100, gosnmp.NotWritable, 1, "; failed to acknowledge the alarm: the unit refused the Set with NotWritable"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.AckOnCritical = true
+		plugin.AckOID = ackOID
+		plugin.AckValue = 2
+		client := &fakeAckClient{errorStatus: tt.errorStatus}
+		useAckClient(t, client)
+
+		_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if len(client.sets) != tt.wantSets {
+			t.Fatalf("%d: %d Sets issued, want %d", tt.external, len(client.sets), tt.wantSets)
+		}
+		if noted := strings.Contains(out, "acknowledge"); noted != (tt.wantNote != "") || !strings.Contains(out, tt.wantNote) {
+			t.Errorf("%d: output = %q, want note %q", tt.external, out, tt.wantNote)
+		}
+		if tt.wantSets > 0 {
+			want := gosnmp.SnmpPDU{Name: ackOID, Type: gosnmp.Integer, Value: 2}
+			if got := client.sets[0]; len(got) != 1 || got[0] != want {
+				t.Errorf("%d: Set(%+v), want %+v", tt.external, got, want)
+			}
+		}
+	}
+}
+
+func TestExecuteCheckAckNotOptedIn(t *testing.T) {
+	setDefaults()
+	plugin.AckOID = ".1.3.6.1.4.1.20916.1.7.1.8.0"
+	plugin.WriteCommunity = "private"
+	client := &fakeAckClient{}
+	useAckClient(t, client)
+
+	runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 4100))})
+	if len(client.sets) != 0 {
+		t.Errorf("%d Sets issued without ack-on-critical, want none", len(client.sets))
+	}
+}
+
+func TestCheckArgsAckOnCritical(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.AckOnCritical = true
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted ack-on-critical without ack-oid")
+	}
+
+	plugin.AckOID = "1.3.6.1.4.1.20916.1.7.1.8.0"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted ack-on-critical without write-community")
+	}
+
+	plugin.WriteCommunity = "private"
+	if _, err := checkArgs(nil); err != nil {
+		t.Errorf("checkArgs() error = %v", err)
+	}
+}
+
+func TestExecuteCheckIfstats(t *testing.T) {
+	setDefaults()
+	plugin.StateFile = tempStateFile(t)
+	plugin.IncludeIfstats = true
+	plugin.WarnOnIfErrors = true
+	plugin.IfIndex = 2
+
+	steps := []struct {
+		in, out   uint
+		wantState int
+		wantOut   string
+	}{
+		{3, 1, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50, tempager_if_in_errors=3, tempager_if_out_errors=1\n"},
+		{3, 1, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50, tempager_if_in_errors=3, tempager_if_out_errors=1\n"},
+		{7, 2, sensu.CheckStateWarning, "lab temperature is 21.50c; 5 interface errors since the last poll (4 in, 1 out) | tempager_internal=20.00, tempager_external=21.50, tempager_if_in_errors=7, tempager_if_out_errors=2\n"},
+		{0, 0, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50, tempager_if_in_errors=0, tempager_if_out_errors=0\n"},
+	}
+	for i, step := range steps {
+		a := tempagerAgent("lab", 2000, 2150)
+		a[ifInErrorsOID+".2"] = gosnmp.SnmpPDU{Name: ifInErrorsOID + ".2", Type: gosnmp.Counter32, Value: step.in}
+		a[ifOutErrorsOID+".2"] = gosnmp.SnmpPDU{Name: ifOutErrorsOID + ".2", Type: gosnmp.Counter32, Value: step.out}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != step.wantState || !strings.HasSuffix(out, step.wantOut) {
+			t.Errorf("run %d: executeCheck() = %d, %q, want %d, %q", i, state, out, step.wantState, step.wantOut)
+		}
+	}
+
+	// an agent without the interface table just leaves the counters out
+	_, out := runCheck(t, &fakeClient{get: tempagerAgent("lab", 2000, 2150).get})
+	if strings.Contains(out, "tempager_if_") {
+		t.Errorf("executeCheck() = %q, want no interface counters", out)
+	}
+}
+
+func TestCheckArgsIfstats(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.WarnOnIfErrors = true
+	plugin.StateFile = tempStateFile(t)
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted warn-on-if-errors without include-ifstats")
+	}
+
+	plugin.IncludeIfstats = true
+	plugin.IfIndex = 0
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted an if-index of 0")
+	}
+}
+
+func TestCheckArgsProbeHealth(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.CheckProbeHealth = true
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted check-probe-health without probe-status-oid")
+	}
+}
+
+func TestCheckArgsBattery(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.BatteryWarning = 12
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted battery-warning without battery-oid")
+	}
+
+	plugin.BatteryOID = "1.3.6.1.4.1.20916.1.7.1.4.1.0"
+	plugin.BatteryCritical = 13
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted battery-critical above battery-warning")
+	}
+}
+
+func TestExecuteCheckNoPerfData(t *testing.T) {
+	setDefaults()
+	plugin.NoPerfData = true
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))})
+	want := "check-tempager-3e-temperature WARNING: lab temperature is 36.00c\n"
+	if state != sensu.CheckStateWarning || out != want {
+		t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out, sensu.CheckStateWarning, want)
+	}
+
+	// json always carries the metrics
+	plugin.Output = "json"
+	_, out = runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))})
+	if !strings.Contains(out, `"metrics":[`) {
+		t.Errorf("json output = %q, want the metrics kept", out)
+	}
+}
+
+func TestExecuteCheckCalibrationOffset(t *testing.T) {
+	setDefaults()
+	plugin.Warning = 21
+	plugin.CalibrationOffset = -1.5
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2150, 2150))})
+	want := "check-tempager-3e-temperature OK: lab temperature is 20.00c | tempager_internal=20.00, tempager_external=20.00\n"
+	if state != sensu.CheckStateOK || out != want {
+		t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out, sensu.CheckStateOK, want)
+	}
+}
+
+// faultedPacket is a response where the external probe isn't there.
+func faultedPacket(location string, internal int) *gosnmp.SnmpPacket {
+	packet := tempagerPacket(location, internal, 0)
+	packet.Variables[2] = gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.20916.1.7.1.2.1.1.0", Type: gosnmp.NoSuchInstance}
+	return packet
+}
+
+func TestExecuteCheckValidateOIDResponses(t *testing.T) {
+	packet := tempagerPacket("lab", 2000, 2150)
+	packet.Variables[1] = gosnmp.SnmpPDU{Name: internalOID, Type: gosnmp.OctetString, Value: []byte("20.0")}
+
+	// normally the string is read for what it says
+	setDefaults()
+	if state, _ := runCheck(t, &fakeClient{get: respond(packet)}); state != sensu.CheckStateOK {
+		t.Errorf("executeCheck() = %d, want OK without validate-oid-responses", state)
+	}
+
+	setDefaults()
+	plugin.ValidateOIDs = true
+	state, out := runCheck(t, &fakeClient{get: respond(packet)})
+	want := "UNKNOWN: unexpected OID responses: " + internalOID + " returned OctetString, expected Integer.\n"
+	if state != sensu.CheckStateUnknown || !strings.HasSuffix(out, want) {
+		t.Errorf("executeCheck() = %d, %q, want UNKNOWN ending %q", state, out, want)
+	}
+
+	// a unit answering to the letter passes
+	if state, _ := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}); state != sensu.CheckStateOK {
+		t.Errorf("executeCheck() = %d, want OK for a conforming response", state)
+	}
+}
+
+func TestExecuteCheckUseGetNext(t *testing.T) {
+	packet := tempagerPacket("lab", 2000, 0)
+	packet.Variables[2] = gosnmp.SnmpPDU{Name: externalOID, Type: gosnmp.NoSuchInstance}
+	getNext := func(oids []string) (*gosnmp.SnmpPacket, error) {
+		return &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{
+			{Name: strings.TrimSuffix(externalOID, ".0") + ".1", Type: gosnmp.Integer, Value: 2150},
+		}}, nil
+	}
+
+	setDefaults()
+	if state, _ := runCheck(t, &fakeClient{get: respond(packet), getNext: getNext}); state == sensu.CheckStateOK {
+		t.Errorf("executeCheck() = %d, want a failure without use-getnext", state)
+	}
+
+	setDefaults()
+	plugin.UseGetNext = true
+	state, out := runCheck(t, &fakeClient{get: respond(packet), getNext: getNext})
+	if state != sensu.CheckStateOK || !strings.Contains(out, "lab temperature is 21.50c") {
+		t.Errorf("executeCheck() = %d, %q, want OK with the value from the GetNext", state, out)
+	}
+}
+
+func TestExecuteCheckUseGetNextV1(t *testing.T) {
+	// a v1 agent without the external .0 instance refuses any Get asking
+	// for it with noSuchName, naming it by position
+	get := func(oids []string) (*gosnmp.SnmpPacket, error) {
+		packet := &gosnmp.SnmpPacket{}
+		for i, oid := range oids {
+			if oid == externalOID {
+				return &gosnmp.SnmpPacket{Error: gosnmp.NoSuchName, ErrorIndex: uint8(i + 1)}, nil
+			}
+			packet.Variables = append(packet.Variables, tempagerAgent("lab", 2000, 0)[oid])
+		}
+		return packet, nil
+	}
+	getNext := func(oids []string) (*gosnmp.SnmpPacket, error) {
+		return &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{
+			{Name: strings.TrimSuffix(externalOID, ".0") + ".1", Type: gosnmp.Integer, Value: 2150},
+		}}, nil
+	}
+
+	setDefaults()
+	plugin.SnmpVersion = "1"
+	if state, out := runCheck(t, &fakeClient{get: get, getNext: getNext}); state != sensu.CheckStateUnknown || !strings.Contains(out, "not found on agent") {
+		t.Errorf("executeCheck() = %d, %q, want the noSuchName without use-getnext", state, out)
+	}
+
+	setDefaults()
+	plugin.SnmpVersion = "1"
+	plugin.UseGetNext = true
+	client := &fakeClient{get: get, getNext: getNext}
+	state, out := runCheck(t, client)
+	if state != sensu.CheckStateOK || !strings.Contains(out, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50") {
+		t.Errorf("executeCheck() = %d, %q, want OK with the value from the GetNext", state, out)
+	}
+	if len(client.gets) != 2 || len(client.gets[1]) != 2 {
+		t.Errorf("Gets = %q, want the Get redone without the external oid", client.gets)
+	}
+}
+
+func TestExecuteCheckDisplayStringReading(t *testing.T) {
+	tests := []struct {
+		external  string
+		wantState int
+		wantOut   string
+	}{
+		{"21.5 (OK)", sensu.CheckStateOK, "OK: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+		{"21.5", sensu.CheckStateOK, "OK: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+		{"30.0 (ALARM)", sensu.CheckStateCritical, "CRITICAL: lab temperature is 30.00c; external probe reports ALARM | tempager_internal=20.00, tempager_external=30.00\n"},
+		{"21.5 (DEGRADED)", sensu.CheckStateWarning, "WARNING: lab temperature is 21.50c; external probe reports DEGRADED | tempager_internal=20.00, tempager_external=21.50\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		packet := tempagerPacket("lab", 2000, 0)
+		packet.Variables[2] = gosnmp.SnmpPDU{Name: externalOID, Type: gosnmp.OctetString, Value: []byte(tt.external)}
+
+		state, out := runCheck(t, &fakeClient{get: respond(packet)})
+		if state != tt.wantState || !strings.HasSuffix(out, tt.wantOut) {
+			t.Errorf("%q: executeCheck() = %d, %q, want %d, %q", tt.external, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestExecuteCheckFallbackToInternal(t *testing.T) {
+	tests := []struct {
+		internal  int
+		wantState int
+		wantOut   string
+	}{
+		{2150, sensu.CheckStateWarning, "check-tempager-3e-temperature WARNING: lab temperature is 21.50c; external probe fault, evaluating the internal sensor | tempager_internal=21.50\n"},
+		{4100, sensu.CheckStateCritical, "check-tempager-3e-temperature CRITICAL: lab temperature is 41.00c; external probe fault, evaluating the internal sensor | tempager_internal=41.00\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.FallbackToInternal = true
+
+		state, out := runCheck(t, &fakeClient{get: respond(faultedPacket("lab", tt.internal))})
+		if state != tt.wantState || out != tt.wantOut {
+			t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestExecuteCheckExternalFaultWithoutFallback(t *testing.T) {
+	setDefaults()
+
+	state, out := runCheck(t, &fakeClient{get: respond(faultedPacket("lab", 2150))})
+	if state != sensu.CheckStateCritical || !strings.Contains(out, "failed to read external temperature.") {
+		t.Errorf("executeCheck() = %d, %q, want a critical external fault", state, out)
+	}
+}
+
+func TestExecuteCheckPartialOK(t *testing.T) {
+	tests := []struct {
+		failed    []int
+		wantState int
+		wantOut   string
+	}{
+		{[]int{1}, sensu.CheckStateOK, "check-tempager-3e-temperature OK: lab temperature is 21.50c; skipped unreadable internal temperature | tempager_external=21.50\n"},
+		{[]int{2}, sensu.CheckStateOK, "check-tempager-3e-temperature OK: lab temperature is 20.00c; skipped unreadable external temperature | tempager_internal=20.00\n"},
+		{[]int{0}, sensu.CheckStateOK, "check-tempager-3e-temperature OK:  temperature is 21.50c; skipped unreadable location | tempager_internal=20.00, tempager_external=21.50\n"},
+		{[]int{1, 2}, sensu.CheckStateCritical, "check-tempager-3e-temperature CRITICAL: failed to read internal temperature.\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.PartialOK = true
+
+		packet := tempagerPacket("lab", 2000, 2150)
+		for _, i := range tt.failed {
+			packet.Variables[i] = gosnmp.SnmpPDU{Name: packet.Variables[i].Name, Type: gosnmp.NoSuchObject}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: respond(packet)})
+		if state != tt.wantState || out != tt.wantOut {
+			t.Errorf("%v: executeCheck() = %d, %q, want %d, %q", tt.failed, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+
+	// without it the first bad value fails the check
+	setDefaults()
+	packet := tempagerPacket("lab", 2000, 2150)
+	packet.Variables[1] = gosnmp.SnmpPDU{Name: packet.Variables[1].Name, Type: gosnmp.NoSuchObject}
+	if state, _ := runCheck(t, &fakeClient{get: respond(packet)}); state != sensu.CheckStateCritical {
+		t.Errorf("state = %d without partial-ok, want %d", state, sensu.CheckStateCritical)
+	}
+}
+
+func TestExecuteCheckIncludeMinMax(t *testing.T) {
+	setDefaults()
+	plugin.IncludeMinMax = true
+	plugin.ExternalMinOID = "1.3.6.1.4.1.20916.1.7.1.2.1.4.0"
+	plugin.ExternalMaxOID = "1.3.6.1.4.1.20916.1.7.1.2.1.5.0"
+
+	a := tempagerAgent("lab", 2000, 2150)
+	a[".1.3.6.1.4.1.20916.1.7.1.2.1.4.0"] = gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.20916.1.7.1.2.1.4.0", Type: gosnmp.Integer, Value: 1825}
+	a[".1.3.6.1.4.1.20916.1.7.1.2.1.5.0"] = gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.20916.1.7.1.2.1.5.0", Type: gosnmp.Integer, Value: 3940}
+
+	_, out := runCheck(t, &fakeClient{get: a.get})
+	if !strings.HasSuffix(out, "tempager_external=21.50, tempager_external_min=18.25, tempager_external_max=39.40\n") {
+		t.Errorf("output = %q, want the min/max perfdata", out)
+	}
+}
+
+func TestExecuteCheckIncludeMinMaxAbsent(t *testing.T) {
+	setDefaults()
+	plugin.IncludeMinMax = true
+	plugin.ExternalMinOID = "1.3.6.1.4.1.20916.1.7.1.2.1.4.0"
+	plugin.ExternalMaxOID = "1.3.6.1.4.1.20916.1.7.1.2.1.5.0"
+
+	state, out := runCheck(t, &fakeClient{get: tempagerAgent("lab", 2000, 2150).get})
+	want := "check-tempager-3e-temperature OK: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"
+	if state != sensu.CheckStateOK || out != want {
+		t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out, sensu.CheckStateOK, want)
+	}
+}
+
+func TestCheckArgsIncludeMinMax(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.IncludeMinMax = true
+	plugin.ExternalMinOID = "1.3.6.1.4.1.20916.1.7.1.2.1.4.0"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted include-minmax without external-max-oid")
+	}
+}
+
+func TestExecuteCheckExitCodeRemap(t *testing.T) {
+	setDefaults()
+	plugin.ExitCritical = 42
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 4100))})
+	if state != 42 {
+		t.Errorf("state = %d, want the remapped critical 42", state)
+	}
+	if !strings.Contains(out, "CRITICAL: lab temperature is 41.00c") {
+		t.Errorf("output = %q, want the output to still say CRITICAL", out)
+	}
+
+	// the other states keep their codes
+	if state, _ := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}); state != sensu.CheckStateOK {
+		t.Errorf("ok state = %d, want %d", state, sensu.CheckStateOK)
+	}
+}
+
+func TestCheckArgsExitCodeRange(t *testing.T) {
+	for _, code := range []int{-1, 256} {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.ExitUnknown = code
+
+		if _, err := checkArgs(nil); err == nil {
+			t.Errorf("checkArgs() accepted exit-unknown %d", code)
+		}
+	}
+}
+
+// runVersions runs executeCheck answering each SNMP version from units,
+// returning the state, output and the versions asked for.
+func runVersions(t *testing.T, units map[string]snmpClient) (int, string, []string) {
+	t.Helper()
+
+	var (
+		out      bytes.Buffer
+		versions []string
+	)
+	oldClient := newClient
+	stdout = &out
+	newClient = func(target string, version string) snmpClient {
+		versions = append(versions, version)
+		return units[version]
+	}
+	defer func() {
+		stdout = os.Stdout
+		newClient = oldClient
+	}()
+
+	state, err := executeCheck(nil)
+	if err != nil {
+		t.Fatalf("executeCheck() error = %v", err)
+	}
+	return state, out.String(), versions
+}
+
+func TestExecuteCheckAutoVersionFallback(t *testing.T) {
+	refused := &gosnmp.SnmpPacket{Error: gosnmp.AuthorizationError}
+	timedOut := func([]string) (*gosnmp.SnmpPacket, error) {
+		return nil, errors.New("request timeout (after 3 retries)")
+	}
+
+	for _, get := range []func([]string) (*gosnmp.SnmpPacket, error){respond(refused), timedOut} {
+		setDefaults()
+		plugin.AutoVersionFallback = true
+
+		v1 := &fakeClient{get: get}
+		units := map[string]snmpClient{
+			"1":  v1,
+			"2c": &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))},
+		}
+
+		state, out, versions := runVersions(t, units)
+		if state != sensu.CheckStateOK || !strings.Contains(out, "OK: lab temperature is 21.50c") {
+			t.Errorf("executeCheck() = %d, %q, want the v2c reading", state, out)
+		}
+		if !reflect.DeepEqual(versions, []string{"1", "2c"}) {
+			t.Errorf("versions = %v, want [1 2c]", versions)
+		}
+		if !v1.closed {
+			t.Error("v1 connection was not closed")
+		}
+	}
+}
+
+func TestExecuteCheckSnmpVersionReported(t *testing.T) {
+	refused := respond(&gosnmp.SnmpPacket{Error: gosnmp.AuthorizationError})
+	answers := respond(tempagerPacket("lab", 2000, 2150))
+
+	tests := []struct {
+		v1          func([]string) (*gosnmp.SnmpPacket, error)
+		wantVersion string
+	}{
+		{answers, "1"},
+		{refused, "2c"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Output = "json"
+		plugin.AutoVersionFallback = true
+		plugin.ReportSnmpVersion = true
+
+		units := map[string]snmpClient{
+			"1":  &fakeClient{get: tt.v1},
+			"2c": &fakeClient{get: answers},
+		}
+
+		_, out, _ := runVersions(t, units)
+		var res checkResult
+		if err := json.Unmarshal([]byte(out), &res); err != nil {
+			t.Fatalf("output %q isn't JSON: %v", out, err)
+		}
+		if res.SnmpVersion != tt.wantVersion {
+			t.Errorf("snmp_version = %q, want %q", res.SnmpVersion, tt.wantVersion)
+		}
+
+		// and as numeric perfdata in the text output
+		plugin.Output = "text"
+		units["1"] = &fakeClient{get: tt.v1}
+		_, out, _ = runVersions(t, units)
+		if want := "tempager_snmp_version=" + strings.TrimSuffix(tt.wantVersion, "c") + "\n"; !strings.HasSuffix(out, want) {
+			t.Errorf("output = %q, want it to end %q", out, want)
+		}
+	}
+}
+
+func TestExecuteCheckWithoutAutoVersionFallback(t *testing.T) {
+	setDefaults()
+
+	units := map[string]snmpClient{
+		"1":  &fakeClient{get: respond(&gosnmp.SnmpPacket{Error: gosnmp.AuthorizationError})},
+		"2c": &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))},
+	}
+
+	state, _, versions := runVersions(t, units)
+	if state != sensu.CheckStateUnknown || len(versions) != 1 {
+		t.Errorf("executeCheck() = %d using %v, want unknown from v1 alone", state, versions)
+	}
+}
+
+func TestExecuteCheckShowRaw(t *testing.T) {
+	setDefaults()
+	plugin.Output = "json"
+	plugin.ShowRaw = true
+
+	_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	var res checkResult
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("output %q isn't JSON: %v", out, err)
+	}
+	if res.RawInternal == nil || *res.RawInternal != 2000 || res.RawExternal == nil || *res.RawExternal != 2150 {
+		t.Errorf("raw values = %v, %v, want 2000, 2150", res.RawInternal, res.RawExternal)
+	}
+
+	// text output and JSON without the flag stay as they were
+	plugin.ShowRaw = false
+	if _, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}); strings.Contains(out, "raw_") {
+		t.Errorf("output = %q, want no raw values", out)
+	}
+	plugin.ShowRaw = true
+	plugin.Output = "text"
+	if _, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}); strings.Contains(out, "2150") {
+		t.Errorf("output = %q, want no raw values in text output", out)
+	}
+}
+
+func TestExecuteCheckMinInterval(t *testing.T) {
+	setDefaults()
+	plugin.StateFile = tempStateFile(t)
+	plugin.MinInterval = 60
+
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	setNow(t, start)
+	client := &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}
+	if _, out := runCheck(t, client); !strings.Contains(out, "lab temperature is 21.50c") {
+		t.Fatalf("first run output = %q, want a reading", out)
+	}
+
+	// too soon, the unit isn't touched
+	setNow(t, start.Add(30*time.Second))
+	client = &fakeClient{get: respond(tempagerPacket("lab", 2000, 4100))}
+	state, out := runCheck(t, client)
+	want := "check-tempager-3e-temperature OK: last polled 30s ago, skipped until min-interval of 60s has passed.\n"
+	if state != sensu.CheckStateOK || out != want {
+		t.Errorf("too soon run = %d, %q, want %d, %q", state, out, sensu.CheckStateOK, want)
+	}
+	if len(client.gets) != 0 {
+		t.Errorf("too soon run issued %d Gets, want none", len(client.gets))
+	}
+
+	// once the interval has passed the unit is polled again
+	setNow(t, start.Add(90*time.Second))
+	state, out = runCheck(t, client)
+	if state != sensu.CheckStateCritical || len(client.gets) != 1 {
+		t.Errorf("elapsed run = %d, %q with %d Gets, want a critical poll", state, out, len(client.gets))
+	}
+}
+
+func TestExecuteCheckProbeVersions(t *testing.T) {
+	setDefaults()
+	plugin.ProbeVersions = true
+
+	silent := func([]string) (*gosnmp.SnmpPacket, error) {
+		return nil, errors.New("request timeout (after 3 retries)")
+	}
+	units := map[string]snmpClient{
+		"1":  &fakeClient{get: silent},
+		"2c": &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))},
+		"3":  &fakeClient{connectErr: errors.New("no route to host")},
+	}
+
+	state, out, versions := runVersions(t, units)
+	want := "check-tempager-3e-temperature OK: unit responds to SNMP 2c.\n"
+	if state != sensu.CheckStateOK || out != want {
+		t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out, sensu.CheckStateOK, want)
+	}
+	if !reflect.DeepEqual(versions, []string{"1", "2c", "3"}) {
+		t.Errorf("versions = %v, want all three tried", versions)
+	}
+}
+
+func TestExecuteCheckProbeVersionsNoneAnswer(t *testing.T) {
+	setDefaults()
+	plugin.ProbeVersions = true
+
+	refused := &fakeClient{get: respond(&gosnmp.SnmpPacket{Error: gosnmp.AuthorizationError})}
+	units := map[string]snmpClient{"1": refused, "2c": refused, "3": refused}
+
+	state, out, _ := runVersions(t, units)
+	if state != sensu.CheckStateOK || !strings.Contains(out, "unit doesn't respond to any SNMP version.") {
+		t.Errorf("executeCheck() = %d, %q, want OK with no versions", state, out)
+	}
+}
+
+func TestHealthScore(t *testing.T) {
+	setDefaults()
+	plugin.Critical = 40
+
+	tests := []struct {
+		readings []float64
+		want     int
+	}{
+		{[]float64{-5, 0}, 100},
+		{[]float64{20, 20}, 50},
+		{[]float64{20, 30}, 38},
+		{[]float64{20, 36}, 30},
+		{[]float64{20, 45}, 25},
+		{[]float64{40, 45}, 0},
+	}
+	last := 101
+	for _, tt := range tests {
+		got, _ := healthScore(tt.readings, above(plugin.Critical))
+		if got != tt.want {
+			t.Errorf("healthScore(%v) = %d, want %d", tt.readings, got, tt.want)
+		}
+		if got >= last {
+			t.Errorf("healthScore(%v) = %d, want it below %d as the readings warm up", tt.readings, got, last)
+		}
+		last = got
+	}
+}
+
+func TestHealthScoreRanges(t *testing.T) {
+	critical, _ := parseRange("15:30")
+	tests := []struct {
+		readings []float64
+		want     int
+	}{
+		// the middle of the range has all the headroom there is
+		{[]float64{22.5}, 100},
+		{[]float64{18.75, 26.25}, 50},
+		{[]float64{15, 31}, 0},
+	}
+	for _, tt := range tests {
+		if got, ok := healthScore(tt.readings, critical); !ok || got != tt.want {
+			t.Errorf("healthScore(%v, 15:30) = %d, %v, want %d", tt.readings, got, ok, tt.want)
+		}
+	}
+
+	// a range that can't alert on the way the reading would go leaves it all
+	if got, _ := healthScore([]float64{20}, thresholdRange{start: 10, end: math.Inf(1)}); got != 100 {
+		t.Errorf("healthScore() = %d with no upper bound, want 100", got)
+	}
+
+	// nothing to score is no score at all
+	if _, ok := healthScore(nil, above(40)); ok {
+		t.Error("healthScore() scored no readings")
+	}
+}
+
+func TestExecuteCheckHealthScore(t *testing.T) {
+	setDefaults()
+	plugin.HealthScore = true
+
+	// a warm external keeps the worst-wins state but only dents the score
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))})
+	if state != sensu.CheckStateWarning || !strings.HasSuffix(out, ", tempager_health_score=30\n") {
+		t.Errorf("executeCheck() = %d, %q, want a warning with a score of 30", state, out)
+	}
+}
+
+func TestExecuteCheckHealthScoreRange(t *testing.T) {
+	setDefaults()
+	plugin.HealthScore = true
+	plugin.CriticalRange = "~:50"
+
+	// scored against the range in force, not the plain critical of 40
+	_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2500, 2500))})
+	if !strings.HasSuffix(out, ", tempager_health_score=50\n") {
+		t.Errorf("executeCheck() = %q, want a score of 50 against the critical-range", out)
+	}
+}
+
+func TestSeverityKeyword(t *testing.T) {
+	tests := []struct {
+		state   int
+		reading float64
+		want    string
+	}{
+		{sensu.CheckStateOK, 21.5, "info"},
+		{sensu.CheckStateWarning, 36, "minor"},
+		{sensu.CheckStateCritical, 41, "major"},
+		{sensu.CheckStateCritical, 44.99, "major"},
+		{sensu.CheckStateCritical, 45, "critical"},
+		{sensu.CheckStateCritical, 60, "critical"},
+	}
+	for _, tt := range tests {
+		if got := severityKeyword(tt.state, tt.reading, above(35), above(40)); got != tt.want {
+			t.Errorf("severityKeyword(%d, %v, 35, 40) = %q, want %q", tt.state, tt.reading, got, tt.want)
+		}
+	}
+
+	// without room between the thresholds there's no telling how far past
+	if got := severityKeyword(sensu.CheckStateCritical, 90, above(40), above(40)); got != "major" {
+		t.Errorf("severityKeyword() = %q with equal thresholds, want major", got)
+	}
+
+	// ranges are measured on the side the reading went out of
+	warning, _ := parseRange("18:27")
+	critical, _ := parseRange("15:30")
+	rangeTests := []struct {
+		reading float64
+		want    string
+	}{
+		{31, "major"},
+		{33, "critical"},
+		{14, "major"},
+		{12, "critical"},
+	}
+	for _, tt := range rangeTests {
+		if got := severityKeyword(sensu.CheckStateCritical, tt.reading, warning, critical); got != tt.want {
+			t.Errorf("severityKeyword(CRITICAL, %v, 18:27, 15:30) = %q, want %q", tt.reading, got, tt.want)
+		}
+	}
+
+	// a CRITICAL the reading isn't behind isn't graded by it
+	if got := severityKeyword(sensu.CheckStateCritical, 21.5, warning, critical); got != "major" {
+		t.Errorf("severityKeyword() = %q for a reading within range, want major", got)
+	}
+}
+
+func TestExecuteCheckSeverityKeyword(t *testing.T) {
+	tests := []struct {
+		external int
+		wantOut  string
+	}{
+		{2150, "OK: lab temperature is 21.50c [severity=info] |"},
+		{3600, "WARNING: lab temperature is 36.00c [severity=minor] |"},
+		{4100, "CRITICAL: lab temperature is 41.00c [severity=major] |"},
+		{4600, "CRITICAL: lab temperature is 46.00c [severity=critical] |"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.SeverityKeyword = true
+
+		_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if !strings.Contains(out, tt.wantOut) {
+			t.Errorf("%d: output = %q, want %q", tt.external, out, tt.wantOut)
+		}
+	}
+}
+
+func TestExecuteCheckSeverityKeywordRange(t *testing.T) {
+	setDefaults()
+	plugin.SeverityKeyword = true
+	plugin.WarningRange = "18:27"
+	plugin.CriticalRange = "15:30"
+
+	// the plain thresholds would call 12c OK, the ranges make it as far
+	// below critical as critical is below warning
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 1200))})
+	if state != sensu.CheckStateCritical || !strings.Contains(out, "[severity=critical]") {
+		t.Errorf("executeCheck() = %d, %q, want CRITICAL graded critical", state, out)
+	}
+}
+
+func TestExecuteCheckDiscoverSensor(t *testing.T) {
+	const (
+		typeOID  = ".1.3.6.1.4.1.20916.1.7.3.1.2"
+		valueOID = ".1.3.6.1.4.1.20916.1.7.3.1.3"
+	)
+
+	// humidity comes first, so the temperature isn't at index 1
+	a := tempagerAgent("lab", 2000, 2150)
+	a[typeOID+".1"] = gosnmp.SnmpPDU{Name: typeOID + ".1", Type: gosnmp.OctetString, Value: []byte("humidity")}
+	a[typeOID+".2"] = gosnmp.SnmpPDU{Name: typeOID + ".2", Type: gosnmp.OctetString, Value: []byte("Temperature")}
+	a[valueOID+".1"] = gosnmp.SnmpPDU{Name: valueOID + ".1", Type: gosnmp.Integer, Value: 5500}
+	a[valueOID+".2"] = gosnmp.SnmpPDU{Name: valueOID + ".2", Type: gosnmp.Integer, Value: 2375}
+
+	setDefaults()
+	plugin.DiscoverSensor = true
+	plugin.SensorTypeOID = typeOID
+	plugin.SensorValueOID = strings.TrimPrefix(valueOID, ".")
+
+	client := &fakeClient{get: a.get, walk: a.walk}
+	state, out := runCheck(t, client)
+	if state != sensu.CheckStateOK || !strings.Contains(out, "lab temperature is 23.75c") {
+		t.Errorf("executeCheck() = %d, %q, want the discovered 23.75c", state, out)
+	}
+	if got := client.gets[0][2]; got != valueOID+".2" {
+		t.Errorf("external OID = %s, want %s", got, valueOID+".2")
+	}
+
+	// a table without a temperature sensor can't be checked
+	delete(a, typeOID+".2")
+	state, out = runCheck(t, &fakeClient{get: a.get, walk: a.walk})
+	if state != sensu.CheckStateCritical || !strings.Contains(out, "no temperature sensor found") {
+		t.Errorf("executeCheck() = %d, %q, want a critical without a temperature sensor", state, out)
+	}
+}
+
+func TestExecuteCheckWarnOnIdenticalSensors(t *testing.T) {
+	tests := []struct {
+		internal  int
+		external  int
+		wantState int
+		wantNote  bool
+	}{
+		{2150, 2150, sensu.CheckStateWarning, true},
+		{2000, 2150, sensu.CheckStateOK, false},
+		{0, 0, sensu.CheckStateOK, false},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.WarnOnIdentical = true
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", tt.internal, tt.external))})
+		if state != tt.wantState || strings.Contains(out, "read identically") != tt.wantNote {
+			t.Errorf("%d/%d: executeCheck() = %d, %q, want %d", tt.internal, tt.external, state, out, tt.wantState)
+		}
+	}
+
+	// it's opt-in
+	setDefaults()
+	if state, _ := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2150, 2150))}); state != sensu.CheckStateOK {
+		t.Errorf("state = %d without warn-on-identical-sensors, want %d", state, sensu.CheckStateOK)
+	}
+}
+
+func TestExecuteCheckFirmware(t *testing.T) {
+	const firmwareOID = ".1.3.6.1.4.1.20916.1.7.1.9.0"
+
+	tests := []struct {
+		firmware  string
+		wantState int
+		wantNote  bool
+	}{
+		{"v2.1.0", sensu.CheckStateWarning, true},
+		{"V2.1.3 ", sensu.CheckStateWarning, true},
+		{"v2.2.0", sensu.CheckStateOK, false},
+		{"", sensu.CheckStateOK, false},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.CheckFirmware = true
+		plugin.FirmwareOID = firmwareOID
+		plugin.BuggyFirmware = []string{"v2.1.0", "v2.1.3"}
+
+		a := tempagerAgent("lab", 2000, 2150)
+		if tt.firmware != "" {
+			a[firmwareOID] = gosnmp.SnmpPDU{Name: firmwareOID, Type: gosnmp.OctetString, Value: []byte(tt.firmware)}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState || strings.Contains(out, "known to report incorrect scaling") != tt.wantNote {
+			t.Errorf("%q: executeCheck() = %d, %q, want %d", tt.firmware, state, out, tt.wantState)
+		}
+	}
+}
+
+func TestExecuteCheckMinFirmware(t *testing.T) {
+	const firmwareOID = ".1.3.6.1.4.1.20916.1.7.1.9.0"
+
+	tests := []struct {
+		firmware  string
+		wantState int
+		wantNote  string
+	}{
+		{"v2.0.9", sensu.CheckStateWarning, "; firmware v2.0.9 is older than the minimum of 2.1"},
+		{"v2.1", sensu.CheckStateOK, ""},
+		{"2.1.0", sensu.CheckStateOK, ""},
+		{"v2.10.1-beta", sensu.CheckStateOK, ""},
+		{"unreleased", sensu.CheckStateOK, `; firmware "unreleased" can't be compared with min-firmware`},
+		{"", sensu.CheckStateOK, ""},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.FirmwareOID = firmwareOID
+		plugin.MinFirmware = "2.1"
+
+		a := tempagerAgent("lab", 2000, 2150)
+		if tt.firmware != "" {
+			a[firmwareOID] = gosnmp.SnmpPDU{Name: firmwareOID, Type: gosnmp.OctetString, Value: []byte(tt.firmware)}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState || !strings.Contains(out, "lab temperature is 21.50c"+tt.wantNote+" |") {
+			t.Errorf("%q: executeCheck() = %d, %q, want %d with %q", tt.firmware, state, out, tt.wantState, tt.wantNote)
+		}
+	}
+}
+
+func TestFirmwareBelow(t *testing.T) {
+	tests := []struct {
+		firmware  string
+		min       string
+		wantBelow bool
+		wantOK    bool
+	}{
+		{"1.9", "2.0", true, true},
+		{"2.0", "2.0.0", false, true},
+		{"2.0.1", "2.0", false, true},
+		{"2.9", "2.10", true, true},
+		{"V3.0 build 12", "2.10", false, true},
+		{"", "2.0", false, false},
+		{"beta", "2.0", false, false},
+	}
+	for _, tt := range tests {
+		below, ok := firmwareBelow(tt.firmware, tt.min)
+		if below != tt.wantBelow || ok != tt.wantOK {
+			t.Errorf("firmwareBelow(%q, %q) = %v, %v, want %v, %v", tt.firmware, tt.min, below, ok, tt.wantBelow, tt.wantOK)
+		}
+	}
+}
+
+func TestCheckArgsMinFirmware(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.MinFirmware = "2.1"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted min-firmware without firmware-oid")
+	}
+
+	plugin.FirmwareOID = ".1.3.6.1.4.1.20916.1.7.1.9.0"
+	plugin.MinFirmware = "latest"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a min-firmware that isn't a version")
+	}
+}
+
+func TestExecuteCheckDetectUnit(t *testing.T) {
+	const unitOID = ".1.3.6.1.4.1.20916.1.7.1.10.0"
+
+	tests := []struct {
+		unit      string
+		internal  int
+		external  int
+		wantState int
+		wantOut   string
+	}{
+		{"F", 6800, 9680, sensu.CheckStateWarning, "lab temperature is 36.00c | tempager_internal=20.00, tempager_external=36.00\n"},
+		{"Fahrenheit", 6800, 7070, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+		{"K", 29315, 31415, sensu.CheckStateCritical, "lab temperature is 41.00c | tempager_internal=20.00, tempager_external=41.00\n"},
+		{"C", 2000, 2150, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+		{"", 2000, 2150, sensu.CheckStateOK, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.DetectUnit = true
+		plugin.UnitOID = unitOID
+
+		a := tempagerAgent("lab", tt.internal, tt.external)
+		if tt.unit != "" {
+			a[unitOID] = gosnmp.SnmpPDU{Name: unitOID, Type: gosnmp.OctetString, Value: []byte(tt.unit)}
+		}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState || !strings.HasSuffix(out, tt.wantOut) {
+			t.Errorf("%q: executeCheck() = %d, %q, want %d, %q", tt.unit, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+func TestCheckArgsDetectUnit(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.DetectUnit = true
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted detect-unit without unit-oid")
+	}
+}
+
+func TestExecuteCheckPerSensorUnits(t *testing.T) {
+	tests := []struct {
+		external  int
+		warning   float64
+		critical  float64
+		wantState int
+		wantOut   string
+	}{
+		// 86f is only 30c, under both thresholds
+		{8600, 32, 38, sensu.CheckStateOK, "lab temperature is 30.00c | tempager_internal=20.00, tempager_external=30.00\n"},
+		{9500, 32, 38, sensu.CheckStateWarning, "lab temperature is 35.00c | tempager_internal=20.00, tempager_external=35.00\n"},
+		{10400, 32, 38, sensu.CheckStateCritical, "lab temperature is 40.00c | tempager_internal=20.00, tempager_external=40.00\n"},
+		{9500, 36, 45, sensu.CheckStateOK, "lab temperature is 35.00c | tempager_internal=20.00, tempager_external=35.00\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Unit = "c"
+		plugin.ExternalUnit = "f"
+		plugin.Warning = tt.warning
+		plugin.Critical = tt.critical
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if state != tt.wantState || !strings.HasSuffix(out, tt.wantOut) {
+			t.Errorf("%d at %v/%v: executeCheck() = %d, %q, want %d, %q", tt.external, tt.warning, tt.critical, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+
+	// the override works the other way round too
+	setDefaults()
+	plugin.Unit = "f"
+	plugin.InternalUnit = "c"
+	if _, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 7070))}); !strings.HasSuffix(out, "lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n") {
+		t.Errorf("executeCheck() = %q, want the internal sensor left in celsius", out)
+	}
+}
+
+func TestCheckArgsUnit(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.ExternalUnit = "rankine"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted an external-unit of rankine")
+	}
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Unit = "f"
+	plugin.DetectUnit = true
+	plugin.UnitOID = "1.3.6.1.4.1.20916.1.7.1.10.0"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted unit with detect-unit")
+	}
+}
+
+func TestExecuteCheckAbsoluteMinimum(t *testing.T) {
+	tests := []struct {
+		internal  int
+		external  int
+		minimum   float64
+		wantState int
+		wantOut   string
+	}{
+		{2000, -27315, -273.15, sensu.CheckStateUnknown, "external probe reading of -273.15c indicates a probe fault."},
+		{2000, -27314, -273.15, sensu.CheckStateOK, "lab temperature is -273.14c"},
+		{-27400, 2150, -273.15, sensu.CheckStateUnknown, "internal sensor reading of -274.00c indicates a probe fault."},
+		{2000, -5000, -50, sensu.CheckStateUnknown, "external probe reading of -50.00c indicates a probe fault."},
+		{2000, -4999, -50, sensu.CheckStateOK, "lab temperature is -49.99c"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.AbsoluteMinimum = tt.minimum
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", tt.internal, tt.external))})
+		if state != tt.wantState || !strings.Contains(out, tt.wantOut) {
+			t.Errorf("%d/%d: executeCheck() = %d, %q, want %d with %q", tt.internal, tt.external, state, out, tt.wantState, tt.wantOut)
+		}
+	}
+}
+
+// runUnits runs executeCheck answering each target from units, returning the
+// state and output.
+func runUnits(t *testing.T, units map[string]snmpClient) (int, string) {
+	t.Helper()
+
+	var out bytes.Buffer
+	oldClient := newClient
+	stdout = &out
+	newClient = func(target string, version string) snmpClient { return units[target] }
+	defer func() {
+		stdout = os.Stdout
+		newClient = oldClient
+	}()
+
+	state, err := executeCheck(nil)
+	if err != nil {
+		t.Fatalf("executeCheck() error = %v", err)
+	}
+	return state, out.String()
+}
+
+func TestExecuteCheckHumidityTarget(t *testing.T) {
+	const humidityOID = ".1.3.6.1.4.1.20916.1.7.1.3.1.1.0"
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.HumidityTarget = "192.0.2.2"
+	plugin.HumidityOID = humidityOID
+
+	hygrometer := agent{humidityOID: gosnmp.SnmpPDU{Name: humidityOID, Type: gosnmp.Integer, Value: 4550}}
+	temperature := &fakeClient{get: tempagerAgent("lab", 2000, 2150).get}
+	humidity := &fakeClient{get: hygrometer.get}
+
+	state, out := runUnits(t, map[string]snmpClient{"192.0.2.1": temperature, "192.0.2.2": humidity})
+	want := "check-tempager-3e-temperature OK: lab temperature is 21.50c with 45.50% humidity | tempager_internal=20.00, tempager_external=21.50, tempager_humidity=45.50\n"
+	if state != sensu.CheckStateOK || out != want {
+		t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out, sensu.CheckStateOK, want)
+	}
+
+	// each unit only got asked for its own values
+	for _, get := range temperature.gets {
+		if reflect.DeepEqual(get, []string{humidityOID}) {
+			t.Error("humidity was read from the temperature unit")
+		}
+	}
+	if len(humidity.gets) != 1 || !humidity.closed {
+		t.Errorf("humidity unit got %d Gets, closed %v, want 1 and closed", len(humidity.gets), humidity.closed)
+	}
+}
+
+func TestExecuteCheckHumiditySameUnit(t *testing.T) {
+	const humidityOID = ".1.3.6.1.4.1.20916.1.7.1.3.1.1.0"
+
+	setDefaults()
+	plugin.HumidityOID = humidityOID
+
+	a := tempagerAgent("lab", 2000, 2150)
+	a[humidityOID] = gosnmp.SnmpPDU{Name: humidityOID, Type: gosnmp.Integer, Value: 3000}
+	if _, out := runCheck(t, &fakeClient{get: a.get}); !strings.HasSuffix(out, ", tempager_humidity=30.00\n") {
+		t.Errorf("output = %q, want the humidity perfdata", out)
+	}
+
+	// a unit without humidity is checked as normal
+	delete(a, humidityOID)
+	if _, out := runCheck(t, &fakeClient{get: a.get}); strings.Contains(out, "humidity") {
+		t.Errorf("output = %q, want no humidity", out)
+	}
+}
+
+func TestExecuteCheckRequireHumidity(t *testing.T) {
+	const humidityOID = ".1.3.6.1.4.1.20916.1.7.1.3.1.1.0"
+
+	tests := []struct {
+		humidity  bool
+		state     string
+		wantState int
+	}{
+		{true, "warning", sensu.CheckStateOK},
+		{false, "warning", sensu.CheckStateWarning},
+		{false, "critical", sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.HumidityOID = humidityOID
+		plugin.RequireHumidity = true
+		plugin.MissingHumidity = tt.state
+
+		a := tempagerAgent("lab", 2000, 2150)
+		if tt.humidity {
+			a[humidityOID] = gosnmp.SnmpPDU{Name: humidityOID, Type: gosnmp.Integer, Value: 3000}
+		}
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != tt.wantState || strings.Contains(out, "humidity unavailable") == tt.humidity {
+			t.Errorf("humidity %v, %s: executeCheck() = %d, %q, want %d", tt.humidity, tt.state, state, out, tt.wantState)
+		}
+	}
+}
+
+func TestCheckArgsRequireHumidity(t *testing.T) {
+	tests := []struct {
+		humidityOID string
+		state       string
+	}{
+		{"", "warning"},
+		{".1.3.6.1.4.1.20916.1.7.1.3.1.1.0", "ok"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.RequireHumidity = true
+		plugin.HumidityOID = tt.humidityOID
+		plugin.MissingHumidity = tt.state
+
+		if _, err := checkArgs(nil); err == nil {
+			t.Errorf("checkArgs() accepted require-humidity with humidity-oid %q and state %q", tt.humidityOID, tt.state)
+		}
+	}
+}
+
+func TestExecuteCheckEmitHeartbeat(t *testing.T) {
+	tests := []struct {
+		client  *fakeClient
+		wantOut string
+	}{
+		{&fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}, "check-tempager-3e-temperature OK: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50, tempager_up=1\n"},
+		{&fakeClient{get: respond(faultedPacket("lab", 2000))}, "check-tempager-3e-temperature CRITICAL: failed to read external temperature. | tempager_up=1\n"},
+		{&fakeClient{connectErr: errors.New("no route to host")}, "check-tempager-3e-temperature CRITICAL: failed to connect to tempager. | tempager_up=0\n"},
+		{&fakeClient{get: func([]string) (*gosnmp.SnmpPacket, error) { return nil, errors.New("request timeout") }}, "check-tempager-3e-temperature CRITICAL: timed out gathering oids. | tempager_up=0\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.EmitHeartbeat = true
+
+		if _, out := runCheck(t, tt.client); out != tt.wantOut {
+			t.Errorf("output = %q, want %q", out, tt.wantOut)
+		}
+	}
+}
+
+func TestExecuteCheckSkipPerfdataBelow(t *testing.T) {
+	const humidityOID = ".1.3.6.1.4.1.20916.1.7.1.3.1.1.0"
+
+	tests := []struct {
+		external int
+		wantOut  string
+	}{
+		{1800, "check-tempager-3e-temperature OK: lab temperature is 18.00c with 45.00% humidity | tempager_internal=20.00, tempager_external=18.00\n"},
+		{2150, "check-tempager-3e-temperature OK: lab temperature is 21.50c with 45.00% humidity | tempager_internal=20.00, tempager_external=21.50, tempager_external_min=18.25, tempager_external_max=39.40, tempager_humidity=45.00\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.SkipPerfdataBelow = 20
+		plugin.HumidityOID = humidityOID
+		plugin.IncludeMinMax = true
+		plugin.ExternalMinOID = "1.3.6.1.4.1.20916.1.7.1.2.1.4.0"
+		plugin.ExternalMaxOID = "1.3.6.1.4.1.20916.1.7.1.2.1.5.0"
+
+		a := tempagerAgent("lab", 2000, tt.external)
+		a[humidityOID] = gosnmp.SnmpPDU{Name: humidityOID, Type: gosnmp.Integer, Value: 4500}
+		a[".1.3.6.1.4.1.20916.1.7.1.2.1.4.0"] = gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.20916.1.7.1.2.1.4.0", Type: gosnmp.Integer, Value: 1825}
+		a[".1.3.6.1.4.1.20916.1.7.1.2.1.5.0"] = gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.20916.1.7.1.2.1.5.0", Type: gosnmp.Integer, Value: 3940}
+
+		if _, out := runCheck(t, &fakeClient{get: a.get}); out != tt.wantOut {
+			t.Errorf("%d: output = %q, want %q", tt.external, out, tt.wantOut)
+		}
+	}
+}
+
+func TestExecuteCheckSkipPerfdataBelowStillChecks(t *testing.T) {
+	const statusOID = ".1.3.6.1.4.1.20916.1.7.1.2.1.6.0"
+
+	tests := []struct {
+		name     string
+		status   string
+		critical string
+		wantOut  string
+	}{
+		{"disconnected probe", "disconnected", "", "CRITICAL: lab temperature is 5.00c; external probe is disconnected, the reading may be stale | tempager_internal=20.00, tempager_external=5.00, tempager_external_min=18.25\n"},
+		{"critical range", "ok", "@~:10", "CRITICAL: lab temperature is 5.00c | tempager_internal=20.00, tempager_external=5.00, tempager_external_min=18.25\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.SkipPerfdataBelow = 20
+		plugin.CheckProbeHealth = true
+		plugin.ProbeStatusOID = statusOID
+		plugin.IncludeMinMax = true
+		plugin.ExternalMinOID = "1.3.6.1.4.1.20916.1.7.1.2.1.4.0"
+		plugin.ExternalMaxOID = "1.3.6.1.4.1.20916.1.7.1.2.1.5.0"
+		plugin.CriticalRange = tt.critical
+
+		// a probe serving a stale cold value isn't trimmed down to an OK
+		a := tempagerAgent("lab", 2000, 500)
+		a[statusOID] = gosnmp.SnmpPDU{Name: statusOID, Type: gosnmp.OctetString, Value: []byte(tt.status)}
+		a[".1.3.6.1.4.1.20916.1.7.1.2.1.4.0"] = gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.20916.1.7.1.2.1.4.0", Type: gosnmp.Integer, Value: 1825}
+
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != sensu.CheckStateCritical || !strings.HasSuffix(out, tt.wantOut) {
+			t.Errorf("%s: executeCheck() = %d, %q, want CRITICAL ending %q", tt.name, state, out, tt.wantOut)
+		}
+	}
+}
+
+func TestCheckArgsSkipPerfdataBelow(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.StateFile = tempStateFile(t)
+	plugin.SkipPerfdataBelow = 20
+	plugin.DegreesDelta = true
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted skip-perfdata-if-ok-below with degrees-delta")
+	}
+}
+
+func TestExecuteCheckExpectedProbeCount(t *testing.T) {
+	const (
+		countOID = ".1.3.6.1.4.1.20916.1.7.1.5.0"
+		keyOID   = ".1.3.6.1.4.1.20916.1.7.2.1.2"
+		valueOID = ".1.3.6.1.4.1.20916.1.7.2.1.3"
+	)
+
+	a := tempagerAgent("lab", 2000, 2150)
+	a[countOID] = gosnmp.SnmpPDU{Name: countOID, Type: gosnmp.Integer, Value: 2}
+	a[keyOID+".1"] = gosnmp.SnmpPDU{Name: keyOID + ".1", Type: gosnmp.OctetString, Value: []byte("2f1e0c6a-0001")}
+	a[keyOID+".2"] = gosnmp.SnmpPDU{Name: keyOID + ".2", Type: gosnmp.OctetString, Value: []byte("9b3d7e42-0002")}
+	a[keyOID+".3"] = gosnmp.SnmpPDU{Name: keyOID + ".3", Type: gosnmp.OctetString, Value: []byte("51c0aa17-0003")}
+	a[valueOID+".1"] = gosnmp.SnmpPDU{Name: valueOID + ".1", Type: gosnmp.Integer, Value: 2150}
+
+	tests := []struct {
+		countOID  string
+		expected  int
+		wantState int
+		wantNote  string
+	}{
+		{countOID, 2, sensu.CheckStateOK, ""},
+		{countOID, 3, sensu.CheckStateWarning, "; 2 probes reported, expected 3"},
+		// without the count OID the table rows are counted
+		{"", 3, sensu.CheckStateOK, ""},
+		{"", 2, sensu.CheckStateWarning, "; 3 probes reported, expected 2"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.ExpectedProbeCount = tt.expected
+		plugin.ProbeCountOID = tt.countOID
+		plugin.ProbeKey = "2f1e0c6a-0001"
+		plugin.ProbeKeyOID = keyOID
+		plugin.ProbeValueOID = valueOID
+
+		state, out := runCheck(t, &fakeClient{get: a.get, walk: a.walk})
+		if state != tt.wantState || !strings.Contains(out, "lab temperature is 21.50c"+tt.wantNote+" |") {
+			t.Errorf("%q/%d: executeCheck() = %d, %q, want %d with %q", tt.countOID, tt.expected, state, out, tt.wantState, tt.wantNote)
+		}
+	}
+}
+
+func TestExecuteCheckMaxProbeCount(t *testing.T) {
+	const (
+		typeOID  = ".1.3.6.1.4.1.20916.1.7.2.1.1"
+		valueOID = ".1.3.6.1.4.1.20916.1.7.2.1.3"
+	)
+
+	a := tempagerAgent("lab", 2000, 2150)
+	a[typeOID+".1"] = gosnmp.SnmpPDU{Name: typeOID + ".1", Type: gosnmp.OctetString, Value: []byte("temperature")}
+	a[typeOID+".2"] = gosnmp.SnmpPDU{Name: typeOID + ".2", Type: gosnmp.OctetString, Value: []byte("humidity")}
+	a[typeOID+".3"] = gosnmp.SnmpPDU{Name: typeOID + ".3", Type: gosnmp.OctetString, Value: []byte("temperature")}
+	a[valueOID+".1"] = gosnmp.SnmpPDU{Name: valueOID + ".1", Type: gosnmp.Integer, Value: 2150}
+
+	tests := []struct {
+		max       int
+		maxState  string
+		wantState int
+		wantNote  string
+	}{
+		// the humidity row isn't a probe
+		{2, "critical", sensu.CheckStateOK, ""},
+		{3, "critical", sensu.CheckStateOK, ""},
+		{1, "critical", sensu.CheckStateCritical, "; 2 probes reported, more than the maximum of 1"},
+		{1, "warning", sensu.CheckStateWarning, "; 2 probes reported, more than the maximum of 1"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.MaxProbeCount = tt.max
+		plugin.MaxProbeCountState = tt.maxState
+		plugin.DiscoverSensor = true
+		plugin.SensorTypeOID = typeOID
+		plugin.SensorValueOID = valueOID
+
+		state, out := runCheck(t, &fakeClient{get: a.get, walk: a.walk})
+		if state != tt.wantState || !strings.Contains(out, "lab temperature is 21.50c"+tt.wantNote+" |") {
+			t.Errorf("%d/%s: executeCheck() = %d, %q, want %d with %q", tt.max, tt.maxState, state, out, tt.wantState, tt.wantNote)
+		}
+	}
+}
+
+func TestCheckArgsExpectedProbeCount(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.ExpectedProbeCount = 2
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted expected-probe-count with nothing to count")
+	}
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.MaxProbeCount = 2
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted max-probe-count with nothing to count")
+	}
+}
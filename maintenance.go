@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"strings"
+	"time"
+)
+
+// weekdays are the day names a maintenance window can be given in
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// maintenanceStates are the states a breach is downgraded to in a window
+var maintenanceStates = map[string]int{
+	"ok":      sensu.CheckStateOK,
+	"warning": sensu.CheckStateWarning,
+}
+
+// maintenanceWindow is a daily span of time on a run of days, "sat-sun
+// 08:00-18:00" say. A span ending before it starts runs past midnight into
+// the next day.
+type maintenanceWindow struct {
+	first time.Weekday
+	last  time.Weekday
+	start int // minutes past midnight
+	end   int
+}
+
+// parseMaintenanceWindow parses a window given as "<days> <HH:MM>-<HH:MM>",
+// where days is daily, a day such as sat, or a range of them such as mon-fri.
+func parseMaintenanceWindow(s string) (maintenanceWindow, error) {
+	w, err := parseWindow(s)
+	if err != nil {
+		return w, fmt.Errorf("maintenance window %v", err)
+	}
+	return w, nil
+}
+
+// parseWindow parses "<days> <HH:MM>-<HH:MM>", with errors worded to follow
+// the name of whatever the window is for.
+func parseWindow(s string) (maintenanceWindow, error) {
+	var w maintenanceWindow
+
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) != 2 {
+		return w, fmt.Errorf("%q must be days and a time range, such as sat-sun 08:00-18:00.", s)
+	}
+
+	days := strings.SplitN(fields[0], "-", 2)
+	switch {
+	case fields[0] == "daily":
+		w.first, w.last = time.Sunday, time.Saturday
+	case len(days) == 1:
+		day, ok := weekdays[days[0]]
+		if !ok {
+			return w, fmt.Errorf("%q has an unknown day %q.", s, days[0])
+		}
+		w.first, w.last = day, day
+	default:
+		first, ok1 := weekdays[days[0]]
+		last, ok2 := weekdays[days[1]]
+		if !ok1 || !ok2 {
+			return w, fmt.Errorf("%q has an unknown day range %q.", s, fields[0])
+		}
+		w.first, w.last = first, last
+	}
+
+	times := strings.SplitN(fields[1], "-", 2)
+	if len(times) != 2 {
+		return w, fmt.Errorf("%q must have a time range such as 08:00-18:00.", s)
+	}
+	var err error
+	if w.start, err = minutesPastMidnight(times[0]); err != nil {
+		return w, fmt.Errorf("%q has a bad start time: %v", s, err)
+	}
+	if w.end, err = minutesPastMidnight(times[1]); err != nil {
+		return w, fmt.Errorf("%q has a bad end time: %v", s, err)
+	}
+	if w.start == w.end {
+		return w, fmt.Errorf("%q is empty.", s)
+	}
+	return w, nil
+}
+
+// minutesPastMidnight parses HH:MM.
+func minutesPastMidnight(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// onDay reports whether day falls within the window's run of days, which may
+// wrap round the end of the week.
+func (w maintenanceWindow) onDay(day time.Weekday) bool {
+	if w.first <= w.last {
+		return day >= w.first && day <= w.last
+	}
+	return day >= w.first || day <= w.last
+}
+
+// contains reports whether t falls within the window.
+func (w maintenanceWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.onDay(t.Weekday()) && minute >= w.start && minute < w.end
+	}
+
+	// past midnight the window belongs to the day before
+	if minute >= w.start {
+		return w.onDay(t.Weekday())
+	}
+	return minute < w.end && w.onDay((t.Weekday()+6)%7)
+}
+
+// inMaintenance reports whether t falls within any of the maintenance
+// windows. They're validated by checkArgs, so a bad one is just skipped.
+func inMaintenance(t time.Time) bool {
+	for _, s := range plugin.MaintenanceWindows {
+		if w, err := parseMaintenanceWindow(s); err == nil && w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/gosnmp/gosnmp"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowContains(t *testing.T) {
+	// 2020-06-06 is a Saturday
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2020, 6, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		window string
+		t      time.Time
+		want   bool
+	}{
+		{"sat-sun 08:00-18:00", at(6, 8, 0), true},
+		{"sat-sun 08:00-18:00", at(7, 17, 59), true},
+		{"sat-sun 08:00-18:00", at(6, 18, 0), false},
+		{"sat-sun 08:00-18:00", at(5, 12, 0), false},
+		{"Sat 08:00-18:00", at(6, 12, 0), true},
+		{"fri-mon 00:00-23:59", at(8, 12, 0), true},
+		{"fri-mon 00:00-23:59", at(3, 12, 0), false},
+		{"daily 02:00-04:00", at(3, 3, 30), true},
+		{"sat 22:00-02:00", at(6, 23, 0), true},
+		{"sat 22:00-02:00", at(7, 1, 59), true},
+		{"sat 22:00-02:00", at(6, 1, 0), false},
+		{"sat 22:00-02:00", at(7, 23, 0), false},
+	}
+	for _, tt := range tests {
+		w, err := parseMaintenanceWindow(tt.window)
+		if err != nil {
+			t.Fatalf("parseMaintenanceWindow(%q) error = %v", tt.window, err)
+		}
+		if got := w.contains(tt.t); got != tt.want {
+			t.Errorf("%q contains %s = %v, want %v", tt.window, tt.t.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestParseMaintenanceWindowInvalid(t *testing.T) {
+	for _, s := range []string{"", "sat", "sat 08:00", "someday 08:00-18:00", "sat-funday 08:00-18:00", "sat 25:00-26:00", "sat 08:00-08:00"} {
+		if _, err := parseMaintenanceWindow(s); err == nil {
+			t.Errorf("parseMaintenanceWindow(%q) accepted a bad window", s)
+		}
+	}
+}
+
+func TestExecuteCheckMaintenanceWindow(t *testing.T) {
+	saturday := time.Date(2020, 6, 6, 10, 0, 0, 0, time.UTC)
+	monday := time.Date(2020, 6, 8, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		clock     time.Time
+		state     string
+		wantState int
+		wantNote  bool
+	}{
+		{saturday, "warning", sensu.CheckStateWarning, true},
+		{saturday, "ok", sensu.CheckStateOK, true},
+		{monday, "warning", sensu.CheckStateCritical, false},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.MaintenanceWindows = []string{"sat-sun 06:00-20:00"}
+		plugin.MaintenanceState = tt.state
+		setNow(t, tt.clock)
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 4100))})
+		if state != tt.wantState || strings.Contains(out, "in a maintenance window") != tt.wantNote {
+			t.Errorf("%s/%s: executeCheck() = %d, %q, want %d", tt.clock.Weekday(), tt.state, state, out, tt.wantState)
+		}
+	}
+}
+
+func TestExecuteCheckMaintenanceWindowDoorOpen(t *testing.T) {
+	const doorOID = ".1.3.6.1.4.1.20916.1.7.1.7.1.0"
+
+	tests := []struct {
+		external int
+		wantNote bool
+	}{
+		{2150, false},
+		{4100, true},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.MaintenanceWindows = []string{"sat-sun 06:00-20:00"}
+		plugin.MaintenanceState = "ok"
+		plugin.CheckDoor = true
+		plugin.DoorOID = doorOID
+		plugin.DoorOpenState = "critical"
+		setNow(t, time.Date(2020, 6, 6, 10, 0, 0, 0, time.UTC))
+
+		a := tempagerAgent("lab", 2000, tt.external)
+		a[doorOID] = gosnmp.SnmpPDU{Name: doorOID, Type: gosnmp.Integer, Value: 1}
+
+		// only the temperature is let off, the open door still counts
+		state, out := runCheck(t, &fakeClient{get: a.get})
+		if state != sensu.CheckStateCritical || strings.Contains(out, "in a maintenance window") != tt.wantNote {
+			t.Errorf("%d: executeCheck() = %d, %q, want CRITICAL for the open door", tt.external, state, out)
+		}
+	}
+}
+
+func TestCheckArgsMaintenance(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.MaintenanceWindows = []string{"weekends 08:00-18:00"}
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a bad maintenance window")
+	}
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.MaintenanceState = "critical"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a maintenance-state of critical")
+	}
+}
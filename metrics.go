@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metric is a single data point emitted alongside the check result. The
+// value is kept preformatted so every output format renders it the same.
+type metric struct {
+	Name  string      `json:"name"`
+	Value json.Number `json:"value"`
+}
+
+// temperatureMetric returns a metric for a reading in degrees.
+func temperatureMetric(name string, value float64) metric {
+	return metric{name, json.Number(fmt.Sprintf("%.2f", value))}
+}
+
+// slugLocation lowercases location and replaces anything but ASCII letters
+// and digits with underscores, so it's safe in a metric name.
+func slugLocation(location string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(location))
+}
+
+// prefixMetrics returns a copy of metrics with each name prefixed by prefix.
+func prefixMetrics(prefix string, metrics []metric) []metric {
+	prefixed := make([]metric, len(metrics))
+	for i, m := range metrics {
+		prefixed[i] = metric{prefix + "_" + m.Name, m.Value}
+	}
+	return prefixed
+}
+
+// sensorMetrics are the metrics that carry a probe's reading, which
+// dedupe-metrics collapses when neighbouring probes read the same
+var sensorMetrics = map[string]bool{
+	"tempager_internal": true,
+	"tempager_external": true,
+}
+
+// knownMetrics are the names of the metrics the check can emit, which are
+// all a perf-min or perf-max can be given for
+var knownMetrics = map[string]bool{
+	"check_duration_ms":           true,
+	"emergency":                   true,
+	"tempager_battery_volts":      true,
+	"tempager_co2_ppm":            true,
+	"tempager_door_open":          true,
+	"tempager_external":           true,
+	"tempager_external_delta":     true,
+	"tempager_external_ema":       true,
+	"tempager_external_max":       true,
+	"tempager_external_min":       true,
+	"tempager_health_score":       true,
+	"tempager_humidity":           true,
+	"tempager_if_in_errors":       true,
+	"tempager_if_out_errors":      true,
+	"tempager_internal":           true,
+	"tempager_margin_to_critical": true,
+	"tempager_margin_to_warning":  true,
+	"tempager_rtt_ms":             true,
+	"tempager_setpoint_deviation": true,
+	"tempager_snmp_version":       true,
+	"tempager_up":                 true,
+}
+
+// dedupeMetrics collapses each run of consecutive sensor metrics with the
+// same value into the first of them, followed by a <name>_count metric with
+// the length of the run. Everything else is left as it is.
+func dedupeMetrics(metrics []metric) []metric {
+	var deduped []metric
+	for i := 0; i < len(metrics); {
+		m := metrics[i]
+		run := 1
+		for sensorMetrics[m.Name] && i+run < len(metrics) && sensorMetrics[metrics[i+run].Name] && metrics[i+run].Value == m.Value {
+			run++
+		}
+
+		deduped = append(deduped, m)
+		if run > 1 {
+			deduped = append(deduped, metric{m.Name + "_count", json.Number(strconv.Itoa(run))})
+		}
+		i += run
+	}
+	return deduped
+}
+
+// validMetricFormat reports whether format is a Sensu output_metric_format.
+func validMetricFormat(format string) bool {
+	for _, f := range corev2.OutputMetricFormats {
+		if format == f {
+			return true
+		}
+	}
+	return false
+}
+
+// perfData renders metrics as the perfdata after the pipe. Sensu's
+// nagios_perfdata parser wants them space separated, otherwise the original
+// comma separated form is kept.
+func perfData(metrics []metric) string {
+	sep := ", "
+	if plugin.OutputMetricFormat == corev2.NagiosOutputMetricFormat {
+		sep = " "
+	}
+
+	points := make([]string, len(metrics))
+	for i, m := range metrics {
+		points[i] = fmt.Sprintf("%s=%s", m.Name, m.Value)
+
+		// the bounds come after the empty warning and critical fields
+		min, max := perfBound(plugin.PerfMin, m.Name), perfBound(plugin.PerfMax, m.Name)
+		if min != "" || max != "" {
+			points[i] += ";;;" + min
+		}
+		if max != "" {
+			points[i] += ";" + max
+		}
+	}
+	return strings.Join(points, sep)
+}
+
+// perfBound returns the bound in bounds for the metric name, or an empty
+// string when it has none. With slug-location the name is matched after the
+// location prefix, and bounds only holds known metric names, so at most one
+// of them can follow it.
+func perfBound(bounds map[string]string, name string) string {
+	if bound, ok := bounds[name]; ok {
+		return bound
+	}
+	if !plugin.SlugLocation {
+		return ""
+	}
+	for i := strings.Index(name, "_"); i >= 0; i = strings.Index(name, "_") {
+		name = name[i+1:]
+		if bound, ok := bounds[name]; ok && knownMetrics[name] {
+			return bound
+		}
+	}
+	return ""
+}
+
+// formatMetrics renders metrics one per line in the given Sensu
+// output_metric_format, tagged with the target and any metric-tags and
+// timestamped with ts.
+func formatMetrics(format string, target string, metrics []metric, ts time.Time) string {
+	keys := make([]string, 0, len(plugin.MetricTags))
+	for key := range plugin.MetricTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// each format has its own way of writing the tags out
+	var graphite, opentsdb, influx, prometheus string
+	for _, key := range keys {
+		value := plugin.MetricTags[key]
+		graphite += fmt.Sprintf(";%s=%s", key, value)
+		opentsdb += fmt.Sprintf(" %s=%s", key, value)
+		influx += fmt.Sprintf(",%s=%s", key, value)
+		prometheus += fmt.Sprintf(",%s=%q", key, value)
+	}
+
+	var b strings.Builder
+	for _, m := range metrics {
+		switch format {
+		case corev2.GraphiteOutputMetricFormat:
+			fmt.Fprintf(&b, "%s%s %s %d\n", m.Name, graphite, m.Value, ts.Unix())
+		case corev2.OpenTSDBOutputMetricFormat:
+			fmt.Fprintf(&b, "%s %d %s target=%s%s\n", m.Name, ts.Unix(), m.Value, target, opentsdb)
+		case corev2.InfluxDBOutputMetricFormat:
+			fmt.Fprintf(&b, "%s,target=%s%s value=%s %d\n", m.Name, target, influx, m.Value, ts.UnixNano())
+		case corev2.PrometheusOutputMetricFormat:
+			fmt.Fprintf(&b, "%s{target=%q%s} %s %d\n", m.Name, target, prometheus, m.Value, ts.UnixNano()/int64(time.Millisecond))
+		}
+	}
+	return b.String()
+}
+
+// validMetricTag reports whether key=value can be written as a tag in every
+// output_metric_format, a key of letters, digits and underscores and a value
+// without spaces or separators.
+func validMetricTag(key string, value string) bool {
+	if key == "" || value == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return !strings.ContainsAny(value, " \t\r\n,;=\"\\{}")
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatMetrics(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+
+	ts := time.Unix(1600000000, 0)
+	metrics := []metric{{"tempager_internal", "20.00"}, {"tempager_external", "21.50"}}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"graphite_plaintext", "tempager_internal 20.00 1600000000\ntempager_external 21.50 1600000000\n"},
+		{"opentsdb_line", "tempager_internal 1600000000 20.00 target=192.0.2.1\ntempager_external 1600000000 21.50 target=192.0.2.1\n"},
+		{"influxdb_line", "tempager_internal,target=192.0.2.1 value=20.00 1600000000000000000\ntempager_external,target=192.0.2.1 value=21.50 1600000000000000000\n"},
+		{"prometheus_text", "tempager_internal{target=\"192.0.2.1\"} 20.00 1600000000000\ntempager_external{target=\"192.0.2.1\"} 21.50 1600000000000\n"},
+	}
+	for _, tt := range tests {
+		if got := formatMetrics(tt.format, plugin.Target, metrics, ts); got != tt.want {
+			t.Errorf("%s: formatMetrics() = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMetricsTags(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.MetricTags = map[string]string{"site": "dub1", "rack": "r12"}
+
+	ts := time.Unix(1600000000, 0)
+	metrics := []metric{{"tempager_internal", "20.00"}, {"tempager_external", "21.50"}}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"graphite_plaintext", "tempager_internal;rack=r12;site=dub1 20.00 1600000000\ntempager_external;rack=r12;site=dub1 21.50 1600000000\n"},
+		{"opentsdb_line", "tempager_internal 1600000000 20.00 target=192.0.2.1 rack=r12 site=dub1\ntempager_external 1600000000 21.50 target=192.0.2.1 rack=r12 site=dub1\n"},
+		{"influxdb_line", "tempager_internal,target=192.0.2.1,rack=r12,site=dub1 value=20.00 1600000000000000000\ntempager_external,target=192.0.2.1,rack=r12,site=dub1 value=21.50 1600000000000000000\n"},
+		{"prometheus_text", "tempager_internal{target=\"192.0.2.1\",rack=\"r12\",site=\"dub1\"} 20.00 1600000000000\ntempager_external{target=\"192.0.2.1\",rack=\"r12\",site=\"dub1\"} 21.50 1600000000000\n"},
+	}
+	for _, tt := range tests {
+		if got := formatMetrics(tt.format, plugin.Target, metrics, ts); got != tt.want {
+			t.Errorf("%s: formatMetrics() = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestCheckArgsMetricTag(t *testing.T) {
+	tests := []struct {
+		format string
+		tags   map[string]string
+		valid  bool
+	}{
+		{"influxdb_line", map[string]string{"site": "dub1", "rack_id": "R-12"}, true},
+		{"", map[string]string{"site": "dub1"}, false},
+		{"nagios_perfdata", map[string]string{"site": "dub1"}, false},
+		{"influxdb_line", map[string]string{"data centre": "dub1"}, false},
+		{"influxdb_line", map[string]string{"site": "dub 1"}, false},
+		{"influxdb_line", map[string]string{"site": "dub,1"}, false},
+		{"influxdb_line", map[string]string{"site": ""}, false},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.OutputMetricFormat = tt.format
+		plugin.MetricTags = tt.tags
+
+		if _, err := checkArgs(nil); (err == nil) != tt.valid {
+			t.Errorf("%s %v: checkArgs() error = %v, want valid %v", tt.format, tt.tags, err, tt.valid)
+		}
+	}
+}
+
+func TestDedupeMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics []metric
+		want    []metric
+	}{
+		{
+			"duplicate",
+			[]metric{{"tempager_internal", "21.50"}, {"tempager_external", "21.50"}, {"tempager_humidity", "45.00"}},
+			[]metric{{"tempager_internal", "21.50"}, {"tempager_internal_count", "2"}, {"tempager_humidity", "45.00"}},
+		},
+		{
+			"distinct",
+			[]metric{{"tempager_internal", "20.00"}, {"tempager_external", "21.50"}, {"tempager_humidity", "45.00"}},
+			[]metric{{"tempager_internal", "20.00"}, {"tempager_external", "21.50"}, {"tempager_humidity", "45.00"}},
+		},
+		{
+			// only sensors are collapsed, whatever else happens to match
+			"not sensors",
+			[]metric{{"tempager_external", "1"}, {"tempager_up", "1"}, {"tempager_door_open", "1"}},
+			[]metric{{"tempager_external", "1"}, {"tempager_up", "1"}, {"tempager_door_open", "1"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := dedupeMetrics(tt.metrics); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: dedupeMetrics() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteCheckDedupeMetrics(t *testing.T) {
+	tests := []struct {
+		internal int
+		want     string
+	}{
+		{2150, "OK: lab temperature is 21.50c | tempager_internal=21.50, tempager_internal_count=2\n"},
+		{2000, "OK: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.DedupeMetrics = true
+
+		if _, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", tt.internal, 2150))}); !strings.HasSuffix(out, tt.want) {
+			t.Errorf("internal %d: output = %q, want %q", tt.internal, out, tt.want)
+		}
+	}
+}
+
+func TestPerfData(t *testing.T) {
+	metrics := []metric{{"tempager_internal", "20.00"}, {"tempager_external", "21.50"}}
+
+	setDefaults()
+	if got, want := perfData(metrics), "tempager_internal=20.00, tempager_external=21.50"; got != want {
+		t.Errorf("perfData() = %q, want %q", got, want)
+	}
+
+	plugin.OutputMetricFormat = "nagios_perfdata"
+	if got, want := perfData(metrics), "tempager_internal=20.00 tempager_external=21.50"; got != want {
+		t.Errorf("nagios_perfdata perfData() = %q, want %q", got, want)
+	}
+}
+
+func TestPerfDataBounds(t *testing.T) {
+	metrics := []metric{{"tempager_internal", "20.00"}, {"tempager_external", "21.50"}, {"lab_tempager_external", "21.50"}}
+
+	setDefaults()
+	plugin.PerfMin = map[string]string{"tempager_external": "-50"}
+	plugin.PerfMax = map[string]string{"tempager_internal": "60", "tempager_external": "150"}
+	want := "tempager_internal=20.00;;;;60, tempager_external=21.50;;;-50;150, lab_tempager_external=21.50"
+	if got := perfData(metrics); got != want {
+		t.Errorf("perfData() = %q, want %q", got, want)
+	}
+
+	// the location prefix is only looked past with slug-location
+	plugin.SlugLocation = true
+	metrics = []metric{{"server_room_tempager_external", "21.50"}, {"server_room_tempager_external_min", "18.00"}}
+	want = "server_room_tempager_external=21.50;;;-50;150, server_room_tempager_external_min=18.00"
+	if got := perfData(metrics); got != want {
+		t.Errorf("slug-location perfData() = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteCheckPerfBounds(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.PerfMin = map[string]string{"tempager_external": "-40"}
+	plugin.PerfMax = map[string]string{"tempager_external": "125"}
+
+	_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	if want := "| tempager_internal=20.00, tempager_external=21.50;;;-40;125\n"; !strings.HasSuffix(out, want) {
+		t.Errorf("output = %q, want it ending %q", out, want)
+	}
+}
+
+func TestCheckArgsPerfBounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		min    map[string]string
+		max    map[string]string
+		format string
+		wantOK bool
+	}{
+		{"min below max", map[string]string{"tempager_external": "-40"}, map[string]string{"tempager_external": "125"}, "", true},
+		{"min only", map[string]string{"tempager_external": "-40"}, map[string]string{}, "nagios_perfdata", true},
+		{"min at max", map[string]string{"tempager_external": "125"}, map[string]string{"tempager_external": "125"}, "", false},
+		{"min above max", map[string]string{"tempager_external": "150"}, map[string]string{"tempager_external": "125"}, "", false},
+		{"not a number", map[string]string{}, map[string]string{"tempager_external": "hot"}, "", false},
+		{"not a metric", map[string]string{"external": "-40"}, map[string]string{}, "", false},
+		{"no perfdata", map[string]string{"tempager_external": "-40"}, map[string]string{}, "graphite_plaintext", false},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.PerfMin = tt.min
+		plugin.PerfMax = tt.max
+		plugin.OutputMetricFormat = tt.format
+
+		if _, err := checkArgs(nil); (err == nil) != tt.wantOK {
+			t.Errorf("%s: checkArgs() = %v, want ok %v", tt.name, err, tt.wantOK)
+		}
+	}
+}
+
+func TestExecuteCheckOutputMetricFormat(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.OutputMetricFormat = "graphite_plaintext"
+	setNow(t, time.Unix(1600000000, 0))
+
+	_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	want := "check-tempager-3e-temperature OK: lab temperature is 21.50c\ntempager_internal 20.00 1600000000\ntempager_external 21.50 1600000000\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestExecuteCheckOutputMetricFormatCritical(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.OutputMetricFormat = "influxdb_line"
+	setNow(t, time.Unix(1600000000, 0))
+
+	// the reason for the state leads, the metrics follow
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 4150))})
+	want := "check-tempager-3e-temperature CRITICAL: lab temperature is 41.50c\n" +
+		"tempager_internal,target=192.0.2.1 value=20.00 1600000000000000000\n" +
+		"tempager_external,target=192.0.2.1 value=41.50 1600000000000000000\n"
+	if state != sensu.CheckStateCritical || out != want {
+		t.Errorf("executeCheck() = %d, %q, want %q", state, out, want)
+	}
+}
+
+func TestCheckArgsOutputMetricFormat(t *testing.T) {
+	for _, format := range []string{"", "nagios_perfdata", "graphite_plaintext", "opentsdb_line", "influxdb_line", "prometheus_text"} {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.OutputMetricFormat = format
+		if _, err := checkArgs(nil); err != nil {
+			t.Errorf("checkArgs() rejected %q: %v", format, err)
+		}
+	}
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.OutputMetricFormat = "collectd"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted output-metric-format collectd")
+	}
+}
+
+func TestSlugLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"Server Room B", "server_room_b"},
+		{"rack-12/top", "rack_12_top"},
+		{"lab", "lab"},
+	}
+	for _, tt := range tests {
+		if got := slugLocation(tt.location); got != tt.want {
+			t.Errorf("slugLocation(%q) = %q, want %q", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteCheckSlugLocation(t *testing.T) {
+	setDefaults()
+	plugin.SlugLocation = true
+
+	_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("Server Room B", 2000, 2150))})
+	want := "check-tempager-3e-temperature OK: Server Room B temperature is 21.50c | server_room_b_tempager_internal=20.00, server_room_b_tempager_external=21.50\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
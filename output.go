@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"os"
+	"strings"
+	"time"
+)
+
+// checkResult is everything a run found out, printed as is by --output json.
+type checkResult struct {
+	Target           string   `json:"target"`
+	Status           int      `json:"status"`
+	State            string   `json:"state"`
+	Summary          string   `json:"summary"`
+	Location         string   `json:"location,omitempty"`
+	SysName          string   `json:"sysname,omitempty"`
+	AssetTag         string   `json:"asset_tag,omitempty"`
+	SnmpVersion      string   `json:"snmp_version,omitempty"`
+	Internal         *float64 `json:"internal,omitempty"`
+	External         *float64 `json:"external,omitempty"`
+	Humidity         *float64 `json:"humidity,omitempty"`
+	RawInternal      *int     `json:"raw_internal,omitempty"`
+	RawExternal      *int     `json:"raw_external,omitempty"`
+	Severity         string   `json:"severity,omitempty"`
+	MarginToWarning  *float64 `json:"margin_to_warning,omitempty"`
+	MarginToCritical *float64 `json:"margin_to_critical,omitempty"`
+	Metrics          []metric `json:"metrics,omitempty"`
+}
+
+// formatJSON renders r as a single line of JSON.
+func formatJSON(r *checkResult) string {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Sprintf("{\"target\":%q,\"status\":3,\"state\":\"UNKNOWN\",\"summary\":%q}\n", r.Target, err.Error())
+	}
+	return string(data) + "\n"
+}
+
+// optionInfo describes a plugin option for --dump-options.
+type optionInfo struct {
+	Path      string      `json:"path"`
+	Argument  string      `json:"argument"`
+	Shorthand string      `json:"shorthand"`
+	Default   interface{} `json:"default"`
+	Usage     string      `json:"usage"`
+}
+
+// dumpOptions prints the plugin options as JSON for tooling that generates
+// check definitions.
+func dumpOptions() (int, error) {
+	infos := make([]optionInfo, len(options))
+	for i, opt := range options {
+		infos[i] = optionInfo{
+			Path:      opt.Path,
+			Argument:  opt.Argument,
+			Shorthand: opt.Shorthand,
+			Default:   opt.Default,
+			Usage:     opt.Usage,
+		}
+	}
+
+	data, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return sensu.CheckStateUnknown, err
+	}
+	fmt.Fprintln(stdout, string(data))
+	return sensu.CheckStateOK, nil
+}
+
+// appendResultLog appends a timestamped line recording r to the file at path.
+func appendResultLog(path string, r *checkResult) error {
+	line := fmt.Sprintf("%s target=%s state=%s", now().UTC().Format(time.RFC3339), r.Target, r.State)
+	if r.Internal != nil {
+		line += fmt.Sprintf(" internal=%.2f", *r.Internal)
+	}
+	if r.External != nil {
+		line += fmt.Sprintf(" external=%.2f", *r.External)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeNagiosCommand submits r to Nagios as a passive result by writing a
+// PROCESS_SERVICE_CHECK_RESULT line to its external command file at path.
+// The file is Nagios' own pipe, so it's never created.
+func writeNagiosCommand(path string, r *checkResult) error {
+	host := plugin.NagiosHost
+	if host == "" {
+		host = r.Target
+	}
+
+	output := fmt.Sprintf("%s: %s", r.State, r.Summary)
+	if len(r.Metrics) > 0 && !plugin.NoPerfData {
+		output += " | " + perfData(r.Metrics)
+	}
+	output = strings.ReplaceAll(output, "\n", " ")
+	line := fmt.Sprintf("[%d] PROCESS_SERVICE_CHECK_RESULT;%s;%s;%d;%s\n", now().Unix(), host, plugin.NagiosService, r.Status, output)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpOptions(t *testing.T) {
+	setDefaults()
+	plugin.DumpOptions = true
+
+	// target isn't needed just to list the options
+	if _, err := checkArgs(nil); err != nil {
+		t.Fatalf("checkArgs() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	state, err := executeCheck(nil)
+	if state != sensu.CheckStateOK || err != nil {
+		t.Fatalf("executeCheck() = %d, %v, want OK", state, err)
+	}
+
+	var infos []optionInfo
+	if err := json.Unmarshal(out.Bytes(), &infos); err != nil {
+		t.Fatalf("output %q isn't JSON: %v", out.String(), err)
+	}
+	if len(infos) != len(options) {
+		t.Fatalf("dumped %d options, want %d", len(infos), len(options))
+	}
+	for i, opt := range options {
+		info := infos[i]
+		if info.Argument != opt.Argument || info.Path != opt.Path || info.Shorthand != opt.Shorthand || info.Usage != opt.Usage {
+			t.Errorf("option %d = %+v, want %s", i, info, opt.Argument)
+		}
+	}
+	if infos[0].Argument != "target" || infos[0].Default != "" {
+		t.Errorf("first option = %+v, want target with an empty default", infos[0])
+	}
+}
+
+func TestResultLog(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.ResultLog = filepath.Join(filepath.Dir(tempStateFile(t)), "results.log")
+	setNow(t, time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	plugin.Output = "json"
+	runCheck(t, &fakeClient{connectErr: errors.New("no route to host")})
+
+	data, err := ioutil.ReadFile(plugin.ResultLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2020-06-01T12:00:00Z target=192.0.2.1 state=OK internal=20.00 external=21.50\n" +
+		"2020-06-01T12:00:00Z target=192.0.2.1 state=CRITICAL\n"
+	if string(data) != want {
+		t.Errorf("result log = %q, want %q", data, want)
+	}
+}
+
+func TestResultLogWriteFailure(t *testing.T) {
+	setDefaults()
+	plugin.ResultLog = filepath.Join(tempStateFile(t), "missing", "results.log")
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))})
+	if state != sensu.CheckStateWarning || !strings.Contains(out, "WARNING: lab temperature is 36.00c") {
+		t.Errorf("executeCheck() = %d, %q, want the WARNING unaffected by the log", state, out)
+	}
+}
+
+func TestNagiosCmdFile(t *testing.T) {
+	tests := []struct {
+		host   string
+		client *fakeClient
+		want   string
+	}{
+		{"", &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))}, "[1590969600] PROCESS_SERVICE_CHECK_RESULT;192.0.2.1;tempager;1;WARNING: lab temperature is 36.00c | tempager_internal=20.00, tempager_external=36.00\n"},
+		{"dc1-tempager", &fakeClient{connectErr: errors.New("no route to host")}, "[1590969600] PROCESS_SERVICE_CHECK_RESULT;dc1-tempager;tempager;2;CRITICAL: failed to connect to tempager.\n"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.NagiosCmdFile = tempStateFile(t)
+		plugin.NagiosHost = tt.host
+		setNow(t, time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+		if err := ioutil.WriteFile(plugin.NagiosCmdFile, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, out := runCheck(t, tt.client)
+		data, err := ioutil.ReadFile(plugin.NagiosCmdFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("command file = %q, want %q", data, tt.want)
+		}
+		if !strings.HasPrefix(out, "check-tempager-3e-temperature ") {
+			t.Errorf("output = %q, want the normal output as well", out)
+		}
+	}
+}
+
+func TestNagiosCmdFileMissing(t *testing.T) {
+	setDefaults()
+	plugin.NagiosCmdFile = filepath.Join(filepath.Dir(tempStateFile(t)), "nagios.cmd")
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	if state != sensu.CheckStateOK || !strings.Contains(out, "OK: lab temperature is 21.50c") {
+		t.Errorf("executeCheck() = %d, %q, want the result regardless of Nagios", state, out)
+	}
+	if _, err := os.Stat(plugin.NagiosCmdFile); !os.IsNotExist(err) {
+		t.Errorf("command file was created, error = %v", err)
+	}
+}
+
+func TestCheckArgsNagiosCmdFile(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.NagiosCmdFile = "/var/lib/nagios/rw/nagios.cmd"
+	plugin.NagiosService = "temp;erature"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a nagios-service with a semicolon")
+	}
+}
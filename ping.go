@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ping checks host is reachable at all before SNMP is tried, the tests swap
+// in a fake.
+var ping = pingHost
+
+// pingHost sends host an ICMP echo, falling back to a UDP probe where the
+// ICMP socket can't be had, raw sockets not being permitted say.
+func pingHost(host string, timeout time.Duration) error {
+	err := pingICMP(host, timeout)
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return pingUDP(host, timeout)
+	}
+	return err
+}
+
+// pingICMP sends host an ICMP echo request and waits for the reply, over
+// ICMPv6 for an IPv6 host.
+func pingICMP(host string, timeout time.Duration) error {
+	network, request, reply := "ip4:icmp", byte(8), byte(0)
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		network, request, reply = "ip6:ipv6-icmp", 128, 129
+	}
+
+	conn, err := net.DialTimeout(network, host, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	id := os.Getpid() & 0xffff
+	if _, err := conn.Write(echoRequest(request, id, 1)); err != nil {
+		return err
+	}
+
+	// the socket sees every ICMP message from host, not just the reply
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.(*net.IPConn).ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if n >= 8 && buf[0] == reply && int(buf[4])<<8|int(buf[5]) == id {
+			return nil
+		}
+	}
+}
+
+// pingUDP sends a datagram to host's echo port. Either an echo or the port
+// being refused means host is up, only silence counts as unreachable, so a
+// host dropping UDP looks down.
+func pingUDP(host string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(host, "7"), timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write([]byte("tempager")); err != nil {
+		return err
+	}
+	_, err = conn.Read(make([]byte, 64))
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+	return err
+}
+
+// echoRequest builds an ICMP echo request of type typ, 8 for ICMP and 128
+// for ICMPv6, with the given id and sequence. The kernel works out the
+// ICMPv6 checksum itself, so only the ICMP one is filled in.
+func echoRequest(typ byte, id int, seq int) []byte {
+	b := []byte{typ, 0, 0, 0, byte(id >> 8), byte(id), byte(seq >> 8), byte(seq), 't', 'e', 'm', 'p', 'a', 'g', 'e', 'r'}
+	if typ != 8 {
+		return b
+	}
+
+	var sum uint32
+	for i := 0; i < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	sum = sum>>16 + sum&0xffff
+	sum += sum >> 16
+	b[2], b[3] = byte(^sum>>8), byte(^sum)
+	return b
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"github.com/gosnmp/gosnmp"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// usePinger swaps in a pinger answering with err, counting the pings.
+func usePinger(t *testing.T, err error) *int {
+	pings := 0
+	oldPing := ping
+	ping = func(string, time.Duration) error {
+		pings++
+		return err
+	}
+	t.Cleanup(func() { ping = oldPing })
+	return &pings
+}
+
+func TestExecuteCheckPingFirst(t *testing.T) {
+	timeout := func([]string) (*gosnmp.SnmpPacket, error) {
+		return nil, errors.New("request timeout")
+	}
+
+	tests := []struct {
+		name      string
+		pingErr   error
+		failure   string
+		client    *fakeClient
+		wantState int
+		wantOut   string
+		wantGets  int
+	}{
+		{"reachable", nil, "critical", &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}, sensu.CheckStateOK, "lab temperature is 21.50c", 1},
+		{"unreachable", errors.New("i/o timeout"), "critical", &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}, sensu.CheckStateCritical, "tempager is unreachable, it doesn't answer ping.", 0},
+		{"unreachable with its own state", errors.New("i/o timeout"), "warning", &fakeClient{}, sensu.CheckStateWarning, "tempager is unreachable", 0},
+		{"only snmp fails", nil, "critical", &fakeClient{get: timeout}, sensu.CheckStateCritical, "oids, though it answers ping.", 1},
+		{"only snmp connect fails", nil, "critical", &fakeClient{connectErr: errors.New("no route")}, sensu.CheckStateCritical, "failed to connect to tempager, though it answers ping.", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setDefaults()
+			plugin.Target = "192.0.2.1"
+			plugin.PingFirst = true
+			plugin.PingFailureState = tt.failure
+			plugin.TransientErrorState = "critical"
+			pings := usePinger(t, tt.pingErr)
+
+			state, out := runCheck(t, tt.client)
+			if *pings != 1 || state != tt.wantState || !strings.Contains(out, tt.wantOut) || len(tt.client.gets) != tt.wantGets {
+				t.Errorf("executeCheck() = %d, %q after %d pings and %d gets, want %d, %q", state, out, *pings, len(tt.client.gets), tt.wantState, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestExecuteCheckWithoutPingFirst(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	pings := usePinger(t, errors.New("i/o timeout"))
+
+	state, _ := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	if *pings != 0 || state != sensu.CheckStateOK {
+		t.Errorf("executeCheck() = %d after %d pings, want OK without a ping", state, *pings)
+	}
+}
+
+func TestEchoRequestChecksum(t *testing.T) {
+	b := echoRequest(8, 0x1234, 1)
+
+	// the ones' complement sum over a packet with a good checksum is all ones
+	var sum uint32
+	for i := 0; i < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	if b[0] != 8 || b[4] != 0x12 || b[5] != 0x34 || sum != 0xffff {
+		t.Errorf("echoRequest() = %x, want an echo request with id 1234 summing to ffff, got %x", b, sum)
+	}
+}
+
+func TestPingHostFallsBackToUDP(t *testing.T) {
+	// whether or not raw sockets are permitted, ICMP or the UDP probe
+	// answers for the loopback, over IPv6 as well as IPv4
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		conn, err := net.ListenPacket("udp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			t.Logf("no %s loopback to ping: %v", host, err)
+			continue
+		}
+		conn.Close()
+
+		if err := pingHost(host, time.Second); err != nil {
+			t.Errorf("pingHost(%q) = %v, want the loopback reachable", host, err)
+		}
+	}
+}
+
+func TestEchoRequestV6(t *testing.T) {
+	b := echoRequest(128, 0x1234, 1)
+	if b[0] != 128 || b[2] != 0 || b[3] != 0 || b[4] != 0x12 || b[5] != 0x34 {
+		t.Errorf("echoRequest(128) = %x, want an ICMPv6 echo request with id 1234 and the checksum left to the kernel", b)
+	}
+}
+
+func TestCheckArgsPingFirst(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func()
+	}{
+		{"no timeout", func() { plugin.PingTimeout = 0 }},
+		{"socks proxy", func() { plugin.SocksProxy = "192.0.2.2:1080" }},
+		{"replay", func() { plugin.Replay = "testdata/exchange.pcap" }},
+		{"bad failure state", func() { plugin.PingFailureState = "unknown" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setDefaults()
+			plugin.Target = "192.0.2.1"
+			plugin.PingFirst = true
+			tt.setup()
+
+			if _, err := checkArgs(nil); err == nil {
+				t.Error("checkArgs() accepted it")
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// readProfile returns the options set by the named profile in the profile
+// file at path, by argument. The file has a [name] line starting each
+// profile, followed by its option = value lines.
+func readProfile(path string, name string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile-file: %v", err)
+	}
+
+	var (
+		settings map[string]string
+		current  string
+	)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			if current == name && settings == nil {
+				settings = map[string]string{}
+			}
+			continue
+		}
+
+		pair := strings.SplitN(line, "=", 2)
+		if current == "" || len(pair) != 2 || strings.TrimSpace(pair[0]) == "" {
+			return nil, fmt.Errorf("profile-file line %d must be a [profile] or option = value.", i+1)
+		}
+		if current == name {
+			settings[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+		}
+	}
+
+	if settings == nil {
+		return nil, fmt.Errorf("profile %q isn't in %s.", name, path)
+	}
+	return settings, nil
+}
+
+// applyProfile sets each option of the named profile that's still at its
+// default, so anything given on the command line wins over the profile. An
+// option given its default value can't be told from one not given at all,
+// so the profile's value wins there.
+func applyProfile(path string, name string) error {
+	settings, err := readProfile(path, name)
+	if err != nil {
+		return err
+	}
+
+	byArgument := make(map[string]*sensu.PluginConfigOption, len(options))
+	for _, opt := range options {
+		byArgument[opt.Argument] = opt
+	}
+
+	for argument, value := range settings {
+		opt, ok := byArgument[argument]
+		if !ok || argument == "profile" || argument == "profile-file" {
+			return fmt.Errorf("profile %q sets %s, which isn't an option a profile can set.", name, argument)
+		}
+
+		current := reflect.ValueOf(opt.Value).Elem()
+		if !reflect.DeepEqual(current.Interface(), opt.Default) {
+			continue
+		}
+		v, err := parseOptionValue(current.Type(), value)
+		if err != nil {
+			return fmt.Errorf("profile %q has a bad %s: %v", name, argument, err)
+		}
+		current.Set(v)
+	}
+	return nil
+}
+
+// parseOptionValue parses s as the type of an option, the way it would be
+// given on the command line.
+func parseOptionValue(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, fmt.Errorf("%q isn't true or false.", s)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return v, fmt.Errorf("%q isn't a whole number.", s)
+		}
+		v.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return v, fmt.Errorf("%q isn't a number.", s)
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		m := map[string]string{}
+		for _, item := range strings.Split(s, ",") {
+			pair := strings.SplitN(item, "=", 2)
+			if len(pair) != 2 {
+				return v, fmt.Errorf("%q isn't key=value pairs.", s)
+			}
+			m[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return v, fmt.Errorf("options of type %s can't be set by a profile.", t)
+	}
+	return v, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExecuteCheckProfile(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Profile = "server-room"
+	plugin.ProfileFile = filepath.Join("testdata", "profiles.conf")
+
+	if _, err := checkArgs(nil); err != nil {
+		t.Fatalf("checkArgs() error = %v", err)
+	}
+	if plugin.Warning != 27 || plugin.Critical != 32 {
+		t.Errorf("thresholds = %v/%v, want the profile's 27/32", plugin.Warning, plugin.Critical)
+	}
+
+	// 30c is fine by the defaults but not in a server room
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3000))})
+	if state != sensu.CheckStateWarning {
+		t.Errorf("executeCheck() = %d, %q, want WARNING", state, out)
+	}
+}
+
+func TestApplyProfileOverrides(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Profile = "server-room"
+	plugin.ProfileFile = filepath.Join("testdata", "profiles.conf")
+	plugin.Critical = 30
+
+	if _, err := checkArgs(nil); err != nil {
+		t.Fatalf("checkArgs() error = %v", err)
+	}
+	if plugin.Warning != 27 || plugin.Critical != 30 {
+		t.Errorf("thresholds = %v/%v, want 27 from the profile and 30 from the command line", plugin.Warning, plugin.Critical)
+	}
+}
+
+func TestApplyProfileTypes(t *testing.T) {
+	setDefaults()
+	if err := applyProfile(filepath.Join("testdata", "profiles.conf"), "freezer"); err != nil {
+		t.Fatalf("applyProfile() error = %v", err)
+	}
+	if plugin.WarningRange != "-25:-15" || plugin.CriticalRange != "-30:-10" || !plugin.IncludeSysName {
+		t.Errorf("ranges %q/%q, include-sysname %v, want the freezer profile", plugin.WarningRange, plugin.CriticalRange, plugin.IncludeSysName)
+	}
+	if want := map[string]string{"runbook": "freezer", "team": "facilities"}; !reflect.DeepEqual(plugin.MetricTags, want) {
+		t.Errorf("metric tags = %v, want %v", plugin.MetricTags, want)
+	}
+}
+
+func TestApplyProfileErrors(t *testing.T) {
+	tests := []struct {
+		profile string
+		content string
+		want    string
+	}{
+		{"lab", "[server-room]\nwarning = 27\n", `profile "lab" isn't in`},
+		{"lab", "warning = 27\n", "line 1 must be"},
+		{"lab", "[lab]\nwarmth = 27\n", "sets warmth"},
+		{"lab", "[lab]\nprofile = other\n", "sets profile"},
+		{"lab", "[lab]\nwarning = hot\n", "bad warning"},
+		{"lab", "[lab]\ninclude-sysname = maybe\n", "bad include-sysname"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		path := tempStateFile(t)
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyProfile(path, tt.profile); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: applyProfile() error = %v, want %q", tt.content, err, tt.want)
+		}
+	}
+}
+
+func TestCheckArgsProfile(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Profile = "server-room"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted profile without profile-file")
+	}
+}
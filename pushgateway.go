@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// pushMetrics pushes the metrics of r to the Prometheus Pushgateway at base,
+// replacing the previous push for the same job and target. Each metric is
+// labelled with the unit's location, when it has one.
+func pushMetrics(base string, job string, r *checkResult) error {
+	labels := ""
+	if r.Location != "" {
+		labels = fmt.Sprintf("{location=%q}", r.Location)
+	}
+
+	var body strings.Builder
+	for _, m := range r.Metrics {
+		fmt.Fprintf(&body, "%s%s %s\n", m.Name, labels, m.Value)
+	}
+
+	endpoint := fmt.Sprintf("%s/metrics/job/%s/target/%s", strings.TrimSuffix(base, "/"), url.PathEscape(job), url.PathEscape(r.Target))
+	req, err := http.NewRequest(http.MethodPut, endpoint, strings.NewReader(body.String()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway returned %s", resp.Status)
+	}
+	return nil
+}
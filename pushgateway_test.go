@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// push is a request received by pushgatewayStub.
+type push struct {
+	method string
+	path   string
+	body   string
+}
+
+// pushgatewayStub records the pushes it's sent and answers with status.
+func pushgatewayStub(t *testing.T, status int) (*httptest.Server, *[]push) {
+	var pushes []push
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		pushes = append(pushes, push{r.Method, r.URL.Path, string(body)})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &pushes
+}
+
+func TestExecuteCheckPushgateway(t *testing.T) {
+	server, pushes := pushgatewayStub(t, http.StatusOK)
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.PushgatewayURL = server.URL + "/"
+	plugin.PushgatewayJob = "tempager"
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("server room", 2000, 3600))})
+	if state != sensu.CheckStateWarning || strings.Contains(out, "failed to push") {
+		t.Errorf("executeCheck() = %d, %q, want the WARNING unchanged", state, out)
+	}
+
+	want := push{
+		method: http.MethodPut,
+		path:   "/metrics/job/tempager/target/192.0.2.1",
+		body:   "tempager_internal{location=\"server room\"} 20.00\ntempager_external{location=\"server room\"} 36.00\n",
+	}
+	if len(*pushes) != 1 || (*pushes)[0] != want {
+		t.Errorf("pushes = %+v, want %+v", *pushes, want)
+	}
+}
+
+func TestExecuteCheckPushgatewayFailure(t *testing.T) {
+	tests := []struct {
+		failureState string
+		wantState    int
+	}{
+		{"ok", sensu.CheckStateOK},
+		{"warning", sensu.CheckStateWarning},
+		{"critical", sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		server, _ := pushgatewayStub(t, http.StatusServiceUnavailable)
+		setDefaults()
+		plugin.PushgatewayURL = server.URL
+		plugin.PushFailureState = tt.failureState
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+		if state != tt.wantState || !strings.Contains(out, "lab temperature is 21.50c; failed to push metrics: pushgateway returned 503 Service Unavailable |") {
+			t.Errorf("%s: executeCheck() = %d, %q, want %d with the failure noted", tt.failureState, state, out, tt.wantState)
+		}
+	}
+}
+
+func TestExecuteCheckPushgatewayNoMetrics(t *testing.T) {
+	server, pushes := pushgatewayStub(t, http.StatusOK)
+	setDefaults()
+	plugin.PushgatewayURL = server.URL
+
+	runCheck(t, &fakeClient{connectErr: errors.New("no route to host")})
+	if len(*pushes) != 0 {
+		t.Errorf("pushes = %+v, want nothing pushed without metrics", *pushes)
+	}
+}
+
+func TestCheckArgsPushgateway(t *testing.T) {
+	tests := []struct {
+		url          string
+		failureState string
+	}{
+		{"pushgateway:9091", "ok"},
+		{"ftp://pushgateway:9091", "ok"},
+		{"http://pushgateway:9091", "unknown"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.PushgatewayURL = tt.url
+		plugin.PushFailureState = tt.failureState
+
+		if _, err := checkArgs(nil); err == nil {
+			t.Errorf("checkArgs() accepted pushgateway-url %q with push-failure-state %q", tt.url, tt.failureState)
+		}
+	}
+}
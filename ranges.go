@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// thresholdRange is a Nagios threshold range, alerting on a value outside
+// start to end inclusive, or inside it when inverted with "@".
+type thresholdRange struct {
+	start  float64
+	end    float64
+	inside bool
+}
+
+// above returns the range alerting on anything above threshold, which is
+// how the plain warning and critical thresholds behave.
+func above(threshold float64) thresholdRange {
+	return thresholdRange{start: math.Inf(-1), end: threshold}
+}
+
+// parseRange parses a range in the Nagios plugin syntax: "10" is 0 to 10,
+// "10:" is 10 upwards, "~:10" is anything up to 10, "10:20" is 10 to 20 and
+// a leading "@" alerts inside the range rather than outside it.
+func parseRange(s string) (thresholdRange, error) {
+	r := thresholdRange{end: math.Inf(1)}
+
+	spec := s
+	if strings.HasPrefix(spec, "@") {
+		r.inside = true
+		spec = spec[1:]
+	}
+	if spec == "" {
+		return r, fmt.Errorf("%q isn't a range.", s)
+	}
+
+	start, end := "0", spec
+	if i := strings.Index(spec, ":"); i >= 0 {
+		start, end = spec[:i], spec[i+1:]
+	}
+
+	var err error
+	switch start {
+	case "~":
+		r.start = math.Inf(-1)
+	case "":
+		return r, fmt.Errorf("%q is missing the start of the range.", s)
+	default:
+		if r.start, err = strconv.ParseFloat(start, 64); err != nil {
+			return r, fmt.Errorf("%q has a bad start of range.", s)
+		}
+	}
+	if end != "" {
+		if r.end, err = strconv.ParseFloat(end, 64); err != nil {
+			return r, fmt.Errorf("%q has a bad end of range.", s)
+		}
+	}
+
+	if r.start > r.end {
+		return r, fmt.Errorf("%q starts above where it ends.", s)
+	}
+	return r, nil
+}
+
+// alerts reports whether v falls where the range alerts.
+func (r thresholdRange) alerts(v float64) bool {
+	in := v >= r.start && v <= r.end
+	return in == r.inside
+}
+
+// margin returns how far v has to move before the range alerts, 0 when it
+// already does. ok is false for a range that can never alert from v, one
+// going on forever the way v would have to move.
+func (r thresholdRange) margin(v float64) (float64, bool) {
+	if r.alerts(v) {
+		return 0, true
+	}
+
+	var m float64
+	if r.inside {
+		// outside, so the nearer end is the way in
+		m = math.Max(r.start-v, v-r.end)
+	} else {
+		m = math.Min(v-r.start, r.end-v)
+	}
+	if math.IsInf(m, 0) {
+		return 0, false
+	}
+	return math.Round(m*100) / 100, true
+}
+
+// activeRanges returns the warning and critical ranges in force at t, the
+// warning-range and critical-range when given, otherwise alerting above the
+// thresholds active at t. The ranges are validated by checkArgs.
+func activeRanges(t time.Time) (thresholdRange, thresholdRange) {
+	warning, critical := activeThresholds(t)
+	warningRange, criticalRange := above(warning), above(critical)
+
+	if plugin.WarningRange != "" {
+		warningRange, _ = parseRange(plugin.WarningRange)
+	}
+	if plugin.CriticalRange != "" {
+		criticalRange, _ = parseRange(plugin.CriticalRange)
+	}
+	return warningRange, criticalRange
+}
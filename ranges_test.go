@@ -0,0 +1,186 @@
+package main
+
+import (
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		spec  string
+		value float64
+		want  bool
+	}{
+		// a bare number is 0 up to it, inclusive at both ends
+		{"10", -0.5, true},
+		{"10", 0, false},
+		{"10", 10, false},
+		{"10", 10.01, true},
+		// an open end goes on forever
+		{"10:", 9.99, true},
+		{"10:", 10, false},
+		{"10:", 1000, false},
+		// and ~ is minus infinity
+		{"~:40", -1000, false},
+		{"~:40", 40, false},
+		{"~:40", 40.01, true},
+		{"~:", -1000, false},
+		// both ends given
+		{"10:20", 9.99, true},
+		{"10:20", 10, false},
+		{"10:20", 20, false},
+		{"10:20", 20.01, true},
+		{"-5:5", -5.01, true},
+		{"-5:5", 0, false},
+		// @ alerts inside the range, ends included
+		{"@10:20", 9.99, false},
+		{"@10:20", 10, true},
+		{"@10:20", 15, true},
+		{"@10:20", 20, true},
+		{"@10:20", 20.01, false},
+		{"@~:0", -3, true},
+		{"@~:0", 0.5, false},
+		{"@10", 5, true},
+		{"@10", -1, false},
+	}
+	for _, tt := range tests {
+		r, err := parseRange(tt.spec)
+		if err != nil {
+			t.Errorf("parseRange(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if got := r.alerts(tt.value); got != tt.want {
+			t.Errorf("parseRange(%q).alerts(%v) = %v, want %v", tt.spec, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, spec := range []string{"", "@", ":10", "abc", "10:abc", "~", "20:10", "@5:1", "1:2:3"} {
+		if _, err := parseRange(spec); err == nil {
+			t.Errorf("parseRange(%q) accepted an invalid range", spec)
+		}
+	}
+}
+
+func TestExecuteCheckRanges(t *testing.T) {
+	tests := []struct {
+		warning   string
+		critical  string
+		external  int
+		wantState int
+	}{
+		// a freezer that should stay between -25 and -15
+		{"-25:-15", "-30:-10", -2000, sensu.CheckStateOK},
+		{"-25:-15", "-30:-10", -1200, sensu.CheckStateWarning},
+		{"-25:-15", "-30:-10", -3100, sensu.CheckStateCritical},
+		// only the warning given, critical is still above 40
+		{"@20:25", "", 2150, sensu.CheckStateWarning},
+		{"@20:25", "", 3000, sensu.CheckStateOK},
+		{"@20:25", "", 4100, sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.WarningRange = tt.warning
+		plugin.CriticalRange = tt.critical
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if state != tt.wantState {
+			t.Errorf("%q/%q at %d: state = %d, want %d (%q)", tt.warning, tt.critical, tt.external, state, tt.wantState, out)
+		}
+	}
+}
+
+func TestCheckArgsRanges(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.CriticalRange = "40:30"
+
+	if _, err := checkArgs(nil); err == nil || !strings.Contains(err.Error(), "critical-range") {
+		t.Errorf("checkArgs() error = %v, want the bad critical-range", err)
+	}
+
+	// a range stands in for the plain threshold, it can't be given both
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Warning = 30
+	plugin.WarningRange = "18:27"
+	if _, err := checkArgs(nil); err == nil || !strings.Contains(err.Error(), "warning and warning-range") {
+		t.Errorf("checkArgs() error = %v, want warning and warning-range rejected together", err)
+	}
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Critical = 45
+	plugin.CriticalRange = "15:30"
+	if _, err := checkArgs(nil); err == nil || !strings.Contains(err.Error(), "critical and critical-range") {
+		t.Errorf("checkArgs() error = %v, want critical and critical-range rejected together", err)
+	}
+}
+
+func TestRangeMargin(t *testing.T) {
+	tests := []struct {
+		spec   string
+		value  float64
+		want   float64
+		wantOK bool
+	}{
+		{"35", 21.5, 13.5, true},
+		{"~:40", 36.05, 3.95, true},
+		{"~:40", 41, 0, true},
+		// a lower bound has the headroom below the reading
+		{"10:", 21.5, 11.5, true},
+		{"10:30", 21.5, 8.5, true},
+		// inverted, the way into the range is the nearer end
+		{"@10:20", 25, 5, true},
+		{"@10:20", 4, 6, true},
+		{"@10:20", 15, 0, true},
+		{"@~:0", 21.5, 21.5, true},
+		// nothing ever alerts
+		{"~:", 21.5, 0, false},
+	}
+	for _, tt := range tests {
+		r, err := parseRange(tt.spec)
+		if err != nil {
+			t.Fatalf("parseRange(%q) error = %v", tt.spec, err)
+		}
+		if got, ok := r.margin(tt.value); got != tt.want || ok != tt.wantOK {
+			t.Errorf("%q.margin(%v) = %v, %t, want %v, %t", tt.spec, tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestExecuteCheckReportMargins(t *testing.T) {
+	setDefaults()
+	plugin.Output = "json"
+	plugin.ReportMargins = true
+	plugin.MarginPerfData = true
+
+	_, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	want := `"margin_to_warning":13.5,"margin_to_critical":18.5,"metrics":[{"name":"tempager_internal","value":20.00},{"name":"tempager_external","value":21.50},{"name":"tempager_margin_to_warning","value":13.50},{"name":"tempager_margin_to_critical","value":18.50}]}`
+	if !strings.Contains(out, want) {
+		t.Errorf("executeCheck() = %q, want %q", out, want)
+	}
+
+	// lower bounds count down instead
+	setDefaults()
+	plugin.ReportMargins = true
+	plugin.MarginPerfData = true
+	plugin.WarningRange = "15:"
+	plugin.CriticalRange = "10:"
+	_, out = runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	if !strings.Contains(out, "tempager_margin_to_warning=6.50, tempager_margin_to_critical=11.50") {
+		t.Errorf("executeCheck() = %q, want the margins down to the lower bounds", out)
+	}
+}
+
+func TestCheckArgsMarginPerfData(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.MarginPerfData = true
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted margin-perfdata without report-margins")
+	}
+}
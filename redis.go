@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisTarget is where and how to publish to redis, parsed from a
+// redis://[:password@]host[:port][/db] url.
+type redisTarget struct {
+	addr     string
+	password string
+	db       int
+}
+
+// parseRedisURL parses a redis url, the port defaulting to 6379.
+func parseRedisURL(s string) (redisTarget, error) {
+	var rt redisTarget
+
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "redis" || u.Hostname() == "" {
+		return rt, fmt.Errorf("redis-url must be a redis://host:port url.")
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "6379"
+	}
+	rt.addr = net.JoinHostPort(u.Hostname(), port)
+
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			rt.password = password
+		} else {
+			rt.password = u.User.Username()
+		}
+	}
+
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if rt.db, err = strconv.Atoi(db); err != nil || rt.db < 0 {
+			return rt, fmt.Errorf("redis-url database must be a number.")
+		}
+	}
+	return rt, nil
+}
+
+// publishRedis publishes message to channel on the redis server at rawurl.
+// Nobody has to be subscribed for it to succeed.
+func publishRedis(rawurl string, channel string, message string) error {
+	rt, err := parseRedisURL(rawurl)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", rt.addr, time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	r := bufio.NewReader(conn)
+	var commands [][]string
+	if rt.password != "" {
+		commands = append(commands, []string{"AUTH", rt.password})
+	}
+	if rt.db != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(rt.db)})
+	}
+	commands = append(commands, []string{"PUBLISH", channel, message})
+
+	for _, args := range commands {
+		if _, err := conn.Write(respCommand(args)); err != nil {
+			return err
+		}
+		if err := readRespReply(r); err != nil {
+			return fmt.Errorf("%s failed: %v", args[0], err)
+		}
+	}
+	return nil
+}
+
+// respCommand encodes args as a RESP array of bulk strings.
+func respCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
+
+// readRespReply reads a simple reply, which is all AUTH, SELECT and PUBLISH
+// send back, returning the server's error if it sent one.
+func readRespReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return fmt.Errorf("empty reply.")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return fmt.Errorf("%s", line[1:])
+	default:
+		return fmt.Errorf("unexpected reply %q.", line)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"io/ioutil"
+)
+
+// pcap link types the capture may have been taken on
+const (
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLinuxSLL = 113
+	linkIPv4     = 228
+)
+
+// replayClient answers Gets with the responses from a pcap capture of an
+// earlier exchange with a unit, in the order they were captured, so a field
+// issue can be reproduced offline.
+type replayClient struct {
+	path      string
+	decoder   *gosnmp.GoSNMP
+	responses []*gosnmp.SnmpPacket
+}
+
+// Connect loads the SNMP responses from the capture.
+func (c *replayClient) Connect() error {
+	payloads, err := readPcapUDP(c.path, 161)
+	if err != nil {
+		return err
+	}
+
+	for _, payload := range payloads {
+		packet, err := c.decoder.SnmpDecodePacket(payload)
+		if err != nil || packet.PDUType != gosnmp.GetResponse {
+			continue
+		}
+		c.responses = append(c.responses, packet)
+	}
+
+	if len(c.responses) == 0 {
+		return fmt.Errorf("no SNMP responses found in %s.", c.path)
+	}
+	return nil
+}
+
+// Get returns the next captured response, whatever oids are asked for.
+func (c *replayClient) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	if len(c.responses) == 0 {
+		return nil, errors.New("no more responses in the capture.")
+	}
+	packet := c.responses[0]
+	c.responses = c.responses[1:]
+	return packet, nil
+}
+
+// GetNext returns the next captured response too, the capture doesn't say
+// which kind of request each answered.
+func (c *replayClient) GetNext(oids []string) (*gosnmp.SnmpPacket, error) {
+	return c.Get(oids)
+}
+
+// WalkAll isn't supported, a walk is too many exchanges to line up.
+func (c *replayClient) WalkAll(rootOid string) ([]gosnmp.SnmpPDU, error) {
+	return nil, errors.New("walks can't be replayed.")
+}
+
+// Close has nothing to close.
+func (c *replayClient) Close() error {
+	return nil
+}
+
+// readPcapUDP returns the payloads of the UDP datagrams sent from port in the
+// classic libpcap capture at path.
+func readPcapUDP(path string, port uint16) ([][]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 24 {
+		return nil, fmt.Errorf("%s is not a pcap file.", path)
+	}
+
+	// the magic number gives away the byte order, and whether the
+	// timestamps are in micro or nanoseconds, which doesn't matter here
+	var order binary.ByteOrder
+	switch binary.LittleEndian.Uint32(data) {
+	case 0xa1b2c3d4, 0xa1b23c4d:
+		order = binary.LittleEndian
+	case 0xd4c3b2a1, 0x4d3cb2a1:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%s is not a pcap file.", path)
+	}
+	link := order.Uint32(data[20:])
+
+	var payloads [][]byte
+	for rest := data[24:]; len(rest) >= 16; {
+		size := int(order.Uint32(rest[8:]))
+		if len(rest) < 16+size {
+			return nil, fmt.Errorf("%s is truncated.", path)
+		}
+		frame := rest[16 : 16+size]
+		rest = rest[16+size:]
+
+		if payload, ok := udpPayload(link, frame, port); ok {
+			payloads = append(payloads, payload)
+		}
+	}
+	return payloads, nil
+}
+
+// udpPayload unwraps a captured frame down to the UDP payload, when it's a
+// datagram from port.
+func udpPayload(link uint32, frame []byte, port uint16) ([]byte, bool) {
+	var (
+		etherType uint16
+		packet    []byte
+	)
+	switch link {
+	case linkEthernet:
+		if len(frame) < 14 {
+			return nil, false
+		}
+		etherType, packet = binary.BigEndian.Uint16(frame[12:]), frame[14:]
+		// skip a VLAN tag
+		if etherType == 0x8100 && len(packet) >= 4 {
+			etherType, packet = binary.BigEndian.Uint16(packet[2:]), packet[4:]
+		}
+	case linkLinuxSLL:
+		if len(frame) < 16 {
+			return nil, false
+		}
+		etherType, packet = binary.BigEndian.Uint16(frame[14:]), frame[16:]
+	case linkRaw, linkIPv4:
+		etherType, packet = 0x0800, frame
+		if len(frame) > 0 && frame[0]>>4 == 6 {
+			etherType = 0x86dd
+		}
+	default:
+		return nil, false
+	}
+
+	var datagram []byte
+	switch etherType {
+	case 0x0800:
+		if len(packet) < 20 || packet[9] != 17 {
+			return nil, false
+		}
+		headerLen := int(packet[0]&0x0f) * 4
+		if len(packet) < headerLen {
+			return nil, false
+		}
+		datagram = packet[headerLen:]
+	case 0x86dd:
+		if len(packet) < 40 || packet[6] != 17 {
+			return nil, false
+		}
+		datagram = packet[40:]
+	default:
+		return nil, false
+	}
+
+	if len(datagram) < 8 || binary.BigEndian.Uint16(datagram) != port {
+		return nil, false
+	}
+	length := int(binary.BigEndian.Uint16(datagram[4:]))
+	if length < 8 || length > len(datagram) {
+		return nil, false
+	}
+	return datagram[8:length], true
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecuteCheckReplay(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Community = "public"
+	plugin.Replay = filepath.Join("testdata", "exchange.pcap")
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	state, err := executeCheck(nil)
+	if err != nil {
+		t.Fatalf("executeCheck() error = %v", err)
+	}
+	want := "check-tempager-3e-temperature WARNING: server room temperature is 37.25c | tempager_internal=22.50, tempager_external=37.25\n"
+	if state != sensu.CheckStateWarning || out.String() != want {
+		t.Errorf("executeCheck() = %d, %q, want %d, %q", state, out.String(), sensu.CheckStateWarning, want)
+	}
+}
+
+func TestReadPcapUDP(t *testing.T) {
+	// the capture holds the request to 161 and the response from it
+	payloads, err := readPcapUDP(filepath.Join("testdata", "exchange.pcap"), 161)
+	if err != nil {
+		t.Fatalf("readPcapUDP() error = %v", err)
+	}
+	if len(payloads) != 1 {
+		t.Errorf("readPcapUDP() returned %d payloads, want the 1 response", len(payloads))
+	}
+}
+
+func TestReadPcapUDPNotACapture(t *testing.T) {
+	path := tempStateFile(t)
+	if err := ioutil.WriteFile(path, []byte("this is not a packet capture at all"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPcapUDP(path, 161); err == nil {
+		t.Error("readPcapUDP() accepted a file that isn't a capture")
+	}
+}
+
+func TestReplayClientRunsOut(t *testing.T) {
+	setDefaults()
+	plugin.Community = "public"
+
+	client := &replayClient{path: filepath.Join("testdata", "exchange.pcap"), decoder: newSNMP("192.0.2.1", "1")}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if _, err := client.Get(nil); err != nil {
+		t.Fatalf("first Get() error = %v", err)
+	}
+	if _, err := client.Get(nil); err == nil {
+		t.Error("second Get() answered from an exhausted capture")
+	}
+}
+
+func TestExecuteCheckDumpRawResponse(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Community = "public"
+	plugin.Replay = filepath.Join("testdata", "exchange.pcap")
+	plugin.DumpRawResponse = true
+
+	var out, dump bytes.Buffer
+	stdout, stderr = &out, &dump
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+
+	if _, err := executeCheck(nil); err != nil {
+		t.Fatalf("executeCheck() error = %v", err)
+	}
+
+	// the value bytes as captured in the fixture
+	want := ".1.3.6.1.2.1.1.6.0 OctetString [73 65 72 76 65 72 20 72 6f 6f 6d] \"server room\"\n" +
+		".1.3.6.1.4.1.20916.1.7.1.1.1.1.0 Integer [08 ca] 2250\n" +
+		".1.3.6.1.4.1.20916.1.7.1.2.1.1.0 Integer [0e 8d] 3725\n"
+	if dump.String() != want {
+		t.Errorf("dump = %q, want %q", dump.String(), want)
+	}
+
+	// the result itself is unchanged
+	if !strings.HasPrefix(out.String(), "check-tempager-3e-temperature WARNING: server room temperature is 37.25c") {
+		t.Errorf("output = %q, want the normal result", out.String())
+	}
+}
+
+func TestBERInteger(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x00, 0x80}},
+		{2250, []byte{0x08, 0xca}},
+		{-1, []byte{0xff}},
+		{-128, []byte{0x80}},
+		{-129, []byte{0xff, 0x7f}},
+	}
+	for _, tt := range tests {
+		if got := berInteger(tt.n); !bytes.Equal(got, tt.want) {
+			t.Errorf("berInteger(%d) = % x, want % x", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduledThresholds are the warning and critical thresholds that apply
+// during a window, "daily 22:00-06:00 38/45" say.
+type scheduledThresholds struct {
+	window   maintenanceWindow
+	warning  float64
+	critical float64
+}
+
+// parseThresholdSchedule parses a window given as "<days> <HH:MM>-<HH:MM>
+// <warning>/<critical>".
+func parseThresholdSchedule(s string) (scheduledThresholds, error) {
+	var st scheduledThresholds
+
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return st, fmt.Errorf("threshold schedule %q must be days, a time range and warning/critical, such as daily 22:00-06:00 38/45.", s)
+	}
+
+	w, err := parseWindow(strings.Join(fields[:2], " "))
+	if err != nil {
+		return st, fmt.Errorf("threshold schedule %v", err)
+	}
+	st.window = w
+
+	thresholds := strings.SplitN(fields[2], "/", 2)
+	if len(thresholds) != 2 {
+		return st, fmt.Errorf("threshold schedule %q must give the thresholds as warning/critical.", s)
+	}
+	if st.warning, err = strconv.ParseFloat(thresholds[0], 64); err != nil {
+		return st, fmt.Errorf("threshold schedule %q has a bad warning threshold.", s)
+	}
+	if st.critical, err = strconv.ParseFloat(thresholds[1], 64); err != nil {
+		return st, fmt.Errorf("threshold schedule %q has a bad critical threshold.", s)
+	}
+	if st.critical < st.warning {
+		return st, fmt.Errorf("threshold schedule %q has a critical threshold below its warning threshold.", s)
+	}
+	return st, nil
+}
+
+// activeThresholds returns the warning and critical thresholds in force at
+// t, those of the first schedule covering it or the global ones otherwise.
+// The schedules are validated by checkArgs, so a bad one is just skipped.
+func activeThresholds(t time.Time) (float64, float64) {
+	for _, s := range plugin.ThresholdSchedule {
+		if st, err := parseThresholdSchedule(s); err == nil && st.window.contains(t) {
+			return st.warning, st.critical
+		}
+	}
+	return globalWarning(), plugin.Critical
+}
+
+// globalWarning returns the warning threshold, derived from critical when
+// warning-percent is set.
+func globalWarning() float64 {
+	if plugin.WarningPercent > 0 {
+		return plugin.Critical * plugin.WarningPercent / 100
+	}
+	return plugin.Warning
+}
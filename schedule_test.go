@@ -0,0 +1,117 @@
+package main
+
+import (
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"testing"
+	"time"
+)
+
+func TestExecuteCheckThresholdSchedule(t *testing.T) {
+	tests := []struct {
+		t         time.Time
+		external  int
+		wantState int
+	}{
+		// by day the global 35/40 apply
+		{time.Date(2020, 6, 3, 14, 0, 0, 0, time.UTC), 3700, sensu.CheckStateWarning},
+		{time.Date(2020, 6, 3, 14, 0, 0, 0, time.UTC), 4200, sensu.CheckStateCritical},
+		// overnight the economizers allow more
+		{time.Date(2020, 6, 3, 23, 0, 0, 0, time.UTC), 3700, sensu.CheckStateOK},
+		{time.Date(2020, 6, 4, 5, 59, 0, 0, time.UTC), 4200, sensu.CheckStateWarning},
+		{time.Date(2020, 6, 4, 5, 59, 0, 0, time.UTC), 4600, sensu.CheckStateCritical},
+		// the first matching schedule wins
+		{time.Date(2020, 6, 6, 23, 0, 0, 0, time.UTC), 3700, sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.ThresholdSchedule = []string{"sat 20:00-23:30 30/36", "daily 22:00-06:00 38/45"}
+		setNow(t, tt.t)
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if state != tt.wantState {
+			t.Errorf("%s, %d: state = %d, want %d (%q)", tt.t.Format("Mon 15:04"), tt.external, state, tt.wantState, out)
+		}
+	}
+}
+
+func TestParseThresholdSchedule(t *testing.T) {
+	st, err := parseThresholdSchedule("mon-fri 18:00-08:00 37.5/42")
+	if err != nil {
+		t.Fatalf("parseThresholdSchedule() error = %v", err)
+	}
+	if st.warning != 37.5 || st.critical != 42 {
+		t.Errorf("thresholds = %v/%v, want 37.5/42", st.warning, st.critical)
+	}
+
+	for _, s := range []string{"daily 22:00-06:00", "daily 22:00-06:00 38", "daily 22:00-06:00 hot/45", "daily 22:00-06:00 45/38", "nightly 22:00-06:00 38/45"} {
+		if _, err := parseThresholdSchedule(s); err == nil {
+			t.Errorf("parseThresholdSchedule(%q) accepted a bad schedule", s)
+		}
+	}
+}
+
+func TestCheckArgsThresholdSchedule(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.ThresholdSchedule = []string{"daily 22:00-06:00 38/"}
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a bad threshold schedule")
+	}
+}
+
+func TestGlobalWarningPercent(t *testing.T) {
+	tests := []struct {
+		warning  float64
+		critical float64
+		percent  float64
+		want     float64
+	}{
+		{35, 40, 0, 35},
+		{35, 40, 90, 36},
+		{35, 50, 75, 37.5},
+		{10, 40, 50, 20},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Warning = tt.warning
+		plugin.Critical = tt.critical
+		plugin.WarningPercent = tt.percent
+
+		if got := globalWarning(); got != tt.want {
+			t.Errorf("%v%% of %v: globalWarning() = %v, want %v", tt.percent, tt.critical, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteCheckWarningPercent(t *testing.T) {
+	tests := []struct {
+		external  int
+		wantState int
+	}{
+		{3550, sensu.CheckStateOK},
+		{3650, sensu.CheckStateWarning},
+		{4100, sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.WarningPercent = 90
+
+		// the explicit 35c warning is ignored for 90% of 40c
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, tt.external))})
+		if state != tt.wantState {
+			t.Errorf("%d: state = %d, want %d (%q)", tt.external, state, tt.wantState, out)
+		}
+	}
+}
+
+func TestCheckArgsWarningPercent(t *testing.T) {
+	for _, percent := range []float64{-10, 100, 150} {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.WarningPercent = percent
+		if _, err := checkArgs(nil); err == nil {
+			t.Errorf("checkArgs() accepted a warning-percent of %v", percent)
+		}
+	}
+}
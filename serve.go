@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// exporter polls one unit on an interval and serves the latest result, for
+// running alongside a Prometheus rather than under a scheduler.
+type exporter struct {
+	target string
+
+	mu     sync.Mutex
+	res    *checkResult
+	err    error
+	polled time.Time
+}
+
+// poll checks the unit and caches the result.
+func (e *exporter) poll() {
+	res, err := checkTarget(e.target)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.res, e.err, e.polled = res, err, now()
+}
+
+// latest returns the cached result, nil before the first poll.
+func (e *exporter) latest() (*checkResult, time.Time, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.res, e.polled, e.err
+}
+
+// handler serves /metrics and /healthz from the cached result.
+func (e *exporter) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.serveMetrics)
+	mux.HandleFunc("/healthz", e.serveHealth)
+	return mux
+}
+
+// serveMetrics writes the latest metrics in the Prometheus text format,
+// timestamped with when they were polled, along with the check state.
+func (e *exporter) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	res, polled, _ := e.latest()
+	if res == nil {
+		http.Error(w, "not polled yet.", http.StatusServiceUnavailable)
+		return
+	}
+
+	metrics := append([]metric{{"tempager_state", json.Number(strconv.Itoa(res.Status))}}, res.Metrics...)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, formatMetrics(corev2.PrometheusOutputMetricFormat, e.target, metrics, polled))
+}
+
+// serveHealth answers 200 while the unit is being read, and 503 with the
+// summary once it can't be or the cached result is more than two poll
+// intervals old.
+func (e *exporter) serveHealth(w http.ResponseWriter, r *http.Request) {
+	res, polled, err := e.latest()
+
+	var threshold *ErrThreshold
+	switch {
+	case res == nil:
+		http.Error(w, "not polled yet.", http.StatusServiceUnavailable)
+	case now().Sub(polled) > 2*time.Duration(plugin.PollInterval)*time.Second:
+		http.Error(w, fmt.Sprintf("last polled at %s.", polled.Format(time.RFC3339)), http.StatusServiceUnavailable)
+	case err != nil && !errors.As(err, &threshold):
+		http.Error(w, res.Summary, http.StatusServiceUnavailable)
+	default:
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// serve polls target every poll-interval and serves the results on addr
+// until the server fails.
+func serve(addr string, target string) (int, error) {
+	e := &exporter{target: target}
+	e.poll()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(plugin.PollInterval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			e.poll()
+		}
+	}()
+
+	if err := http.ListenAndServe(addr, e.handler()); err != nil {
+		return sensu.CheckStateCritical, fmt.Errorf("failed to serve on %s: %v", addr, err)
+	}
+	return sensu.CheckStateOK, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"github.com/gosnmp/gosnmp"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serveExporter starts an exporter for the unit behind client on an
+// in-process server, without polling it yet.
+func serveExporter(t *testing.T, client snmpClient) (*exporter, *httptest.Server) {
+	oldClient := newClient
+	newClient = func(string, string) snmpClient { return client }
+	t.Cleanup(func() { newClient = oldClient })
+
+	e := &exporter{target: "192.0.2.1"}
+	server := httptest.NewServer(e.handler())
+	t.Cleanup(server.Close)
+	return e, server
+}
+
+// get fetches path from server, returning the status code and body.
+func get(t *testing.T, server *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServeMetrics(t *testing.T) {
+	setDefaults()
+	setNow(t, time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	e, server := serveExporter(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))})
+
+	if code, _ := get(t, server, "/metrics"); code != http.StatusServiceUnavailable {
+		t.Errorf("/metrics before polling = %d, want 503", code)
+	}
+
+	e.poll()
+	code, body := get(t, server, "/metrics")
+	want := "tempager_state{target=\"192.0.2.1\"} 1 1591012800000\n" +
+		"tempager_internal{target=\"192.0.2.1\"} 20.00 1591012800000\n" +
+		"tempager_external{target=\"192.0.2.1\"} 36.00 1591012800000\n"
+	if code != http.StatusOK || body != want {
+		t.Errorf("/metrics = %d, %q, want 200, %q", code, body, want)
+	}
+}
+
+func TestServeHealth(t *testing.T) {
+	setDefaults()
+	plugin.PollInterval = 60
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	setNow(t, start)
+
+	reading := respond(tempagerPacket("lab", 2000, 4100))
+	client := &fakeClient{get: reading}
+	e, server := serveExporter(t, client)
+
+	if code, _ := get(t, server, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz before polling = %d, want 503", code)
+	}
+
+	// a reading over the threshold is still a healthy exporter
+	e.poll()
+	if code, body := get(t, server, "/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Errorf("/healthz = %d, %q, want 200, ok", code, body)
+	}
+
+	// the unit going away isn't
+	client.get = func([]string) (*gosnmp.SnmpPacket, error) {
+		return nil, errors.New("no route to host")
+	}
+	e.poll()
+	if code, body := get(t, server, "/healthz"); code != http.StatusServiceUnavailable || body != "failed to gather oids.\n" {
+		t.Errorf("/healthz = %d, %q, want 503 with the summary", code, body)
+	}
+
+	// nor is a poll that's stopped happening
+	client.get = reading
+	e.poll()
+	setNow(t, start.Add(121*time.Second))
+	if code, _ := get(t, server, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz two intervals on = %d, want 503", code)
+	}
+}
+
+func TestCheckArgsServe(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Serve = ":9781"
+	plugin.PollInterval = 0
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a poll-interval of 0")
+	}
+}
@@ -0,0 +1,1020 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// snmpVersions maps the accepted snmp-version values onto gosnmp
+	snmpVersions = map[string]gosnmp.SnmpVersion{
+		"1":  gosnmp.Version1,
+		"2c": gosnmp.Version2c,
+		"3":  gosnmp.Version3,
+	}
+
+	// authProtocols maps the accepted auth-protocol values onto gosnmp
+	authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+		"":       gosnmp.NoAuth,
+		"md5":    gosnmp.MD5,
+		"sha":    gosnmp.SHA,
+		"sha224": gosnmp.SHA224,
+		"sha256": gosnmp.SHA256,
+		"sha384": gosnmp.SHA384,
+		"sha512": gosnmp.SHA512,
+	}
+
+	// privProtocols maps the accepted priv-protocol values onto gosnmp
+	privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+		"":        gosnmp.NoPriv,
+		"des":     gosnmp.DES,
+		"aes":     gosnmp.AES,
+		"aes192":  gosnmp.AES192,
+		"aes256":  gosnmp.AES256,
+		"aes192c": gosnmp.AES192C,
+		"aes256c": gosnmp.AES256C,
+	}
+)
+
+// snmpClient is the part of gosnmp used by the check, the tests swap in a
+// fake through newClient.
+type snmpClient interface {
+	Connect() error
+	Get(oids []string) (*gosnmp.SnmpPacket, error)
+	GetNext(oids []string) (*gosnmp.SnmpPacket, error)
+	WalkAll(rootOid string) ([]gosnmp.SnmpPDU, error)
+	Close() error
+}
+
+// gosnmpClient adapts a gosnmp connection to snmpClient.
+type gosnmpClient struct {
+	*gosnmp.GoSNMP
+}
+
+// Connect opens the connection, sending from source-address and through
+// socks-proxy when they're configured.
+func (c gosnmpClient) Connect() error {
+	if err := c.GoSNMP.Connect(); err != nil {
+		return err
+	}
+	if plugin.SourceAddress == "" && plugin.SocksProxy == "" {
+		return nil
+	}
+
+	// gosnmp can't bind the local side or use a proxy itself, so replace its
+	// connection with one that does
+	c.Conn.Close()
+	local := net.ParseIP(plugin.SourceAddress)
+	addr := net.JoinHostPort(c.Target, strconv.Itoa(int(c.Port)))
+
+	if plugin.SocksProxy != "" {
+		conn, err := dialSOCKS5UDP(plugin.SocksProxy, addr, local, c.Timeout)
+		if err != nil {
+			return err
+		}
+		c.Conn = conn
+		return nil
+	}
+
+	dialer := net.Dialer{
+		Timeout:   c.Timeout,
+		LocalAddr: localAddr(c.Transport, local),
+	}
+	conn, err := dialer.DialContext(c.Context, c.Transport, addr)
+	if err != nil {
+		return fmt.Errorf("error establishing connection from %s: %w", plugin.SourceAddress, err)
+	}
+	c.Conn = conn
+
+	return nil
+}
+
+// localAddr returns ip as a local address for the given transport.
+func localAddr(transport string, ip net.IP) net.Addr {
+	if strings.HasPrefix(transport, "tcp") {
+		return &net.TCPAddr{IP: ip}
+	}
+	return &net.UDPAddr{IP: ip}
+}
+
+// Close closes the underlying connection.
+func (c gosnmpClient) Close() error {
+	return c.Conn.Close()
+}
+
+var newClient = func(target string, version string) snmpClient {
+	if plugin.Replay != "" {
+		return &replayClient{path: plugin.Replay, decoder: newSNMP(target, version)}
+	}
+	if plugin.MaxOids > 0 {
+		return splitClient{gosnmpClient{newSNMP(target, version)}, plugin.MaxOids}
+	}
+	return gosnmpClient{newSNMP(target, version)}
+}
+
+// ackClient is the part of gosnmp used to acknowledge the unit's alarm, the
+// tests swap in a fake through newAckClient.
+type ackClient interface {
+	Connect() error
+	Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error)
+	Close() error
+}
+
+var newAckClient = func(target string, version string) ackClient {
+	client := newSNMP(target, version)
+	if client.Version != gosnmp.Version3 {
+		client.Community = plugin.WriteCommunity
+	}
+	return gosnmpClient{client}
+}
+
+// acknowledge sets ack-oid on target to ack-value, on a connection of its
+// own so the write community is never used to read.
+func acknowledge(target string, version string) error {
+	client := newAckClient(target, version)
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	result, err := client.Set([]gosnmp.SnmpPDU{{Name: normalizeOID(plugin.AckOID), Type: gosnmp.Integer, Value: plugin.AckValue}})
+	if err != nil {
+		return err
+	}
+	if result.Error != gosnmp.NoError {
+		return fmt.Errorf("the unit refused the Set with %s", result.Error)
+	}
+	return nil
+}
+
+// snmpSlots caps the requests in flight across every client when
+// max-concurrent-snmp is set, nil otherwise.
+var snmpSlots chan struct{}
+
+// dial returns a client speaking version to target, held to the shared
+// snmpSlots.
+func dial(target string, version string) snmpClient {
+	client := newClient(target, version)
+	if snmpSlots != nil {
+		return limitedClient{client, snmpSlots}
+	}
+	return client
+}
+
+// limitedClient waits for one of slots before each Get or walk.
+type limitedClient struct {
+	snmpClient
+	slots chan struct{}
+}
+
+// Get issues the Get once a slot is free.
+func (c limitedClient) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	c.slots <- struct{}{}
+	defer func() { <-c.slots }()
+	return c.snmpClient.Get(oids)
+}
+
+// GetNext issues the GetNext once a slot is free.
+func (c limitedClient) GetNext(oids []string) (*gosnmp.SnmpPacket, error) {
+	c.slots <- struct{}{}
+	defer func() { <-c.slots }()
+	return c.snmpClient.GetNext(oids)
+}
+
+// WalkAll walks rootOid once a slot is free, holding it for the whole walk.
+func (c limitedClient) WalkAll(rootOid string) ([]gosnmp.SnmpPDU, error) {
+	c.slots <- struct{}{}
+	defer func() { <-c.slots }()
+	return c.snmpClient.WalkAll(rootOid)
+}
+
+// splitClient breaks Gets for more than max OIDs into several smaller ones,
+// for units that can't cope with large requests.
+type splitClient struct {
+	snmpClient
+	max int
+}
+
+// Get issues a Get for each max sized chunk of oids and merges the results
+// into one response. An error-status in any of them is returned as is, with
+// the error-index pointing into oids.
+func (c splitClient) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	merged := &gosnmp.SnmpPacket{}
+	for start := 0; start < len(oids); start += c.max {
+		end := start + c.max
+		if end > len(oids) {
+			end = len(oids)
+		}
+
+		result, err := c.snmpClient.Get(oids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if result.Error != gosnmp.NoError {
+			if result.ErrorIndex > 0 {
+				result.ErrorIndex += uint8(start)
+			}
+			return result, nil
+		}
+
+		if start == 0 {
+			*merged = *result
+			merged.Variables = nil
+		}
+		merged.Variables = append(merged.Variables, result.Variables...)
+	}
+	return merged, nil
+}
+
+// newSNMP returns an SNMP client speaking version to target, configured from
+// the plugin options.
+func newSNMP(target string, version string) *gosnmp.GoSNMP {
+	client := &gosnmp.GoSNMP{
+		Target:             target,
+		Port:               161,
+		Transport:          "udp",
+		Community:          communityFor(target),
+		Version:            snmpVersions[version],
+		Timeout:            time.Duration(2) * time.Second,
+		Retries:            3,
+		ExponentialTimeout: true,
+		MaxOids:            gosnmp.MaxOids,
+	}
+	if plugin.MaxOids > 0 {
+		client.MaxOids = plugin.MaxOids
+	}
+
+	if client.Version == gosnmp.Version3 {
+		// v3 identifies the user by security name, the community isn't sent
+		client.Community = ""
+		client.SecurityModel = gosnmp.UserSecurityModel
+		client.MsgFlags = v3MsgFlags()
+
+		params := &gosnmp.UsmSecurityParameters{
+			UserName:               plugin.SecurityName,
+			AuthenticationProtocol: authProtocols[strings.ToLower(plugin.AuthProtocol)],
+			PrivacyProtocol:        privProtocols[strings.ToLower(plugin.PrivProtocol)],
+		}
+		if client.MsgFlags&gosnmp.AuthNoPriv != 0 {
+			params.AuthenticationPassphrase = plugin.AuthPassphrase
+		}
+		if client.MsgFlags&gosnmp.AuthPriv == gosnmp.AuthPriv {
+			params.PrivacyPassphrase = plugin.PrivPassphrase
+		}
+		// a known engine ID means discovery is skipped, checkArgs has made
+		// sure it decodes
+		if id, err := engineID(plugin.V3EngineID); err == nil {
+			params.AuthoritativeEngineID = id
+		}
+		client.SecurityParameters = params
+	}
+
+	return client
+}
+
+// engineID decodes a hex engine ID, with or without a 0x prefix, into the raw
+// bytes gosnmp expects.
+func engineID(s string) (string, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+	id, err := hex.DecodeString(s)
+	return string(id), err
+}
+
+// communityFor returns the community to use with target.
+func communityFor(target string) string {
+	if community, ok := plugin.CommunityMap[target]; ok {
+		return community
+	}
+	if target == plugin.HumidityTarget && plugin.HumidityCommunity != "" {
+		return plugin.HumidityCommunity
+	}
+	return plugin.Community
+}
+
+// readCommunityMap reads target=community pairs, one per line, from the file
+// at path. Blank lines and comments are skipped.
+func readCommunityMap(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read community-map-file: %v", err)
+	}
+
+	communities := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pair := strings.SplitN(line, "=", 2)
+		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" {
+			return nil, fmt.Errorf("community-map-file line %d must be target=community.", i+1)
+		}
+		communities[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return communities, nil
+}
+
+// v3MsgFlags derives the SNMPv3 security level from the configured protocols.
+func v3MsgFlags() gosnmp.SnmpV3MsgFlags {
+	switch {
+	case plugin.PrivProtocol != "":
+		return gosnmp.AuthPriv
+	case plugin.AuthProtocol != "":
+		return gosnmp.AuthNoPriv
+	default:
+		return gosnmp.NoAuthNoPriv
+	}
+}
+
+// checkV3Args validates the SNMPv3 options. With no auth or priv protocol
+// the check runs as noAuthNoPriv and no passphrases are needed.
+func checkV3Args() error {
+
+	// a security name is always required
+	if plugin.SecurityName == "" {
+		return fmt.Errorf("security-name must be specified for snmp-version 3.")
+	}
+
+	if _, ok := authProtocols[strings.ToLower(plugin.AuthProtocol)]; !ok {
+		return fmt.Errorf("unknown auth-protocol %q.", plugin.AuthProtocol)
+	}
+
+	if _, ok := privProtocols[strings.ToLower(plugin.PrivProtocol)]; !ok {
+		return fmt.Errorf("unknown priv-protocol %q.", plugin.PrivProtocol)
+	}
+
+	// there's no authPriv without auth
+	if plugin.PrivProtocol != "" && plugin.AuthProtocol == "" {
+		return fmt.Errorf("priv-protocol requires an auth-protocol.")
+	}
+
+	if plugin.AuthProtocol != "" && plugin.AuthPassphrase == "" {
+		return fmt.Errorf("auth-passphrase must be specified with auth-protocol.")
+	}
+
+	if plugin.PrivProtocol != "" && plugin.PrivPassphrase == "" {
+		return fmt.Errorf("priv-passphrase must be specified with priv-protocol.")
+	}
+
+	if _, err := engineID(plugin.V3EngineID); err != nil {
+		return fmt.Errorf("v3-engine-id must be hex.")
+	}
+
+	return nil
+}
+
+// errorStatusMessage explains a non-zero error-status returned by the agent.
+func errorStatusMessage(result *gosnmp.SnmpPacket, oids []string) string {
+
+	// error-index is 1 based and points at the offending variable binding
+	oid := "requested"
+	if i := int(result.ErrorIndex); i > 0 && i <= len(oids) {
+		oid = oids[i-1]
+	}
+
+	switch result.Error {
+	case gosnmp.TooBig:
+		return "response too big for the agent, request fewer OIDs."
+	case gosnmp.NoSuchName:
+		return fmt.Sprintf("OID %s not found on agent.", oid)
+	case gosnmp.BadValue:
+		return fmt.Sprintf("agent rejected the request for OID %s as a bad value.", oid)
+	case gosnmp.GenErr:
+		return fmt.Sprintf("agent reported a general error reading OID %s.", oid)
+	case gosnmp.NoAccess:
+		return fmt.Sprintf("access to OID %s denied, check the community or SNMPv3 view.", oid)
+	case gosnmp.AuthorizationError:
+		return "agent refused authorization, check the community or SNMPv3 credentials."
+	case gosnmp.ResourceUnavailable:
+		return "agent is out of resources, try again later."
+	default:
+		return fmt.Sprintf("agent returned error-status %s for OID %s.", result.Error, oid)
+	}
+}
+
+// responseTypes are the types the MIB gives the values of the standard Get,
+// in the order they're requested.
+var responseTypes = []gosnmp.Asn1BER{gosnmp.OctetString, gosnmp.Integer, gosnmp.Integer, gosnmp.TimeTicks}
+
+// validateResponse lists each value of the standard Get, matched to oids,
+// that isn't of the type the MIB gives it.
+func validateResponse(result *gosnmp.SnmpPacket, oids []string) []string {
+	var mismatches []string
+	for i, v := range result.Variables {
+		if i >= len(responseTypes) || i >= len(oids) {
+			break
+		}
+		if v.Type != responseTypes[i] {
+			mismatches = append(mismatches, fmt.Sprintf("%s returned %s, expected %s", oids[i], v.Type, responseTypes[i]))
+		}
+	}
+	return mismatches
+}
+
+// uptimeSeconds converts a sysUpTime TimeTicks variable into seconds.
+func uptimeSeconds(v gosnmp.SnmpPDU) (int, bool) {
+	if v.Type != gosnmp.TimeTicks {
+		return 0, false
+	}
+	return int(gosnmp.ToBigInt(v.Value).Int64() / 100), true
+}
+
+// readOptional fetches a single oid on its own so an agent without it can't
+// fail the main Get. ok is false when the agent doesn't have a value for it.
+func readOptional(client snmpClient, oid string) (v gosnmp.SnmpPDU, ok bool) {
+	result, err := client.Get([]string{oid})
+	if err != nil || result.Error != gosnmp.NoError || len(result.Variables) == 0 {
+		return v, false
+	}
+
+	v = result.Variables[0]
+	switch v.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return v, false
+	}
+	return v, true
+}
+
+// readSysName returns the unit's sysName, or an empty string when it doesn't
+// have one.
+func readSysName(client snmpClient) string {
+	v, ok := readOptional(client, sysNameOID)
+	if !ok {
+		return ""
+	}
+	name, ok := v.Value.([]byte)
+	if !ok {
+		return ""
+	}
+	return string(name)
+}
+
+// readFirmware returns the unit's firmware version, or an empty string when
+// it can't be read.
+func readFirmware(client snmpClient) string {
+	v, ok := readOptional(client, normalizeOID(plugin.FirmwareOID))
+	if !ok {
+		return ""
+	}
+	version, ok := v.Value.([]byte)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(string(version))
+}
+
+// readAssetTag returns the unit's asset tag, or an empty string when it
+// doesn't have one.
+func readAssetTag(client snmpClient) string {
+	v, ok := readOptional(client, normalizeOID(plugin.AssetTagOID))
+	if !ok {
+		return ""
+	}
+	tag, ok := v.Value.([]byte)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(string(tag))
+}
+
+// readUnit returns the unit the readings are reported in, as c, f or k,
+// from the first letter of the unit's answer. Anything unreadable is taken
+// to be celsius, the unit's default.
+func readUnit(client snmpClient) string {
+	v, ok := readOptional(client, normalizeOID(plugin.UnitOID))
+	if !ok {
+		return "c"
+	}
+	unit, ok := v.Value.([]byte)
+	if !ok {
+		return "c"
+	}
+	switch s := strings.ToLower(strings.TrimSpace(string(unit))); {
+	case strings.HasPrefix(s, "f"):
+		return "f"
+	case strings.HasPrefix(s, "k"):
+		return "k"
+	}
+	return "c"
+}
+
+// toCelsius converts a reading in unit to celsius.
+func toCelsius(unit string, v float64) float64 {
+	switch unit {
+	case "f":
+		return (v - 32) * 5 / 9
+	case "k":
+		return v - 273.15
+	}
+	return v
+}
+
+// readHumidity returns the humidity in percent, read through client unless
+// it lives on a separate humidity-target.
+func readHumidity(target string, version string, client snmpClient) (float64, bool) {
+	if plugin.HumidityTarget != "" && plugin.HumidityTarget != target {
+		client = dial(plugin.HumidityTarget, version)
+		if err := client.Connect(); err != nil {
+			return 0, false
+		}
+		defer client.Close()
+	}
+	return readHundredths(client, plugin.HumidityOID)
+}
+
+// readSetpoint returns the unit's configured setpoint in degrees.
+func readSetpoint(client snmpClient) (float64, bool) {
+	return readHundredths(client, plugin.SetpointOID)
+}
+
+// readBattery returns the unit's battery voltage.
+func readBattery(client snmpClient) (float64, bool) {
+	return readHundredths(client, plugin.BatteryOID)
+}
+
+// readCO2 returns the unit's CO2 reading in ppm.
+func readCO2(client snmpClient) (int, bool) {
+	v, ok := readOptional(client, normalizeOID(plugin.CO2OID))
+	if !ok {
+		return 0, false
+	}
+	ppm, ok := v.Value.(int)
+	return ppm, ok
+}
+
+// readProbeStatus returns the external probe's status, lowercased.
+func readProbeStatus(client snmpClient) (string, bool) {
+	v, ok := readOptional(client, normalizeOID(plugin.ProbeStatusOID))
+	if !ok {
+		return "", false
+	}
+	status, ok := v.Value.([]byte)
+	if !ok {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(string(status))), true
+}
+
+// readDoor returns whether the door contact is open, from an open or closed
+// string or a non-zero integer.
+func readDoor(client snmpClient) (bool, bool) {
+	v, ok := readOptional(client, normalizeOID(plugin.DoorOID))
+	if !ok {
+		return false, false
+	}
+	switch value := v.Value.(type) {
+	case []byte:
+		switch strings.ToLower(strings.TrimSpace(string(value))) {
+		case "open":
+			return true, true
+		case "closed":
+			return false, true
+		}
+	case int:
+		return value != 0, true
+	}
+	return false, false
+}
+
+// readIfErrors returns the error counters of the interface at if-index,
+// both read in one Get so they're from the same moment.
+func readIfErrors(client snmpClient) (ifErrors, bool) {
+	var errs ifErrors
+	index := "." + strconv.Itoa(plugin.IfIndex)
+
+	result, err := client.Get([]string{ifInErrorsOID + index, ifOutErrorsOID + index})
+	if err != nil || result.Error != gosnmp.NoError || len(result.Variables) != 2 {
+		return errs, false
+	}
+	for _, v := range result.Variables {
+		if v.Type != gosnmp.Counter32 && v.Type != gosnmp.Counter64 {
+			return errs, false
+		}
+	}
+	errs.In = gosnmp.ToBigInt(result.Variables[0].Value).Uint64()
+	errs.Out = gosnmp.ToBigInt(result.Variables[1].Value).Uint64()
+	return errs, true
+}
+
+// readProbeCount returns the number of temperature probes on the unit, from
+// probe-count-oid or else by counting the temperature rows of the sensor
+// table, or the rows of the keyed table when there's no type column.
+func readProbeCount(client snmpClient) (int, bool) {
+	if plugin.ProbeCountOID != "" {
+		v, ok := readOptional(client, normalizeOID(plugin.ProbeCountOID))
+		if !ok {
+			return 0, false
+		}
+		count, ok := v.Value.(int)
+		return count, ok
+	}
+
+	// the type column says which rows are probes, the key column of a keyed
+	// table has nothing else in it
+	if plugin.SensorTypeOID == "" {
+		rows, err := client.WalkAll(normalizeOID(plugin.ProbeKeyOID))
+		if err != nil {
+			return 0, false
+		}
+		return len(rows), true
+	}
+
+	rows, err := client.WalkAll(normalizeOID(plugin.SensorTypeOID))
+	if err != nil {
+		return 0, false
+	}
+	count := 0
+	for _, row := range rows {
+		if cell, ok := row.Value.([]byte); ok && strings.EqualFold(strings.TrimSpace(string(cell)), "temperature") {
+			count++
+		}
+	}
+	return count, true
+}
+
+// readHundredths reads an optional value given in hundredths, of a degree, a
+// percent or a volt, from oid and returns it in whole units.
+func readHundredths(client snmpClient, oid string) (float64, bool) {
+	v, ok := readOptional(client, normalizeOID(oid))
+	if !ok {
+		return 0, false
+	}
+	raw, ok := v.Value.(int)
+	if !ok {
+		return 0, false
+	}
+	return float64(raw) / 100.0, true
+}
+
+// refusedV1 reports whether a v1 Get failed in a way that suggests the unit
+// wants another version, either by never answering or by refusing access.
+func refusedV1(result *gosnmp.SnmpPacket, err error) bool {
+	if err != nil {
+		return isTimeout(err)
+	}
+	return result.Error == gosnmp.AuthorizationError || result.Error == gosnmp.NoAccess
+}
+
+// isTimeout reports whether err is the unit failing to answer in time, as
+// opposed to a decode or authentication problem.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// gosnmp replaces the net error with its own once it runs out of retries
+	return strings.Contains(err.Error(), "timeout")
+}
+
+// matchVariables returns a copy of result with its variables in the same
+// order as the oids they answer, matched by name, so an agent or library
+// that reorders the bindings can't have one value read as another. A
+// variable whose name isn't one of the oids stays where it was, for the
+// oid at the same position, and an oid with no answer at all gets a Null.
+func matchVariables(result *gosnmp.SnmpPacket, oids []string) *gosnmp.SnmpPacket {
+	byName := make(map[string]gosnmp.SnmpPDU, len(result.Variables))
+	for _, v := range result.Variables {
+		byName[strings.TrimPrefix(v.Name, ".")] = v
+	}
+	requested := make(map[string]bool, len(oids))
+	for _, oid := range oids {
+		requested[strings.TrimPrefix(oid, ".")] = true
+	}
+
+	matched := *result
+	matched.Variables = make([]gosnmp.SnmpPDU, len(oids))
+	for i, oid := range oids {
+		if v, ok := byName[strings.TrimPrefix(oid, ".")]; ok {
+			matched.Variables[i] = v
+			continue
+		}
+		if i < len(result.Variables) && !requested[strings.TrimPrefix(result.Variables[i].Name, ".")] {
+			matched.Variables[i] = result.Variables[i]
+			continue
+		}
+		matched.Variables[i] = gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Null}
+	}
+	return &matched
+}
+
+// withoutNoSuchName redoes a v1 Get refused with noSuchName without each oid
+// the agent names in turn, v1 failing the whole Get over one missing value.
+// The answer comes back as v2c would give it, each value at the position of
+// its oid and NoSuchInstance for those the agent doesn't have, ready for
+// fillFromGetNext.
+func withoutNoSuchName(client snmpClient, result *gosnmp.SnmpPacket, oids []string) (*gosnmp.SnmpPacket, error) {
+	asked := make([]int, len(oids))
+	for i := range asked {
+		asked[i] = i
+	}
+	request := oids
+
+	for result.Error == gosnmp.NoSuchName {
+		// the error-index counts from 1 into what was asked for
+		i := int(result.ErrorIndex) - 1
+		if i < 0 || i >= len(asked) {
+			return result, nil
+		}
+		asked = append(asked[:i:i], asked[i+1:]...)
+		if len(asked) == 0 {
+			result = &gosnmp.SnmpPacket{}
+			break
+		}
+
+		request = make([]string, len(asked))
+		for j, position := range asked {
+			request[j] = oids[position]
+		}
+		var err error
+		if result, err = client.Get(request); err != nil {
+			return nil, err
+		}
+	}
+	if result.Error != gosnmp.NoError {
+		return result, nil
+	}
+
+	answered := matchVariables(result, request)
+	shaped := *result
+	shaped.Variables = make([]gosnmp.SnmpPDU, len(oids))
+	for i, oid := range oids {
+		shaped.Variables[i] = gosnmp.SnmpPDU{Name: oid, Type: gosnmp.NoSuchInstance}
+	}
+	for j, position := range asked {
+		shaped.Variables[position] = answered.Variables[j]
+	}
+	return &shaped, nil
+}
+
+// fillFromGetNext replaces each value the agent has no scalar .0 instance
+// of with the first value under the scalar's oid, for agents that only give
+// it up to a GetNext. Anything GetNext doesn't turn up within the subtree is
+// left missing.
+func fillFromGetNext(client snmpClient, result *gosnmp.SnmpPacket, oids []string) *gosnmp.SnmpPacket {
+	var (
+		positions []int
+		bases     []string
+	)
+	for i, v := range result.Variables {
+		if v.Type == gosnmp.NoSuchInstance && i < len(oids) && strings.HasSuffix(oids[i], ".0") {
+			positions = append(positions, i)
+			bases = append(bases, strings.TrimSuffix(oids[i], ".0"))
+		}
+	}
+	if len(bases) == 0 {
+		return result
+	}
+
+	next, err := client.GetNext(bases)
+	if err != nil || next.Error != gosnmp.NoError || len(next.Variables) != len(bases) {
+		return result
+	}
+
+	filled := *result
+	filled.Variables = append([]gosnmp.SnmpPDU(nil), result.Variables...)
+	for j, v := range next.Variables {
+		subtree := strings.TrimPrefix(bases[j], ".") + "."
+		if strings.HasPrefix(strings.TrimPrefix(v.Name, "."), subtree) {
+			filled.Variables[positions[j]] = v
+		}
+	}
+	return &filled
+}
+
+// reading is the decoded response to the standard Get, along with the raw
+// values the temperatures were scaled from.
+type reading struct {
+	location    string
+	internal    float64
+	external    float64
+	rawInternal int
+	rawExternal int
+	noInternal  bool
+	noExternal  bool
+	// statuses are those given alongside a DisplayString reading, empty
+	// for integers
+	internalStatus string
+	externalStatus string
+}
+
+// errExternalFault is returned by decodeReading when only the external probe
+// couldn't be read, in which case the location and internal reading are set.
+var errExternalFault = errors.New("failed to read external temperature.")
+
+// decodeReading validates the standard Get response and converts the oid
+// values into something usable.
+func decodeReading(result *gosnmp.SnmpPacket) (reading, error) {
+	var r reading
+
+	if len(result.Variables) < 3 {
+		return r, errors.New("failed to read location.")
+	}
+
+	// validate the location oid
+	location_oid, ok := result.Variables[0].Value.([]uint8)
+	if !ok {
+		return r, errors.New("failed to read location.")
+	}
+	r.location = string(location_oid)
+
+	// validate the internal temperature oid
+	inttemp_oid, status, ok := temperatureValue(result.Variables[1].Value)
+	if !ok {
+		return r, errors.New("failed to read internal temperature.")
+	}
+	r.rawInternal, r.internalStatus = inttemp_oid, status
+	r.internal = float64(inttemp_oid) / 100.0
+
+	// validate the external temperature oid
+	exttemp_oid, status, ok := temperatureValue(result.Variables[2].Value)
+	if !ok {
+		return r, errExternalFault
+	}
+	r.rawExternal, r.externalStatus = exttemp_oid, status
+	r.external = float64(exttemp_oid) / 100.0
+	return r, nil
+}
+
+// temperatureValue returns a temperature in hundredths of a degree, either
+// the Integer the unit normally sends or a DisplayString in degrees, such as
+// "21.5 (OK)", with the status in brackets returned alongside.
+func temperatureValue(value interface{}) (int, string, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, "", true
+	case []byte:
+		return parseDisplayTemperature(string(v))
+	}
+	return 0, "", false
+}
+
+// parseDisplayTemperature parses a DisplayString reading of degrees,
+// optionally followed by a status in brackets.
+func parseDisplayTemperature(s string) (int, string, bool) {
+	s = strings.TrimSpace(s)
+
+	var status string
+	if i := strings.Index(s, "("); i >= 0 && strings.HasSuffix(s, ")") {
+		s, status = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:len(s)-1])
+	}
+
+	degrees, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(degrees) || math.IsInf(degrees, 0) {
+		return 0, "", false
+	}
+	return int(math.Round(degrees * 100)), status, true
+}
+
+// decodePartial decodes whatever it can of the standard Get response, for
+// partial-ok, and returns the names of the values that couldn't be read.
+func decodePartial(result *gosnmp.SnmpPacket) (reading, []string) {
+	var (
+		r       reading
+		skipped []string
+	)
+	value := func(i int) interface{} {
+		if i < len(result.Variables) {
+			return result.Variables[i].Value
+		}
+		return nil
+	}
+
+	if location, ok := value(0).([]uint8); ok {
+		r.location = string(location)
+	} else {
+		skipped = append(skipped, "location")
+	}
+
+	if internal, status, ok := temperatureValue(value(1)); ok {
+		r.rawInternal, r.internalStatus = internal, status
+		r.internal = float64(internal) / 100.0
+	} else {
+		r.noInternal = true
+		skipped = append(skipped, "internal temperature")
+	}
+
+	if external, status, ok := temperatureValue(value(2)); ok {
+		r.rawExternal, r.externalStatus = external, status
+		r.external = float64(external) / 100.0
+	} else {
+		r.noExternal = true
+		skipped = append(skipped, "external temperature")
+	}
+	return r, skipped
+}
+
+// dumpResponse writes a line per variable in result to w, with the hex of
+// the value's content bytes alongside the decoded value.
+func dumpResponse(w io.Writer, result *gosnmp.SnmpPacket) {
+	for _, v := range result.Variables {
+		decoded := fmt.Sprintf("%v", v.Value)
+		if b, ok := v.Value.([]byte); ok {
+			decoded = strconv.Quote(string(b))
+		}
+		fmt.Fprintf(w, "%s %s [% x] %s\n", v.Name, v.Type, valueBytes(v.Value), decoded)
+	}
+}
+
+// valueBytes returns the BER content bytes of a decoded value, the string
+// itself for octet strings and the shortest two's complement form for
+// integers.
+func valueBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case int:
+		return berInteger(int64(v))
+	case uint:
+		return berInteger(int64(v))
+	case uint32:
+		return berInteger(int64(v))
+	case uint64:
+		// the top bit needs a byte of its own to stay positive
+		if v>>63 == 1 {
+			b := make([]byte, 9)
+			binary.BigEndian.PutUint64(b[1:], v)
+			return b
+		}
+		return berInteger(int64(v))
+	}
+	return nil
+}
+
+// berInteger encodes n in the fewest bytes of big-endian two's complement.
+func berInteger(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+
+	// drop leading bytes that only repeat the sign
+	for len(b) > 1 && ((b[0] == 0x00 && b[1]&0x80 == 0) || (b[0] == 0xff && b[1]&0x80 != 0)) {
+		b = b[1:]
+	}
+	return b
+}
+
+// probeValueOID walks the probe-key-oid column for the row whose key is
+// probe-key and returns the matching cell of the probe-value-oid column.
+func probeValueOID(client snmpClient) (string, error) {
+	oid, ok, err := tableValueOID(client, plugin.ProbeKeyOID, plugin.ProbeKey, plugin.ProbeValueOID)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("no probe with key %s found.", plugin.ProbeKey)
+	}
+	return oid, nil
+}
+
+// discoverSensorOID walks the sensor-type-oid column for the temperature
+// sensor and returns the matching cell of the sensor-value-oid column, so a
+// firmware that reshuffles the sensor indices doesn't break the check.
+func discoverSensorOID(client snmpClient) (string, error) {
+	oid, ok, err := tableValueOID(client, plugin.SensorTypeOID, "temperature", plugin.SensorValueOID)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("no temperature sensor found in the sensor table.")
+	}
+	return oid, nil
+}
+
+// tableValueOID walks the keyOID column for the first row whose value is key,
+// ignoring case and surrounding whitespace, and returns the OID of the same
+// row in the valueOID column.
+func tableValueOID(client snmpClient, keyOID string, key string, valueOID string) (string, bool, error) {
+	keyOID = normalizeOID(keyOID)
+	rows, err := client.WalkAll(keyOID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to walk the sensor table.")
+	}
+
+	for _, row := range rows {
+		cell, ok := row.Value.([]byte)
+		if !ok || !strings.EqualFold(strings.TrimSpace(string(cell)), key) {
+			continue
+		}
+		index := strings.TrimPrefix(row.Name, keyOID)
+		return normalizeOID(valueOID) + index, true, nil
+	}
+
+	return "", false, nil
+}
+
+// normalizeOID gives oid the leading dot gosnmp uses in responses.
+func normalizeOID(oid string) string {
+	return "." + strings.Trim(oid, ".")
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gosnmp/gosnmp"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckArgsV3NoAuthNoPriv(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.SnmpVersion = "3"
+	plugin.SecurityName = "monitor"
+
+	if status, err := checkArgs(nil); err != nil {
+		t.Fatalf("checkArgs() = %d, %v, want no error", status, err)
+	}
+}
+
+func TestCheckArgsV3(t *testing.T) {
+	tests := []struct {
+		name           string
+		securityName   string
+		authProtocol   string
+		authPassphrase string
+		privProtocol   string
+		privPassphrase string
+		wantErr        bool
+	}{
+		{"noAuthNoPriv", "monitor", "", "", "", "", false},
+		{"noAuthNoPriv ignores passphrases", "monitor", "", "secret", "", "secret", false},
+		{"missing security name", "", "", "", "", "", true},
+		{"authNoPriv", "monitor", "SHA", "secret", "", "", false},
+		{"authNoPriv missing passphrase", "monitor", "SHA", "", "", "", true},
+		{"authPriv", "monitor", "SHA", "secret", "AES", "secret", false},
+		{"authPriv missing passphrase", "monitor", "SHA", "secret", "AES", "", true},
+		{"priv without auth", "monitor", "", "", "AES", "secret", true},
+		{"unknown auth protocol", "monitor", "SHA1024", "secret", "", "", true},
+		{"unknown priv protocol", "monitor", "SHA", "secret", "ROT13", "secret", true},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.SnmpVersion = "3"
+		plugin.SecurityName = tt.securityName
+		plugin.AuthProtocol = tt.authProtocol
+		plugin.AuthPassphrase = tt.authPassphrase
+		plugin.PrivProtocol = tt.privProtocol
+		plugin.PrivPassphrase = tt.privPassphrase
+
+		_, err := checkArgs(nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkArgs() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckArgsUnknownVersion(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.SnmpVersion = "4"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted snmp-version 4")
+	}
+}
+
+func TestNewSNMPV3NoAuthNoPriv(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.SnmpVersion = "3"
+	plugin.SecurityName = "monitor"
+	plugin.AuthPassphrase = "ignored"
+
+	client := newSNMP(plugin.Target, plugin.SnmpVersion)
+	if client.Version != gosnmp.Version3 {
+		t.Errorf("Version = %v, want %v", client.Version, gosnmp.Version3)
+	}
+	if client.Community != "" {
+		t.Errorf("Community = %q, want empty", client.Community)
+	}
+	if client.MsgFlags != gosnmp.NoAuthNoPriv {
+		t.Errorf("MsgFlags = %v, want %v", client.MsgFlags, gosnmp.NoAuthNoPriv)
+	}
+	if client.SecurityModel != gosnmp.UserSecurityModel {
+		t.Errorf("SecurityModel = %v, want %v", client.SecurityModel, gosnmp.UserSecurityModel)
+	}
+
+	params, ok := client.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if !ok {
+		t.Fatalf("SecurityParameters = %T, want *gosnmp.UsmSecurityParameters", client.SecurityParameters)
+	}
+	if params.UserName != "monitor" {
+		t.Errorf("UserName = %q, want %q", params.UserName, "monitor")
+	}
+	if params.AuthenticationProtocol != gosnmp.NoAuth || params.PrivacyProtocol != gosnmp.NoPriv {
+		t.Errorf("protocols = %v/%v, want %v/%v", params.AuthenticationProtocol, params.PrivacyProtocol, gosnmp.NoAuth, gosnmp.NoPriv)
+	}
+	if params.AuthenticationPassphrase != "" || params.PrivacyPassphrase != "" {
+		t.Error("passphrases set for noAuthNoPriv")
+	}
+}
+
+func TestNewSNMPV3AuthPriv(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.SnmpVersion = "3"
+	plugin.SecurityName = "monitor"
+	plugin.AuthProtocol = "SHA256"
+	plugin.AuthPassphrase = "authsecret"
+	plugin.PrivProtocol = "AES"
+	plugin.PrivPassphrase = "privsecret"
+
+	client := newSNMP(plugin.Target, plugin.SnmpVersion)
+	if client.MsgFlags != gosnmp.AuthPriv {
+		t.Errorf("MsgFlags = %v, want %v", client.MsgFlags, gosnmp.AuthPriv)
+	}
+	params := client.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if params.AuthenticationProtocol != gosnmp.SHA256 || params.PrivacyProtocol != gosnmp.AES {
+		t.Errorf("protocols = %v/%v, want %v/%v", params.AuthenticationProtocol, params.PrivacyProtocol, gosnmp.SHA256, gosnmp.AES)
+	}
+	if params.AuthenticationPassphrase != "authsecret" || params.PrivacyPassphrase != "privsecret" {
+		t.Error("passphrases not propagated")
+	}
+}
+
+func TestNewSNMPV3EngineID(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.SnmpVersion = "3"
+	plugin.SecurityName = "monitor"
+	plugin.V3EngineID = "0x80001F8880E9630000D61FF449"
+
+	if _, err := checkArgs(nil); err != nil {
+		t.Fatalf("checkArgs() error = %v", err)
+	}
+	client := newSNMP(plugin.Target, plugin.SnmpVersion)
+	if client.SecurityModel != gosnmp.UserSecurityModel {
+		t.Errorf("SecurityModel = %v, want %v", client.SecurityModel, gosnmp.UserSecurityModel)
+	}
+	params := client.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	want := string([]byte{0x80, 0x00, 0x1f, 0x88, 0x80, 0xe9, 0x63, 0x00, 0x00, 0xd6, 0x1f, 0xf4, 0x49})
+	if params.AuthoritativeEngineID != want {
+		t.Errorf("AuthoritativeEngineID = %x, want %x", params.AuthoritativeEngineID, want)
+	}
+
+	// without it the engine is discovered
+	plugin.V3EngineID = ""
+	params = newSNMP(plugin.Target, plugin.SnmpVersion).SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if params.AuthoritativeEngineID != "" {
+		t.Errorf("AuthoritativeEngineID = %x, want none", params.AuthoritativeEngineID)
+	}
+}
+
+func TestCheckArgsV3EngineID(t *testing.T) {
+	for _, id := range []string{"80001f88zz", "800"} {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.SnmpVersion = "3"
+		plugin.SecurityName = "monitor"
+		plugin.V3EngineID = id
+		if _, err := checkArgs(nil); err == nil {
+			t.Errorf("checkArgs() accepted the engine ID %q", id)
+		}
+	}
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.V3EngineID = "80001f88"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted v3-engine-id without snmp-version 3")
+	}
+}
+
+func TestNewSNMPV1KeepsCommunity(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Community = "private"
+
+	client := newSNMP(plugin.Target, plugin.SnmpVersion)
+	if client.Version != gosnmp.Version1 || client.Community != "private" {
+		t.Errorf("client = %v/%q, want %v/%q", client.Version, client.Community, gosnmp.Version1, "private")
+	}
+}
+
+func TestConnectSourceAddress(t *testing.T) {
+	setDefaults()
+	plugin.Target = "127.0.0.1"
+	plugin.SourceAddress = "127.0.0.1"
+
+	client := newClient(plugin.Target, plugin.SnmpVersion).(gosnmpClient)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	local, ok := client.Conn.LocalAddr().(*net.UDPAddr)
+	if !ok || !local.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("local address = %v, want 127.0.0.1", client.Conn.LocalAddr())
+	}
+}
+
+func TestConnectUnassignedSourceAddress(t *testing.T) {
+	setDefaults()
+	plugin.Target = "127.0.0.1"
+	plugin.SourceAddress = "192.0.2.1"
+
+	// binding to an address the host doesn't have proves the bind is applied
+	client := newClient(plugin.Target, plugin.SnmpVersion)
+	if err := client.Connect(); err == nil {
+		client.Close()
+		t.Error("Connect() bound to an address this host doesn't have")
+	}
+}
+
+func TestCheckArgsSourceAddress(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.SourceAddress = "eth0"
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a source-address that isn't an IP address")
+	}
+}
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o deadline reached" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTimeout(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("request timeout (after 3 retries)"), true},
+		{fmt.Errorf("read: %w", timeoutError{}), true},
+		{errors.New("incoming packet is not authentic, discarding"), false},
+		{errors.New("unable to decode packet"), false},
+	}
+	for _, tt := range tests {
+		if got := isTimeout(tt.err); got != tt.want {
+			t.Errorf("isTimeout(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// countingClient answers every Get with a Counter32 per OID, recording the
+// OIDs of each Get.
+type countingClient struct {
+	snmpClient
+	gets [][]string
+}
+
+func (c *countingClient) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	c.gets = append(c.gets, oids)
+	packet := &gosnmp.SnmpPacket{}
+	for _, oid := range oids {
+		packet.Variables = append(packet.Variables, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Counter32, Value: uint(len(c.gets))})
+	}
+	return packet, nil
+}
+
+func TestSplitClientGet(t *testing.T) {
+	oids := []string{".1.1", ".1.2", ".1.3", ".1.4", ".1.5", ".1.6", ".1.7"}
+	tests := []struct {
+		max      int
+		wantGets int
+	}{
+		{1, 7},
+		{2, 4},
+		{3, 3},
+		{7, 1},
+		{60, 1},
+	}
+	for _, tt := range tests {
+		inner := &countingClient{}
+		result, err := splitClient{inner, tt.max}.Get(oids)
+		if err != nil {
+			t.Fatalf("max %d: Get() error = %v", tt.max, err)
+		}
+		if len(inner.gets) != tt.wantGets {
+			t.Errorf("max %d: %d Gets issued, want %d", tt.max, len(inner.gets), tt.wantGets)
+		}
+		for _, get := range inner.gets {
+			if len(get) > tt.max {
+				t.Errorf("max %d: a Get asked for %d OIDs", tt.max, len(get))
+			}
+		}
+
+		// the merged response lines up with the request
+		if len(result.Variables) != len(oids) {
+			t.Fatalf("max %d: %d variables, want %d", tt.max, len(result.Variables), len(oids))
+		}
+		for i, v := range result.Variables {
+			if v.Name != oids[i] {
+				t.Errorf("max %d: variable %d is %s, want %s", tt.max, i, v.Name, oids[i])
+			}
+		}
+	}
+}
+
+func TestSplitClientGetErrorIndex(t *testing.T) {
+	a := agent{}
+	inner := &fakeClient{get: func(oids []string) (*gosnmp.SnmpPacket, error) {
+		if oids[0] == ".1.3" {
+			return &gosnmp.SnmpPacket{Error: gosnmp.NoSuchName, ErrorIndex: 2}, nil
+		}
+		return a.get(oids)
+	}}
+
+	result, err := splitClient{inner, 2}.Get([]string{".1.1", ".1.2", ".1.3", ".1.4"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if result.Error != gosnmp.NoSuchName || result.ErrorIndex != 4 {
+		t.Errorf("Get() = %v at %d, want NoSuchName at 4", result.Error, result.ErrorIndex)
+	}
+}
+
+func TestNewClientMaxOids(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.MaxOids = 2
+
+	client, ok := newClient(plugin.Target, plugin.SnmpVersion).(splitClient)
+	if !ok || client.max != 2 {
+		t.Fatalf("newClient() = %#v, want a splitClient of 2", client)
+	}
+	if got := client.snmpClient.(gosnmpClient).MaxOids; got != 2 {
+		t.Errorf("MaxOids = %d, want 2", got)
+	}
+}
+
+func TestMatchVariables(t *testing.T) {
+	oids := []string{locationOID, internalOID, externalOID}
+	location := gosnmp.SnmpPDU{Name: locationOID, Type: gosnmp.OctetString, Value: []byte("lab")}
+	internal := gosnmp.SnmpPDU{Name: internalOID, Type: gosnmp.Integer, Value: 2000}
+	external := gosnmp.SnmpPDU{Name: strings.TrimPrefix(externalOID, "."), Type: gosnmp.Integer, Value: 2150}
+	unnamed := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.99999.1", Type: gosnmp.Integer, Value: 2150}
+
+	tests := []struct {
+		name      string
+		variables []gosnmp.SnmpPDU
+		want      []gosnmp.SnmpPDU
+	}{
+		{"in order", []gosnmp.SnmpPDU{location, internal, external}, []gosnmp.SnmpPDU{location, internal, external}},
+		{"reordered", []gosnmp.SnmpPDU{external, location, internal}, []gosnmp.SnmpPDU{location, internal, external}},
+		{"missing", []gosnmp.SnmpPDU{internal, location}, []gosnmp.SnmpPDU{location, internal, {Name: externalOID, Type: gosnmp.Null}}},
+		{"unrequested name", []gosnmp.SnmpPDU{location, internal, unnamed}, []gosnmp.SnmpPDU{location, internal, unnamed}},
+	}
+	for _, tt := range tests {
+		result := &gosnmp.SnmpPacket{Variables: tt.variables}
+		if got := matchVariables(result, oids).Variables; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matchVariables() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseDisplayTemperature(t *testing.T) {
+	tests := []struct {
+		s          string
+		wantRaw    int
+		wantStatus string
+		wantOK     bool
+	}{
+		{"21.5 (OK)", 2150, "OK", true},
+		{"55.0 (ALARM)", 5500, "ALARM", true},
+		{"21.5", 2150, "", true},
+		{" -3.25 ", -325, "", true},
+		{"21.5(OK)", 2150, "OK", true},
+		{"(OK)", 0, "", false},
+		{"NaN", 0, "", false},
+		{"n/a", 0, "", false},
+	}
+	for _, tt := range tests {
+		raw, status, ok := parseDisplayTemperature(tt.s)
+		if raw != tt.wantRaw || status != tt.wantStatus || ok != tt.wantOK {
+			t.Errorf("parseDisplayTemperature(%q) = %d, %q, %t, want %d, %q, %t", tt.s, raw, status, ok, tt.wantRaw, tt.wantStatus, tt.wantOK)
+		}
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	oids := []string{locationOID, internalOID, externalOID, uptimeOID}
+	good := tempagerPacket("lab", 2000, 2150)
+	good.Variables = append(good.Variables, gosnmp.SnmpPDU{Name: uptimeOID, Type: gosnmp.TimeTicks, Value: uint32(360000)})
+	if got := validateResponse(good, oids); len(got) != 0 {
+		t.Errorf("validateResponse() = %q, want no mismatches", got)
+	}
+
+	bad := tempagerPacket("lab", 2000, 2150)
+	bad.Variables[2] = gosnmp.SnmpPDU{Name: externalOID, Type: gosnmp.Gauge32, Value: uint(2150)}
+	bad.Variables = append(bad.Variables, gosnmp.SnmpPDU{Name: uptimeOID, Type: gosnmp.NoSuchObject})
+	want := []string{
+		externalOID + " returned Gauge32, expected Integer",
+		uptimeOID + " returned NoSuchObject, expected TimeTicks",
+	}
+	if got := validateResponse(bad, oids); !reflect.DeepEqual(got, want) {
+		t.Errorf("validateResponse() = %q, want %q", got, want)
+	}
+}
+
+func TestFillFromGetNext(t *testing.T) {
+	oids := []string{locationOID, internalOID, externalOID}
+	internalBase := strings.TrimSuffix(internalOID, ".0")
+	externalBase := strings.TrimSuffix(externalOID, ".0")
+
+	result := tempagerPacket("lab", 2000, 2150)
+	result.Variables[1] = gosnmp.SnmpPDU{Name: internalOID, Type: gosnmp.NoSuchInstance}
+	result.Variables[2] = gosnmp.SnmpPDU{Name: externalOID, Type: gosnmp.NoSuchInstance}
+
+	var asked []string
+	client := &fakeClient{getNext: func(oids []string) (*gosnmp.SnmpPacket, error) {
+		asked = oids
+		return &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{
+			{Name: internalBase + ".1", Type: gosnmp.Integer, Value: 2000},
+			// past the end of the external subtree, so not its value
+			{Name: ".1.3.6.1.4.1.20916.1.7.1.2.2.1.0", Type: gosnmp.Integer, Value: 9999},
+		}}, nil
+	}}
+
+	got := fillFromGetNext(client, result, oids)
+	if !reflect.DeepEqual(asked, []string{internalBase, externalBase}) {
+		t.Errorf("fillFromGetNext() asked for %q, want the subtrees of both temperatures", asked)
+	}
+	if got.Variables[1].Value != 2000 || got.Variables[2].Type != gosnmp.NoSuchInstance {
+		t.Errorf("fillFromGetNext() = %v, want the internal filled and the external still missing", got.Variables)
+	}
+	if result.Variables[1].Type != gosnmp.NoSuchInstance {
+		t.Error("fillFromGetNext() changed the original result")
+	}
+
+	// nothing to fill means no GetNext at all
+	asked = nil
+	fillFromGetNext(client, tempagerPacket("lab", 2000, 2150), oids)
+	if asked != nil {
+		t.Errorf("fillFromGetNext() asked for %q with every value there", asked)
+	}
+}
+
+func TestToCelsius(t *testing.T) {
+	tests := []struct {
+		unit string
+		v    float64
+		want float64
+	}{
+		{"c", 21.5, 21.5},
+		{"f", 212, 100},
+		{"f", -40, -40},
+		{"k", 273.15, 0},
+	}
+	for _, tt := range tests {
+		if got := toCelsius(tt.unit, tt.v); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("toCelsius(%q, %v) = %v, want %v", tt.unit, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestCommunityFor(t *testing.T) {
+	setDefaults()
+	plugin.Community = "public"
+	plugin.HumidityTarget = "192.0.2.2"
+
+	if got := newSNMP("192.0.2.2", "2c").Community; got != "public" {
+		t.Errorf("humidity community = %q, want the shared public", got)
+	}
+
+	plugin.HumidityCommunity = "hygro"
+	if got := newSNMP("192.0.2.2", "2c").Community; got != "hygro" {
+		t.Errorf("humidity community = %q, want hygro", got)
+	}
+	if got := newSNMP("192.0.2.1", "2c").Community; got != "public" {
+		t.Errorf("temperature community = %q, want public", got)
+	}
+}
+
+func TestCommunityMap(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+	plugin.Community = "public"
+	plugin.CommunityMap = map[string]string{"192.0.2.1": "lab-ro", "192.0.2.2": "hall-ro"}
+
+	// each unit in the sweep is dialled with its own community
+	var communities []string
+	oldClient := newClient
+	newClient = func(target string, version string) snmpClient {
+		communities = append(communities, newSNMP(target, version).Community)
+		return &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}
+	}
+	defer func() { newClient = oldClient }()
+
+	sweep([]string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, 1, false)
+	if want := []string{"lab-ro", "hall-ro", "public"}; strings.Join(communities, ",") != strings.Join(want, ",") {
+		t.Errorf("communities = %v, want %v", communities, want)
+	}
+}
+
+func TestCheckArgsCommunityMapFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tempager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "communities")
+	data := "# lab units\n192.0.2.1 = lab-ro\n\n192.0.2.2=hall-ro\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	setDefaults()
+	plugin.StdinTargets = true
+	plugin.CommunityMapFile = path
+	plugin.CommunityMap = map[string]string{"192.0.2.2": "override"}
+	if _, err := checkArgs(nil); err != nil {
+		t.Fatalf("checkArgs() error = %v", err)
+	}
+	want := map[string]string{"192.0.2.1": "lab-ro", "192.0.2.2": "override"}
+	if !reflect.DeepEqual(plugin.CommunityMap, want) {
+		t.Errorf("community map = %v, want %v", plugin.CommunityMap, want)
+	}
+
+	// a line without a community isn't silently ignored
+	if err := ioutil.WriteFile(path, []byte("192.0.2.1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setDefaults()
+	plugin.StdinTargets = true
+	plugin.CommunityMapFile = path
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a malformed community-map-file")
+	}
+
+	setDefaults()
+	plugin.StdinTargets = true
+	plugin.CommunityMap = map[string]string{"lab": "lab-ro"}
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a community-map target that isn't an IP")
+	}
+}
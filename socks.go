@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// socksConn is a UDP association through a SOCKS5 proxy (RFC 1928). Every
+// datagram to and from the relay carries a header naming the far end, which
+// socksConn adds and strips so gosnmp can treat it as a plain connection.
+type socksConn struct {
+	net.Conn
+	control net.Conn
+	header  []byte
+}
+
+// dialSOCKS5UDP asks the SOCKS5 proxy to relay UDP datagrams to addr,
+// sending from local when it isn't nil.
+func dialSOCKS5UDP(proxy string, addr string, local net.IP, timeout time.Duration) (net.Conn, error) {
+	header, err := socksAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	if local != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: local}
+	}
+	control, err := dialer.Dial("tcp", proxy)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to SOCKS proxy: %w", err)
+	}
+	if timeout > 0 {
+		control.SetDeadline(time.Now().Add(timeout))
+	}
+
+	relay, err := socksAssociate(control)
+	if err != nil {
+		control.Close()
+		return nil, err
+	}
+	control.SetDeadline(time.Time{})
+
+	// a relay on the unspecified address lives on the proxy host
+	if relay.IP.IsUnspecified() {
+		relay.IP = control.RemoteAddr().(*net.TCPAddr).IP
+	}
+
+	udpDialer := net.Dialer{Timeout: timeout}
+	if local != nil {
+		udpDialer.LocalAddr = &net.UDPAddr{IP: local}
+	}
+	conn, err := udpDialer.Dial("udp", relay.String())
+	if err != nil {
+		control.Close()
+		return nil, fmt.Errorf("error connecting to SOCKS relay: %w", err)
+	}
+
+	// RSV, FRAG then the destination address
+	return &socksConn{Conn: conn, control: control, header: append([]byte{0, 0, 0}, header...)}, nil
+}
+
+// socksAssociate negotiates no authentication and a UDP ASSOCIATE over the
+// control connection, returning the relay address.
+func socksAssociate(control net.Conn) (*net.UDPAddr, error) {
+	if _, err := control.Write([]byte{5, 1, 0}); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(control, reply); err != nil {
+		return nil, fmt.Errorf("error reading SOCKS greeting: %w", err)
+	}
+	if reply[0] != 5 || reply[1] != 0 {
+		return nil, errors.New("SOCKS proxy requires an unsupported authentication method")
+	}
+
+	// the client address is unknown ahead of time, so ask for any
+	if _, err := control.Write([]byte{5, 3, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(control, head); err != nil {
+		return nil, fmt.Errorf("error reading SOCKS reply: %w", err)
+	}
+	if head[1] != 0 {
+		return nil, fmt.Errorf("SOCKS proxy refused the UDP association (reply %d)", head[1])
+	}
+
+	var ip net.IP
+	switch head[3] {
+	case 1:
+		ip = make(net.IP, net.IPv4len)
+	case 4:
+		ip = make(net.IP, net.IPv6len)
+	default:
+		return nil, fmt.Errorf("unsupported SOCKS relay address type %d", head[3])
+	}
+	if _, err := io.ReadFull(control, ip); err != nil {
+		return nil, err
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(control, port); err != nil {
+		return nil, err
+	}
+
+	return &net.UDPAddr{IP: ip, Port: int(binary.BigEndian.Uint16(port))}, nil
+}
+
+// socksAddress encodes addr as a SOCKS5 ATYP, address and port.
+func socksAddress(addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		return nil, fmt.Errorf("missing host in %q", addr)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	var b []byte
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name %q too long", host)
+		}
+		b = append([]byte{3, byte(len(host))}, host...)
+	case ip.To4() != nil:
+		b = append([]byte{1}, ip.To4()...)
+	default:
+		b = append([]byte{4}, ip.To16()...)
+	}
+	return append(b, byte(port>>8), byte(port)), nil
+}
+
+// Write sends p to the far end through the relay.
+func (c *socksConn) Write(p []byte) (int, error) {
+	if _, err := c.Conn.Write(append(append([]byte{}, c.header...), p...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read receives a datagram from the relay into p without its header.
+func (c *socksConn) Read(p []byte) (int, error) {
+	buf := make([]byte, len(p)+262)
+	n, err := c.Conn.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := socksHeaderLen(buf[:n])
+	if err != nil {
+		return 0, err
+	}
+	return copy(p, buf[offset:n]), nil
+}
+
+// socksHeaderLen returns the length of the header on a relayed datagram.
+func socksHeaderLen(b []byte) (int, error) {
+	if len(b) < 4 {
+		return 0, errors.New("short SOCKS datagram")
+	}
+	if b[2] != 0 {
+		return 0, errors.New("fragmented SOCKS datagrams are not supported")
+	}
+
+	var n int
+	switch b[3] {
+	case 1:
+		n = 4 + net.IPv4len + 2
+	case 4:
+		n = 4 + net.IPv6len + 2
+	case 3:
+		if len(b) < 5 {
+			return 0, errors.New("short SOCKS datagram")
+		}
+		n = 4 + 1 + int(b[4]) + 2
+	default:
+		return 0, fmt.Errorf("unsupported SOCKS address type %d", b[3])
+	}
+	if len(b) < n {
+		return 0, errors.New("short SOCKS datagram")
+	}
+	return n, nil
+}
+
+// Close ends the association, the proxy drops the relay with the control
+// connection.
+func (c *socksConn) Close() error {
+	err := c.Conn.Close()
+	if cerr := c.control.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
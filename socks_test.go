@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// socksStub is a minimal SOCKS5 server supporting UDP ASSOCIATE, counting the
+// datagrams it relays.
+type socksStub struct {
+	listener net.Listener
+	relay    *net.UDPConn
+	relayed  int32
+}
+
+func newSOCKSStub(t *testing.T) *socksStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socksStub{listener: listener, relay: relay}
+	t.Cleanup(func() {
+		listener.Close()
+		relay.Close()
+	})
+
+	go s.serveControl()
+	go s.serveRelay()
+	return s
+}
+
+func (s *socksStub) serveControl() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		greeting := make([]byte, 3)
+		io.ReadFull(conn, greeting)
+		conn.Write([]byte{5, 0})
+
+		request := make([]byte, 10)
+		io.ReadFull(conn, request)
+		relay := s.relay.LocalAddr().(*net.UDPAddr)
+		reply := append([]byte{5, 0, 0, 1}, relay.IP.To4()...)
+		reply = append(reply, byte(relay.Port>>8), byte(relay.Port))
+		conn.Write(reply)
+	}
+}
+
+func (s *socksStub) serveRelay() {
+	var client *net.UDPAddr
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := s.relay.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.relayed, 1)
+
+		// from the client the header names the destination, from anywhere
+		// else the header names the source
+		if client == nil || from.String() == client.String() {
+			client = from
+			dst := &net.UDPAddr{IP: net.IP(buf[4:8]), Port: int(binary.BigEndian.Uint16(buf[8:10]))}
+			s.relay.WriteToUDP(buf[10:n], dst)
+			continue
+		}
+		header := append([]byte{0, 0, 0, 1}, from.IP.To4()...)
+		header = append(header, byte(from.Port>>8), byte(from.Port))
+		s.relay.WriteToUDP(append(header, buf[:n]...), client)
+	}
+}
+
+func TestDialSOCKS5UDP(t *testing.T) {
+	proxy := newSOCKSStub(t)
+
+	// the far end answers "pong" to whoever sent the datagram
+	target, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	seen := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 64)
+		n, from, err := target.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		seen <- from.String() + " " + string(buf[:n])
+		target.WriteToUDP([]byte("pong"), from)
+	}()
+
+	conn, err := dialSOCKS5UDP(proxy.listener.Addr().String(), target.LocalAddr().String(), nil, time.Second)
+	if err != nil {
+		t.Fatalf("dialSOCKS5UDP() error = %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "pong" {
+		t.Errorf("Read() = %q, want %q", buf[:n], "pong")
+	}
+
+	// the far end only ever saw the relay
+	if got, want := <-seen, proxy.relay.LocalAddr().String()+" ping"; got != want {
+		t.Errorf("target saw %q, want %q", got, want)
+	}
+	if relayed := atomic.LoadInt32(&proxy.relayed); relayed != 2 {
+		t.Errorf("proxy relayed %d datagrams, want 2", relayed)
+	}
+}
+
+func TestConnectSOCKSProxy(t *testing.T) {
+	proxy := newSOCKSStub(t)
+
+	setDefaults()
+	plugin.Target = "127.0.0.1"
+	plugin.SocksProxy = proxy.listener.Addr().String()
+
+	client := newClient(plugin.Target, plugin.SnmpVersion).(gosnmpClient)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	if _, ok := client.Conn.(*socksConn); !ok {
+		t.Errorf("Conn = %T, want the SNMP traffic routed through the proxy", client.Conn)
+	}
+}
+
+func TestSocksAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    []byte
+		wantErr bool
+	}{
+		{"192.0.2.1:161", []byte{1, 192, 0, 2, 1, 0, 161}, false},
+		{"proxy.example:1080", append(append([]byte{3, 13}, "proxy.example"...), 4, 56), false},
+		{"192.0.2.1", nil, true},
+		{":1080", nil, true},
+		{"192.0.2.1:socks", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := socksAddress(tt.addr)
+		if (err != nil) != tt.wantErr || string(got) != string(tt.want) {
+			t.Errorf("socksAddress(%q) = %v, %v, want %v", tt.addr, got, err, tt.want)
+		}
+	}
+}
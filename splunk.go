@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// hecEvent is an event as the Splunk HTTP Event Collector takes it.
+type hecEvent struct {
+	Time       int64        `json:"time"`
+	Host       string       `json:"host"`
+	Source     string       `json:"source"`
+	Sourcetype string       `json:"sourcetype"`
+	Event      *checkResult `json:"event"`
+}
+
+// hecEndpoint returns the event endpoint of the collector at rawurl, which
+// can be given as just the collector's address.
+func hecEndpoint(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || strings.Trim(u.Path, "/") != "" {
+		return rawurl
+	}
+	u.Path = "/services/collector/event"
+	return u.String()
+}
+
+// sendHEC posts r to the Splunk HTTP Event Collector at rawurl as an event
+// of sourcetype, authenticated with token.
+func sendHEC(rawurl string, token string, sourcetype string, r *checkResult) error {
+	body, err := json.Marshal(hecEvent{
+		Time:       now().Unix(),
+		Host:       r.Target,
+		Source:     plugin.PluginConfig.Name,
+		Sourcetype: sourcetype,
+		Event:      r,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hecEndpoint(rawurl), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("splunk HEC returned %s", resp.Status)
+	}
+	return nil
+}
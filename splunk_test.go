@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hecRequest is a request received by hecStub.
+type hecRequest struct {
+	path          string
+	authorization string
+	body          string
+}
+
+// hecStub records the events it's sent and answers with status.
+func hecStub(t *testing.T, status int) (*httptest.Server, *[]hecRequest) {
+	var requests []hecRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, hecRequest{r.URL.Path, r.Header.Get("Authorization"), string(body)})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestExecuteCheckSplunkHEC(t *testing.T) {
+	server, requests := hecStub(t, http.StatusOK)
+	setDefaults()
+	setNow(t, time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	plugin.Target = "192.0.2.1"
+	plugin.SplunkHECURL = server.URL
+	plugin.SplunkHECToken = "e3b0c442-98fc-1c14"
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))})
+	if state != sensu.CheckStateWarning || strings.Contains(out, "splunk") {
+		t.Errorf("executeCheck() = %d, %q, want the WARNING unchanged", state, out)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("requests = %+v, want one event sent", *requests)
+	}
+	got := (*requests)[0]
+	if got.path != "/services/collector/event" || got.authorization != "Splunk e3b0c442-98fc-1c14" {
+		t.Errorf("request to %s with Authorization %q, want /services/collector/event with the token", got.path, got.authorization)
+	}
+
+	var event struct {
+		Time       int64       `json:"time"`
+		Host       string      `json:"host"`
+		Sourcetype string      `json:"sourcetype"`
+		Event      checkResult `json:"event"`
+	}
+	if err := json.Unmarshal([]byte(got.body), &event); err != nil {
+		t.Fatalf("event %q isn't JSON: %v", got.body, err)
+	}
+	if event.Time != 1591012800 || event.Host != "192.0.2.1" || event.Sourcetype != "tempager:result" {
+		t.Errorf("event = %+v, want the time, target and default sourcetype", event)
+	}
+	if event.Event.State != "WARNING" || event.Event.Location != "lab" || event.Event.External == nil || *event.Event.External != 36 {
+		t.Errorf("event result = %+v, want the WARNING reading of 36c at lab", event.Event)
+	}
+}
+
+func TestExecuteCheckSplunkHECFailure(t *testing.T) {
+	tests := []struct {
+		failureState string
+		wantState    int
+	}{
+		{"ok", sensu.CheckStateOK},
+		{"warning", sensu.CheckStateWarning},
+		{"critical", sensu.CheckStateCritical},
+	}
+	for _, tt := range tests {
+		server, _ := hecStub(t, http.StatusForbidden)
+		setDefaults()
+		plugin.SplunkHECURL = server.URL + "/services/collector/event"
+		plugin.SplunkHECToken = "wrong"
+		plugin.SplunkFailureState = tt.failureState
+
+		state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+		if state != tt.wantState || !strings.Contains(out, "lab temperature is 21.50c; failed to send to splunk: splunk HEC returned 403 Forbidden |") {
+			t.Errorf("%s: executeCheck() = %d, %q, want %d with the failure noted", tt.failureState, state, out, tt.wantState)
+		}
+	}
+}
+
+func TestCheckArgsSplunkHEC(t *testing.T) {
+	tests := []struct {
+		url          string
+		token        string
+		failureState string
+	}{
+		{"splunk:8088", "token", "warning"},
+		{"https://splunk:8088", "", "warning"},
+		{"https://splunk:8088", "token", "unknown"},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.SplunkHECURL = tt.url
+		plugin.SplunkHECToken = tt.token
+		plugin.SplunkFailureState = tt.failureState
+
+		if _, err := checkArgs(nil); err == nil {
+			t.Errorf("checkArgs() accepted splunk-hec-url %q, token %q and splunk-failure-state %q", tt.url, tt.token, tt.failureState)
+		}
+	}
+}
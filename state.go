@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// checkState is what the check remembers between runs in the state-file.
+type checkState struct {
+	LastCritical     string    `json:"last_critical,omitempty"`
+	LastCriticalTime time.Time `json:"last_critical_time,omitempty"`
+	LastExternal     *float64  `json:"last_external,omitempty"`
+	ExternalEMA      *float64  `json:"external_ema,omitempty"`
+	LastPoll         time.Time `json:"last_poll,omitempty"`
+	LastUptime       *int      `json:"last_uptime,omitempty"`
+	IfErrors         *ifErrors `json:"if_errors,omitempty"`
+}
+
+// ifErrors are the management interface's error counters.
+type ifErrors struct {
+	In  uint64 `json:"in"`
+	Out uint64 `json:"out"`
+}
+
+// loadState reads the state file at path. A missing file is a first run and
+// returns an empty state.
+func loadState(path string) (checkState, error) {
+	var s checkState
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+// saveState writes s to the state file at path.
+func saveState(path string, s checkState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
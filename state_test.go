@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestLoadStateMissingFile(t *testing.T) {
+	s, err := loadState(tempStateFile(t))
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	if s.LastCritical != "" || !s.LastCriticalTime.IsZero() {
+		t.Errorf("loadState() = %+v, want empty state", s)
+	}
+}
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	path := tempStateFile(t)
+	want := checkState{
+		LastCritical:     "check-tempager-3e-temperature CRITICAL: lab temperature is 41.00c\n",
+		LastCriticalTime: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	if err := saveState(path, want); err != nil {
+		t.Fatalf("saveState() error = %v", err)
+	}
+	got, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	if got.LastCritical != want.LastCritical || !got.LastCriticalTime.Equal(want.LastCriticalTime) {
+		t.Errorf("loadState() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadStateCorrupt(t *testing.T) {
+	path := tempStateFile(t)
+	if err := ioutil.WriteFile(path, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadState(path); err == nil {
+		t.Error("loadState() accepted a corrupt state file")
+	}
+}
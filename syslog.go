@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// syslogLocal is the syslog address meaning the local syslog socket
+const syslogLocal = "local"
+
+// syslogFacilities are the facilities a result can be logged under
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// syslogSeverities are the syslog severities each check state is logged at
+var syslogSeverities = map[int]int{
+	sensu.CheckStateOK:       6, // info
+	sensu.CheckStateWarning:  4, // warning
+	sensu.CheckStateCritical: 2, // crit
+	sensu.CheckStateUnknown:  3, // err
+}
+
+// checkSyslogAddress validates addr as either the local socket or host:port.
+func checkSyslogAddress(addr string) error {
+	if addr == syslogLocal {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" || port == "" {
+		return fmt.Errorf("syslog must be %s or host:port.", syslogLocal)
+	}
+	return nil
+}
+
+// sendSyslog sends each line of out to the syslog server at addr as an
+// RFC 3164 message, over UDP or to the local syslog socket.
+func sendSyslog(addr string, state int, out string) error {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if addr == syslogLocal {
+		conn, err = net.DialTimeout("unixgram", "/dev/log", time.Second)
+	} else {
+		conn, err = net.DialTimeout("udp", addr, time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	hostname, _ := os.Hostname()
+	priority := syslogFacilities[plugin.SyslogFacility]*8 + syslogSeverities[state]
+	timestamp := now().Format(time.Stamp)
+
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		msg := fmt.Sprintf("<%d>%s %s %s[%d]: %s", priority, timestamp, hostname, plugin.PluginConfig.Name, os.Getpid(), line)
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
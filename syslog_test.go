@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syslogStub listens for syslog messages over UDP.
+func syslogStub(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// receive returns the next message the stub is sent.
+func receive(t *testing.T, conn *net.UDPConn) string {
+	t.Helper()
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("no syslog message received: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestExecuteCheckSyslog(t *testing.T) {
+	stub := syslogStub(t)
+	setDefaults()
+	plugin.Syslog = stub.LocalAddr().String()
+	plugin.SyslogFacility = "local3"
+	setNow(t, time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))})
+	if state != sensu.CheckStateWarning {
+		t.Fatalf("state = %d, want %d", state, sensu.CheckStateWarning)
+	}
+
+	// local3 (19) * 8 + warning (4)
+	hostname, _ := os.Hostname()
+	want := fmt.Sprintf("<156>Jun  1 12:00:00 %s check-tempager-3e-temperature[%d]: %s", hostname, os.Getpid(), strings.TrimSpace(out))
+	if got := receive(t, stub); got != want {
+		t.Errorf("syslog message = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteCheckSyslogUnreachable(t *testing.T) {
+	setDefaults()
+	plugin.Syslog = "syslog.invalid:514"
+
+	state, out := runCheck(t, &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))})
+	if state != sensu.CheckStateOK || !strings.Contains(out, "OK: lab temperature is 21.50c") {
+		t.Errorf("executeCheck() = %d, %q, want the result regardless of syslog", state, out)
+	}
+}
+
+func TestCheckArgsSyslog(t *testing.T) {
+	tests := []struct {
+		addr     string
+		facility string
+		valid    bool
+	}{
+		{"local", "daemon", true},
+		{"192.0.2.9:514", "local7", true},
+		{"192.0.2.9", "daemon", false},
+		{":514", "daemon", false},
+		{"192.0.2.9:514", "printer", false},
+	}
+	for _, tt := range tests {
+		setDefaults()
+		plugin.Target = "192.0.2.1"
+		plugin.Syslog = tt.addr
+		plugin.SyslogFacility = tt.facility
+
+		if _, err := checkArgs(nil); (err == nil) != tt.valid {
+			t.Errorf("%s/%s: checkArgs() error = %v, want valid %v", tt.addr, tt.facility, err, tt.valid)
+		}
+	}
+}
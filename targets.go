@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// sweepResult is the outcome of polling one of the stdin-targets, a nil res
+// means it was never polled.
+type sweepResult struct {
+	res *checkResult
+	err error
+}
+
+// failed reports whether the unit couldn't be read at all, as opposed to
+// being read and breaching a threshold.
+func (r sweepResult) failed() bool {
+	var threshold *ErrThreshold
+	return r.err != nil && !errors.As(r.err, &threshold)
+}
+
+// pollTargets polls each target read from r, one per line, printing a result
+// line for every one in the order they were read and returning the worst
+// state. Blank lines and comments are skipped, and a malformed line is
+// reported as UNKNOWN without stopping the sweep, unless fail-fast is set, in
+// which case the first unit that can't be read ends it with its state.
+func pollTargets(r io.Reader) (int, error) {
+	var targets []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		target := strings.TrimSpace(scanner.Text())
+		if target == "" || strings.HasPrefix(target, "#") {
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return sensu.CheckStateUnknown, fmt.Errorf("failed to read targets: %v", err)
+	}
+
+	if len(targets) == 0 {
+		return sensu.CheckStateUnknown, fmt.Errorf("no targets read from stdin.")
+	}
+
+	// the results are printed once they're all in, so the output doesn't
+	// depend on which unit answered first
+	state := sensu.CheckStateOK
+	for _, result := range sweep(targets, plugin.Concurrency, plugin.FailFast) {
+		if result.res == nil {
+			continue
+		}
+		s, _ := result.res.print()
+		if plugin.FailFast && result.failed() {
+			return s, nil
+		}
+		state = worst(state, s)
+	}
+	return state, nil
+}
+
+// sweep polls targets with at most concurrency of them in flight at once,
+// returning their results in the same order. With failFast, targets not yet
+// started when one fails are skipped.
+func sweep(targets []string, concurrency int, failFast bool) []sweepResult {
+	results := make([]sweepResult, len(targets))
+
+	var (
+		abort     = make(chan struct{})
+		abortOnce sync.Once
+	)
+	aborted := func() bool {
+		select {
+		case <-abort:
+			return true
+		default:
+			return false
+		}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(targets); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if aborted() {
+					continue
+				}
+				results[i].res, results[i].err = sweepTarget(targets[i])
+				if failFast && results[i].failed() {
+					abortOnce.Do(func() { close(abort) })
+				}
+			}
+		}()
+	}
+
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// sweepTarget polls a single line of the sweep.
+func sweepTarget(target string) (*checkResult, error) {
+	if net.ParseIP(target) == nil {
+		err := fmt.Errorf("target must be an IP address.")
+		return (&checkResult{Target: target}).fail(sensu.CheckStateUnknown, err.Error(), &ErrConfig{Err: err})
+	}
+	return checkTarget(target)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"github.com/gosnmp/gosnmp"
+	"github.com/sensu-community/sensu-plugin-sdk/sensu"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runSweep runs executeCheck in stdin-targets mode over input, answering each
+// target from units, returning the state and output.
+func runSweep(t *testing.T, input string, units map[string]snmpClient) (int, string) {
+	t.Helper()
+
+	stdin = strings.NewReader(input)
+	defer func() { stdin = os.Stdin }()
+
+	return runUnits(t, units)
+}
+
+func TestExecuteCheckStdinTargets(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+
+	units := map[string]snmpClient{
+		"192.0.2.1": &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))},
+		"192.0.2.2": &fakeClient{get: respond(tempagerPacket("hall", 2000, 3600))},
+	}
+	input := "192.0.2.1\n\n# decommissioned\nnot-a-unit\n192.0.2.2\n"
+
+	state, out := runSweep(t, input, units)
+	want := []string{
+		"check-tempager-3e-temperature OK: 192.0.2.1: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50",
+		"check-tempager-3e-temperature UNKNOWN: not-a-unit: target must be an IP address.",
+		"check-tempager-3e-temperature WARNING: 192.0.2.2: hall temperature is 36.00c | tempager_internal=20.00, tempager_external=36.00",
+	}
+	if got := strings.Split(strings.TrimSuffix(out, "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	// the bad line counts, but doesn't stop the sweep
+	if state != sensu.CheckStateUnknown {
+		t.Errorf("state = %d, want %d", state, sensu.CheckStateUnknown)
+	}
+}
+
+func TestExecuteCheckStdinTargetsWorstState(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+
+	units := map[string]snmpClient{
+		"192.0.2.1": &fakeClient{get: respond(tempagerPacket("lab", 2000, 4100))},
+		"192.0.2.2": &fakeClient{get: respond(tempagerPacket("hall", 2000, 2150))},
+	}
+
+	state, _ := runSweep(t, "192.0.2.1\nbogus\n192.0.2.2\n", units)
+	if state != sensu.CheckStateCritical {
+		t.Errorf("state = %d, want %d", state, sensu.CheckStateCritical)
+	}
+}
+
+func TestPollTargetsEmpty(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+
+	if _, err := pollTargets(strings.NewReader("\n# nothing here\n")); err == nil {
+		t.Error("pollTargets() accepted input without any targets")
+	}
+}
+
+func TestCheckArgsStdinTargets(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+	if _, err := checkArgs(nil); err != nil {
+		t.Errorf("checkArgs() error = %v, want none without a target", err)
+	}
+
+	plugin.Target = "192.0.2.1"
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted both target and stdin-targets")
+	}
+
+	plugin.Target = ""
+	plugin.StateFile = tempStateFile(t)
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a state-file with stdin-targets")
+	}
+}
+
+func TestExecuteCheckStdinTargetsJSONGzip(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+	plugin.Output = "json-gz"
+
+	units := map[string]snmpClient{
+		"192.0.2.1": &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))},
+		"192.0.2.2": &fakeClient{get: respond(tempagerPacket("hall", 2000, 4100))},
+	}
+
+	state, out := runSweep(t, "192.0.2.1\n192.0.2.2\n", units)
+	if state != sensu.CheckStateCritical {
+		t.Errorf("state = %d, want the worst %d", state, sensu.CheckStateCritical)
+	}
+
+	zr, err := gzip.NewReader(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("output isn't gzip: %v", err)
+	}
+	var records []checkResult
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		var r checkResult
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decompressed output isn't JSON: %v", err)
+		}
+		records = append(records, r)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("%d records, want 2", len(records))
+	}
+	if records[0].Target != "192.0.2.1" || records[0].State != "OK" || records[1].Target != "192.0.2.2" || records[1].State != "CRITICAL" {
+		t.Errorf("records = %+v, want an OK and a CRITICAL", records)
+	}
+}
+
+func TestExecuteCheckStdinTargetsConcurrency(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+	plugin.Concurrency = 3
+
+	// every Get holds until all three are in flight, so a sequential sweep
+	// would time out instead
+	var arrived sync.WaitGroup
+	arrived.Add(3)
+	release := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(release)
+	}()
+	held := func(packet *gosnmp.SnmpPacket) func([]string) (*gosnmp.SnmpPacket, error) {
+		return func([]string) (*gosnmp.SnmpPacket, error) {
+			arrived.Done()
+			select {
+			case <-release:
+				return packet, nil
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("targets weren't polled concurrently")
+			}
+		}
+	}
+
+	units := map[string]snmpClient{
+		"192.0.2.1": &fakeClient{get: held(tempagerPacket("lab", 2000, 2150))},
+		"192.0.2.2": &fakeClient{get: held(tempagerPacket("hall", 2000, 3600))},
+		"192.0.2.3": &fakeClient{get: held(tempagerPacket("attic", 2000, 2200))},
+	}
+
+	state, out := runSweep(t, "192.0.2.1\n192.0.2.2\nnot-a-unit\n192.0.2.3\n", units)
+	want := []string{
+		"check-tempager-3e-temperature OK: 192.0.2.1: lab temperature is 21.50c | tempager_internal=20.00, tempager_external=21.50",
+		"check-tempager-3e-temperature WARNING: 192.0.2.2: hall temperature is 36.00c | tempager_internal=20.00, tempager_external=36.00",
+		"check-tempager-3e-temperature UNKNOWN: not-a-unit: target must be an IP address.",
+		"check-tempager-3e-temperature OK: 192.0.2.3: attic temperature is 22.00c | tempager_internal=20.00, tempager_external=22.00",
+	}
+	if got := strings.Split(strings.TrimSuffix(out, "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if state != sensu.CheckStateUnknown {
+		t.Errorf("state = %d, want %d", state, sensu.CheckStateUnknown)
+	}
+}
+
+func TestSweepErrors(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+
+	oldClient := newClient
+	newClient = func(target string, version string) snmpClient {
+		if target == "192.0.2.1" {
+			return &fakeClient{connectErr: errors.New("no route to host")}
+		}
+		return &fakeClient{get: respond(tempagerPacket("lab", 2000, 2150))}
+	}
+	defer func() { newClient = oldClient }()
+
+	results := sweep([]string{"192.0.2.1", "192.0.2.2", "bogus"}, 2, false)
+
+	var connectErr *ErrConnect
+	if !errors.As(results[0].err, &connectErr) {
+		t.Errorf("results[0].err = %v, want an ErrConnect", results[0].err)
+	}
+	if results[1].err != nil || results[1].res.Status != sensu.CheckStateOK {
+		t.Errorf("results[1] = %+v, %v, want OK", results[1].res, results[1].err)
+	}
+	var configErr *ErrConfig
+	if !errors.As(results[2].err, &configErr) {
+		t.Errorf("results[2].err = %v, want an ErrConfig", results[2].err)
+	}
+}
+
+func TestCheckArgsConcurrency(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+	plugin.Concurrency = 0
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted a concurrency of 0")
+	}
+
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.Concurrency = 4
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted concurrency without stdin-targets")
+	}
+}
+
+func TestExecuteCheckStdinTargetsFailFast(t *testing.T) {
+	for _, failFast := range []bool{true, false} {
+		setDefaults()
+		plugin.StdinTargets = true
+		plugin.FailFast = failFast
+
+		last := &fakeClient{get: respond(tempagerPacket("attic", 2000, 2150))}
+		units := map[string]snmpClient{
+			"192.0.2.1": &fakeClient{get: respond(tempagerPacket("lab", 2000, 3600))},
+			"192.0.2.2": &fakeClient{connectErr: errors.New("no route to host")},
+			"192.0.2.3": last,
+		}
+
+		state, out := runSweep(t, "192.0.2.1\n192.0.2.2\n192.0.2.3\n", units)
+		lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+
+		// a breached threshold isn't a failure, the unreachable unit is
+		if state != sensu.CheckStateCritical {
+			t.Errorf("fail-fast %v: state = %d, want %d", failFast, state, sensu.CheckStateCritical)
+		}
+		if failFast && (len(lines) != 2 || len(last.gets) != 0) {
+			t.Errorf("fail-fast: output = %q, %d gets of the last unit, want the sweep to stop at 192.0.2.2", lines, len(last.gets))
+		}
+		if !failFast && (len(lines) != 3 || len(last.gets) != 1) {
+			t.Errorf("best effort: output = %q, %d gets of the last unit, want every unit polled", lines, len(last.gets))
+		}
+	}
+}
+
+func TestCheckArgsFailFast(t *testing.T) {
+	setDefaults()
+	plugin.Target = "192.0.2.1"
+	plugin.FailFast = true
+
+	if _, err := checkArgs(nil); err == nil {
+		t.Error("checkArgs() accepted fail-fast without stdin-targets")
+	}
+}
+
+func TestExecuteCheckMaxConcurrentSNMP(t *testing.T) {
+	setDefaults()
+	plugin.StdinTargets = true
+	plugin.Concurrency = 4
+	plugin.MaxConcurrentSNMP = 2
+
+	// every Get holds on for a moment, recording how many are in flight
+	var (
+		mu             sync.Mutex
+		inFlight, most int
+	)
+	slow := func([]string) (*gosnmp.SnmpPacket, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > most {
+			most = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return tempagerPacket("lab", 2000, 2150), nil
+	}
+
+	units := map[string]snmpClient{}
+	var input strings.Builder
+	for _, target := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"} {
+		units[target] = &fakeClient{get: slow}
+		input.WriteString(target + "\n")
+	}
+
+	state, out := runSweep(t, input.String(), units)
+	if state != sensu.CheckStateOK || strings.Count(out, "OK: ") != 4 {
+		t.Errorf("executeCheck() = %d, %q, want four OKs", state, out)
+	}
+	if most != 2 {
+		t.Errorf("at most %d Gets in flight, want 2", most)
+	}
+}